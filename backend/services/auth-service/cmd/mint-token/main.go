@@ -0,0 +1,73 @@
+// Command mint-token signs Cognito-shaped test tokens with the key that an
+// auth service running in JWT_VALIDATION_MODE=test trusts.
+//
+//	go run ./cmd/mint-token -new-key test-key.pem
+//	go run ./cmd/mint-token -key test-key.pem -email user@example.com
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/jwt"
+)
+
+func main() {
+	keyFile := flag.String("key", os.Getenv("JWT_TEST_PRIVATE_KEY_FILE"), "PEM private key to sign with")
+	newKey := flag.String("new-key", "", "write a new private key to this path and exit")
+	userPoolID := flag.String("pool", envOr("COGNITO_USER_POOL_ID", "us-east-1_dummy123"), "user pool ID for the issuer")
+	region := flag.String("region", envOr("AWS_REGION", "us-east-1"), "region for the issuer")
+	clientID := flag.String("client", envOr("COGNITO_CLIENT_ID", "dummyclientid123456789"), "app client ID")
+	tokenUse := flag.String("use", "access", "token_use: access or id")
+	subject := flag.String("sub", "", "subject (random if empty)")
+	email := flag.String("email", "", "email claim")
+	scope := flag.String("scope", "", "scope claim")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime")
+	flag.Parse()
+
+	if *newKey != "" {
+		signer, err := jwt.NewTestSigner(*userPoolID, *region, *clientID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*newKey, signer.EncodePrivateKey(), 0o600); err != nil {
+			log.Fatal("Failed to write key:", err)
+		}
+		return
+	}
+
+	if *keyFile == "" {
+		log.Fatal("-key or JWT_TEST_PRIVATE_KEY_FILE is required")
+	}
+
+	signer, err := jwt.LoadTestSigner(*keyFile, *userPoolID, *region, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	claims := jwt.Claims{
+		TokenUse:      *tokenUse,
+		Email:         *email,
+		EmailVerified: *email != "",
+		Scope:         *scope,
+		ExpTime:       time.Now().Add(*ttl).Unix(),
+	}
+	claims.Subject = *subject
+
+	token, err := signer.Mint(claims)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(token)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
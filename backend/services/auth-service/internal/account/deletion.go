@@ -0,0 +1,244 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDeletionNotFound  = errors.New("no account deletion is scheduled")
+	ErrDeletionScheduled = errors.New("account deletion is already scheduled")
+)
+
+// Deletion is a requested account deletion waiting out its grace period
+type Deletion struct {
+	// Subject is the user's sub claim, which other services store as
+	// users.cognito_user_id
+	Subject string `json:"userId"`
+	// Username is what the identity provider deletes the user by
+	Username    string    `json:"-"`
+	Email       string    `json:"email"`
+	Pool        string    `json:"pool,omitempty"`
+	RequestedAt time.Time `json:"requestedAt"`
+	DeleteAt    time.Time `json:"deleteAt"`
+	// UserDeleted is set once the identity provider no longer has the user,
+	// so a failed event publish is retried without deleting again
+	UserDeleted bool `json:"-"`
+}
+
+// DeletionStore persists scheduled deletions by subject
+type DeletionStore interface {
+	// Add schedules d, failing with ErrDeletionScheduled if one exists
+	Add(ctx context.Context, d Deletion) error
+	Get(ctx context.Context, subject string) (*Deletion, error)
+	Update(ctx context.Context, d Deletion) error
+	Remove(ctx context.Context, subject string) error
+	// Due returns the deletions whose grace period ended by now
+	Due(ctx context.Context, now time.Time) ([]Deletion, error)
+}
+
+// MemoryDeletionStore is an in-process DeletionStore
+type MemoryDeletionStore struct {
+	mu        sync.Mutex
+	deletions map[string]Deletion
+}
+
+func NewMemoryDeletionStore() *MemoryDeletionStore {
+	return &MemoryDeletionStore{
+		deletions: make(map[string]Deletion),
+	}
+}
+
+func (s *MemoryDeletionStore) Add(ctx context.Context, d Deletion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.deletions[d.Subject]; found {
+		return ErrDeletionScheduled
+	}
+	s.deletions[d.Subject] = d
+	return nil
+}
+
+func (s *MemoryDeletionStore) Get(ctx context.Context, subject string) (*Deletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, found := s.deletions[subject]
+	if !found {
+		return nil, ErrDeletionNotFound
+	}
+	return &d, nil
+}
+
+func (s *MemoryDeletionStore) Update(ctx context.Context, d Deletion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.deletions[d.Subject]; !found {
+		return ErrDeletionNotFound
+	}
+	s.deletions[d.Subject] = d
+	return nil
+}
+
+func (s *MemoryDeletionStore) Remove(ctx context.Context, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.deletions[subject]; !found {
+		return ErrDeletionNotFound
+	}
+	delete(s.deletions, subject)
+	return nil
+}
+
+func (s *MemoryDeletionStore) Due(ctx context.Context, now time.Time) ([]Deletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Deletion
+	for _, d := range s.deletions {
+		if !d.DeleteAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sortByDeleteAt(due)
+	return due, nil
+}
+
+// sortByDeleteAt orders deletions by when they fall due
+func sortByDeleteAt(deletions []Deletion) {
+	sort.Slice(deletions, func(i, j int) bool { return deletions[i].DeleteAt.Before(deletions[j].DeleteAt) })
+}
+
+// DeleteFunc removes a user from the identity provider. It should treat a
+// user that no longer exists as deleted.
+type DeleteFunc func(ctx context.Context, d Deletion) error
+
+// Deletions schedules account deletions and carries them out once their
+// grace period ends, publishing an event at each step
+type Deletions struct {
+	grace     time.Duration
+	store     DeletionStore
+	publisher Publisher
+	delete    DeleteFunc
+}
+
+func NewDeletions(grace time.Duration, store DeletionStore, publisher Publisher, deleteUser DeleteFunc) *Deletions {
+	return &Deletions{
+		grace:     grace,
+		store:     store,
+		publisher: publisher,
+		delete:    deleteUser,
+	}
+}
+
+// GracePeriod is how long a user has to cancel a requested deletion
+func (s *Deletions) GracePeriod() time.Duration {
+	return s.grace
+}
+
+// Schedule records a deletion request. With no grace period the account is
+// deleted right away.
+func (s *Deletions) Schedule(ctx context.Context, d Deletion) (*Deletion, error) {
+	d.RequestedAt = time.Now().UTC()
+	d.DeleteAt = d.RequestedAt.Add(s.grace)
+	d.UserDeleted = false
+
+	if err := s.store.Add(ctx, d); err != nil {
+		return nil, err
+	}
+
+	if err := s.publisher.Publish(ctx, NewEvent(EventDeletionRequested, d)); err != nil {
+		log.Printf("Failed to publish %s for %s: %v", EventDeletionRequested, d.Subject, err)
+	}
+
+	if s.grace <= 0 {
+		if err := s.complete(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return &d, nil
+}
+
+// Status returns the user's scheduled deletion
+func (s *Deletions) Status(ctx context.Context, subject string) (*Deletion, error) {
+	return s.store.Get(ctx, subject)
+}
+
+// Cancel withdraws a scheduled deletion that hasn't been carried out yet
+func (s *Deletions) Cancel(ctx context.Context, subject string) error {
+	d, err := s.store.Get(ctx, subject)
+	if err != nil {
+		return err
+	}
+	if d.UserDeleted {
+		return ErrDeletionNotFound
+	}
+
+	if err := s.store.Remove(ctx, subject); err != nil {
+		return err
+	}
+
+	if err := s.publisher.Publish(ctx, NewEvent(EventDeletionCancelled, *d)); err != nil {
+		log.Printf("Failed to publish %s for %s: %v", EventDeletionCancelled, d.Subject, err)
+	}
+
+	return nil
+}
+
+// Run carries out due deletions every interval until ctx is done
+func (s *Deletions) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.processDue(ctx, now)
+		}
+	}
+}
+
+func (s *Deletions) processDue(ctx context.Context, now time.Time) {
+	due, err := s.store.Due(ctx, now)
+	if err != nil {
+		log.Printf("Failed to list due account deletions: %v", err)
+		return
+	}
+
+	for _, d := range due {
+		if err := s.complete(ctx, d); err != nil {
+			log.Printf("Account deletion for %s failed, will retry: %v", d.Subject, err)
+		}
+	}
+}
+
+// complete deletes the user and publishes the deleted event. The record is
+// only removed once both succeeded, so either step is retried on failure.
+func (s *Deletions) complete(ctx context.Context, d Deletion) error {
+	if !d.UserDeleted {
+		if err := s.delete(ctx, d); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		d.UserDeleted = true
+		if err := s.store.Update(ctx, d); err != nil {
+			return err
+		}
+	}
+
+	if err := s.publisher.Publish(ctx, NewEvent(EventDeleted, d)); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", EventDeleted, err)
+	}
+
+	return s.store.Remove(ctx, d.Subject)
+}
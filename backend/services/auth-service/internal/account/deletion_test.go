@@ -0,0 +1,249 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPublisher keeps published event types and fails while failing is set
+type recordingPublisher struct {
+	mu      sync.Mutex
+	events  []string
+	failing bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failing {
+		return errors.New("publisher unavailable")
+	}
+	p.events = append(p.events, event.Type)
+	return nil
+}
+
+func (p *recordingPublisher) published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.events...)
+}
+
+// fakeDirectory counts deletions and fails while failing is set
+type fakeDirectory struct {
+	deleted int
+	failing bool
+}
+
+func (d *fakeDirectory) delete(ctx context.Context, del Deletion) error {
+	if d.failing {
+		return errors.New("identity provider unavailable")
+	}
+	d.deleted++
+	return nil
+}
+
+func newTestDeletions(grace time.Duration) (*Deletions, *MemoryDeletionStore, *recordingPublisher, *fakeDirectory) {
+	store, publisher, directory := NewMemoryDeletionStore(), &recordingPublisher{}, &fakeDirectory{}
+	return NewDeletions(grace, store, publisher, directory.delete), store, publisher, directory
+}
+
+func equalEvents(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSchedule(t *testing.T) {
+	s, store, publisher, directory := newTestDeletions(30 * 24 * time.Hour)
+	ctx := context.Background()
+
+	d, err := s.Schedule(ctx, Deletion{Subject: "alice", Username: "alice"})
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	if got := d.DeleteAt.Sub(d.RequestedAt); got != 30*24*time.Hour {
+		t.Errorf("DeleteAt - RequestedAt = %v, want the grace period", got)
+	}
+	if _, err := store.Get(ctx, "alice"); err != nil {
+		t.Errorf("scheduled deletion was not stored: %v", err)
+	}
+
+	if _, err := s.Schedule(ctx, Deletion{Subject: "alice"}); !errors.Is(err, ErrDeletionScheduled) {
+		t.Errorf("second Schedule() error = %v, want %v", err, ErrDeletionScheduled)
+	}
+
+	if directory.deleted != 0 {
+		t.Errorf("user deleted during the grace period")
+	}
+	if got := publisher.published(); !equalEvents(got, EventDeletionRequested) {
+		t.Errorf("events = %v, want %s", got, EventDeletionRequested)
+	}
+}
+
+func TestScheduleWithoutGrace(t *testing.T) {
+	s, store, publisher, directory := newTestDeletions(0)
+	ctx := context.Background()
+
+	if _, err := s.Schedule(ctx, Deletion{Subject: "alice"}); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	if directory.deleted != 1 {
+		t.Errorf("deleted = %d, want the user deleted right away", directory.deleted)
+	}
+	if _, err := store.Get(ctx, "alice"); !errors.Is(err, ErrDeletionNotFound) {
+		t.Errorf("completed deletion is still stored: %v", err)
+	}
+	if got := publisher.published(); !equalEvents(got, EventDeletionRequested, EventDeleted) {
+		t.Errorf("events = %v", got)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, store, publisher, _ := newTestDeletions(time.Hour)
+	ctx := context.Background()
+
+	if err := s.Cancel(ctx, "alice"); !errors.Is(err, ErrDeletionNotFound) {
+		t.Errorf("Cancel() without a deletion: error = %v, want %v", err, ErrDeletionNotFound)
+	}
+
+	if _, err := s.Schedule(ctx, Deletion{Subject: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Cancel(ctx, "alice"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if _, err := s.Status(ctx, "alice"); !errors.Is(err, ErrDeletionNotFound) {
+		t.Errorf("Status() after Cancel: error = %v, want %v", err, ErrDeletionNotFound)
+	}
+	if got := publisher.published(); !equalEvents(got, EventDeletionRequested, EventDeletionCancelled) {
+		t.Errorf("events = %v", got)
+	}
+
+	// Once the user is gone there is nothing left to cancel
+	store.Add(ctx, Deletion{Subject: "bob", UserDeleted: true})
+	if err := s.Cancel(ctx, "bob"); !errors.Is(err, ErrDeletionNotFound) {
+		t.Errorf("Cancel() after the user was deleted: error = %v, want %v", err, ErrDeletionNotFound)
+	}
+}
+
+func TestCompleteRetries(t *testing.T) {
+	s, store, publisher, directory := newTestDeletions(time.Hour)
+	ctx := context.Background()
+
+	d, err := s.Schedule(ctx, Deletion{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The identity provider is down: nothing changes
+	directory.failing = true
+	if err := s.complete(ctx, *d); err == nil {
+		t.Fatal("complete() = nil with the identity provider down")
+	}
+	if stored, _ := store.Get(ctx, "alice"); stored == nil || stored.UserDeleted {
+		t.Fatalf("stored deletion = %+v, want it pending", stored)
+	}
+
+	// The user is deleted but the event can't be published
+	directory.failing, publisher.failing = false, true
+	if err := s.complete(ctx, *d); err == nil {
+		t.Fatal("complete() = nil with the publisher down")
+	}
+	stored, err := store.Get(ctx, "alice")
+	if err != nil || !stored.UserDeleted {
+		t.Fatalf("stored deletion = %+v, %v; want it kept with UserDeleted", stored, err)
+	}
+
+	// The retry only publishes
+	publisher.failing = false
+	if err := s.complete(ctx, *stored); err != nil {
+		t.Fatalf("retried complete(): %v", err)
+	}
+	if directory.deleted != 1 {
+		t.Errorf("deleted = %d, want the user deleted once", directory.deleted)
+	}
+	if _, err := store.Get(ctx, "alice"); !errors.Is(err, ErrDeletionNotFound) {
+		t.Errorf("completed deletion is still stored: %v", err)
+	}
+	if got := publisher.published(); !equalEvents(got, EventDeletionRequested, EventDeleted) {
+		t.Errorf("events = %v", got)
+	}
+}
+
+func TestProcessDue(t *testing.T) {
+	s, store, _, directory := newTestDeletions(time.Hour)
+	ctx := context.Background()
+	now := time.Now()
+
+	store.Add(ctx, Deletion{Subject: "due", DeleteAt: now.Add(-time.Minute)})
+	store.Add(ctx, Deletion{Subject: "pending", DeleteAt: now.Add(time.Minute)})
+
+	s.processDue(ctx, now)
+
+	if directory.deleted != 1 {
+		t.Errorf("deleted = %d, want only the due account", directory.deleted)
+	}
+	if _, err := store.Get(ctx, "due"); !errors.Is(err, ErrDeletionNotFound) {
+		t.Errorf("due deletion is still stored: %v", err)
+	}
+	if _, err := store.Get(ctx, "pending"); err != nil {
+		t.Errorf("pending deletion was removed: %v", err)
+	}
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	var received Event
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p := NewWebhookPublisher(server.URL)
+	event := NewEvent(EventDeleted, Deletion{Subject: "alice"})
+
+	if err := p.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if received.ID != event.ID || received.Type != EventDeleted || received.Data.Subject != "alice" || received.Source != "auth-service" {
+		t.Errorf("webhook received %+v", received)
+	}
+
+	status = http.StatusInternalServerError
+	if err := p.Publish(context.Background(), event); err == nil {
+		t.Error("Publish() = nil for a failing webhook")
+	}
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	deletions, _, _, _ := newTestDeletions(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		deletions.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() kept going after its context was cancelled")
+	}
+}
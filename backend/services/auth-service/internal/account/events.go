@@ -0,0 +1,98 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ec-recommend/backend/shared/go/observability"
+	"github.com/google/uuid"
+)
+
+// Account lifecycle event types, named like the other user events
+const (
+	EventDeletionRequested = "user.deletion_requested"
+	EventDeletionCancelled = "user.deletion_cancelled"
+	EventDeleted           = "user.deleted"
+)
+
+const eventSource = "auth-service"
+
+// Event is the envelope shared by the platform's asynchronous events
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"`
+	Data      Deletion  `json:"data"`
+}
+
+func NewEvent(eventType string, d Deletion) Event {
+	return Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Source:    eventSource,
+		Data:      d,
+	}
+}
+
+// Publisher delivers account events to the services that hold user data.
+// On user.deleted they remove or anonymize the user's rows.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// LogPublisher writes events to the log as JSON lines, for development and
+// for log-based pipelines
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Printf("account event: %s", data)
+	return nil
+}
+
+// WebhookPublisher POSTs each event as JSON to a URL
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookPublisher(url string) *WebhookPublisher {
+	return &WebhookPublisher{
+		url:    url,
+		client: observability.HTTPClient(10 * time.Second),
+	}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("event webhook returned %s", resp.Status)
+	}
+	return nil
+}
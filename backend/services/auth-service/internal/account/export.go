@@ -0,0 +1,139 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// Records is a user's data held outside the identity provider. Each entry is
+// a row as JSON, so the export follows the schema without mirroring it here.
+type Records struct {
+	Account       json.RawMessage   `json:"account"`
+	Addresses     []json.RawMessage `json:"addresses"`
+	Orders        []json.RawMessage `json:"orders"`
+	Reviews       []json.RawMessage `json:"reviews"`
+	SellerReviews []json.RawMessage `json:"sellerReviews"`
+	Favorites     []json.RawMessage `json:"favorites"`
+}
+
+// DataSource gathers a user's records by their Cognito subject
+type DataSource interface {
+	UserRecords(ctx context.Context, subject string) (*Records, error)
+}
+
+// exportQueries select each section as JSON rows. Orders carry their items
+// and reviews their product name, so the archive reads on its own.
+var exportQueries = []struct {
+	name  string
+	query string
+}{
+	{"addresses", `
+		SELECT row_to_json(a) FROM addresses a
+		JOIN users u ON u.id = a.user_id
+		WHERE u.cognito_user_id = $1
+		ORDER BY a.created_at`},
+	{"orders", `
+		SELECT to_jsonb(o) || jsonb_build_object('items', COALESCE(
+			(SELECT jsonb_agg(to_jsonb(i) ORDER BY i.created_at) FROM order_items i WHERE i.order_id = o.id),
+			'[]'::jsonb))
+		FROM orders o
+		JOIN users u ON u.id = o.user_id
+		WHERE u.cognito_user_id = $1
+		ORDER BY o.created_at`},
+	{"reviews", `
+		SELECT to_jsonb(r) || jsonb_build_object('product_name', p.name)
+		FROM reviews r
+		JOIN users u ON u.id = r.user_id
+		JOIN products p ON p.id = r.product_id
+		WHERE u.cognito_user_id = $1
+		ORDER BY r.created_at`},
+	{"sellerReviews", `
+		SELECT row_to_json(r) FROM seller_reviews r
+		JOIN users u ON u.id = r.user_id
+		WHERE u.cognito_user_id = $1
+		ORDER BY r.created_at`},
+	{"favorites", `
+		SELECT to_jsonb(f) || jsonb_build_object('product_name', p.name)
+		FROM favorites f
+		JOIN users u ON u.id = f.user_id
+		JOIN products p ON p.id = f.product_id
+		WHERE u.cognito_user_id = $1
+		ORDER BY f.created_at`},
+}
+
+const accountQuery = `
+	SELECT to_jsonb(u) || jsonb_build_object('profile', to_jsonb(p) - 'user_id')
+	FROM users u
+	LEFT JOIN user_profiles p ON p.user_id = u.id
+	WHERE u.cognito_user_id = $1`
+
+// PostgresDataSource reads records from the platform's PostgreSQL database
+type PostgresDataSource struct {
+	db *sql.DB
+}
+
+func NewPostgresDataSource(databaseURL string) (*PostgresDataSource, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	return &PostgresDataSource{db: db}, nil
+}
+
+func (s *PostgresDataSource) UserRecords(ctx context.Context, subject string) (*Records, error) {
+	records := &Records{}
+
+	var account []byte
+	err := s.db.QueryRowContext(ctx, accountQuery, subject).Scan(&account)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read account: %v", err)
+	}
+	records.Account = account
+
+	sections := map[string]*[]json.RawMessage{
+		"addresses":     &records.Addresses,
+		"orders":        &records.Orders,
+		"reviews":       &records.Reviews,
+		"sellerReviews": &records.SellerReviews,
+		"favorites":     &records.Favorites,
+	}
+
+	for _, q := range exportQueries {
+		rows, err := s.queryJSON(ctx, q.query, subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", q.name, err)
+		}
+		*sections[q.name] = rows
+	}
+
+	return records, nil
+}
+
+func (s *PostgresDataSource) queryJSON(ctx context.Context, query string, args ...any) ([]json.RawMessage, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []json.RawMessage{}
+	for rows.Next() {
+		// Scan into a fresh []byte; the driver may reuse its buffer
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *PostgresDataSource) Close() error {
+	return s.db.Close()
+}
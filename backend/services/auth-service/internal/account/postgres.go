@@ -0,0 +1,142 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// dueLease is how long a due deletion handed to one instance is hidden from
+// the others, so concurrent sweeps don't carry it out twice
+const dueLease = 5 * time.Minute
+
+// deletionColumns are selected in the order scanDeletion reads them
+const deletionColumns = `subject, username, email, COALESCE(pool, ''), requested_at, delete_at, user_deleted`
+
+// PostgresDeletionStore keeps scheduled deletions in the account_deletions
+// table, so they survive restarts for the whole grace period and every
+// instance can report and cancel them
+type PostgresDeletionStore struct {
+	db *sql.DB
+}
+
+func NewPostgresDeletionStore(databaseURL string) (*PostgresDeletionStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	return &PostgresDeletionStore{db: db}, nil
+}
+
+func (s *PostgresDeletionStore) Add(ctx context.Context, d Deletion) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO account_deletions (subject, username, email, pool, requested_at, delete_at, user_deleted)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)`,
+		d.Subject, d.Username, d.Email, d.Pool, d.RequestedAt.UTC(), d.DeleteAt.UTC(), d.UserDeleted)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDeletionScheduled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to schedule deletion: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresDeletionStore) Get(ctx context.Context, subject string) (*Deletion, error) {
+	return scanDeletion(s.db.QueryRowContext(ctx,
+		`SELECT `+deletionColumns+` FROM account_deletions WHERE subject = $1`, subject))
+}
+
+func (s *PostgresDeletionStore) Update(ctx context.Context, d Deletion) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE account_deletions SET
+			username = $2, email = $3, pool = NULLIF($4, ''),
+			requested_at = $5, delete_at = $6, user_deleted = $7
+		WHERE subject = $1`,
+		d.Subject, d.Username, d.Email, d.Pool, d.RequestedAt.UTC(), d.DeleteAt.UTC(), d.UserDeleted)
+	if err != nil {
+		return fmt.Errorf("failed to update deletion: %v", err)
+	}
+	return expectRow(result)
+}
+
+func (s *PostgresDeletionStore) Remove(ctx context.Context, subject string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM account_deletions WHERE subject = $1`, subject)
+	if err != nil {
+		return fmt.Errorf("failed to remove deletion: %v", err)
+	}
+	return expectRow(result)
+}
+
+// Due leases the deletions whose grace period ended by now to the caller.
+// One still leased to another instance is skipped until its lease ends.
+func (s *PostgresDeletionStore) Due(ctx context.Context, now time.Time) ([]Deletion, error) {
+	now = now.UTC()
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE account_deletions SET leased_until = $2
+		WHERE subject IN (
+			SELECT subject FROM account_deletions
+			WHERE delete_at <= $1 AND (leased_until IS NULL OR leased_until <= $1)
+			ORDER BY delete_at
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deletionColumns,
+		now, now.Add(dueLease))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due deletions: %v", err)
+	}
+	defer rows.Close()
+
+	var due []Deletion
+	for rows.Next() {
+		d, err := scanDeletion(rows)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list due deletions: %v", err)
+	}
+
+	// RETURNING doesn't keep the subquery's order
+	sortByDeleteAt(due)
+	return due, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeletion(row rowScanner) (*Deletion, error) {
+	var d Deletion
+	err := row.Scan(&d.Subject, &d.Username, &d.Email, &d.Pool, &d.RequestedAt, &d.DeleteAt, &d.UserDeleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeletionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The columns hold UTC without a zone
+	d.RequestedAt, d.DeleteAt = d.RequestedAt.UTC(), d.DeleteAt.UTC()
+	return &d, nil
+}
+
+func expectRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDeletionNotFound
+	}
+	return nil
+}
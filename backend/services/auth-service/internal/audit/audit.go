@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is one entry in the audit trail
+type Event struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// Actor is the user who acted, when they differ from UserID (an admin)
+	Actor string `json:"actor,omitempty"`
+	// UserID is the user the event is about
+	UserID string `json:"userId,omitempty"`
+	// Email identifies the user when UserID isn't known yet, such as a
+	// failed sign-in. Record replaces it with EmailHash; it is never written.
+	Email     string            `json:"-"`
+	EmailHash string            `json:"emailHash,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"userAgent,omitempty"`
+	Outcome   string            `json:"outcome"`
+	Reason    string            `json:"reason,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+type clientKey struct{}
+
+type client struct {
+	ip, userAgent string
+}
+
+// WithClient attaches the requesting client's IP and user agent to ctx.
+// Record copies them into events that don't set their own.
+func WithClient(ctx context.Context, ip, userAgent string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client{ip: ip, userAgent: userAgent})
+}
+
+// Sink stores or forwards audit events
+type Sink interface {
+	Write(ctx context.Context, event Event) error
+}
+
+// ErrSearchUnavailable is returned by Search when no sink can be searched
+var ErrSearchUnavailable = errors.New("no searchable audit sink is configured")
+
+// Query selects a user's recent events. Events match UserID or the hash of
+// Email; at least one is required.
+type Query struct {
+	UserID string
+	Email  string
+	// EmailHash is set from Email by Recorder.Search
+	EmailHash string
+	Type      string
+	Since     time.Time
+	Limit     int
+}
+
+// matches reports whether event satisfies q
+func (q Query) matches(event Event) bool {
+	if (q.UserID == "" || event.UserID != q.UserID) && (q.EmailHash == "" || event.EmailHash != q.EmailHash) {
+		return false
+	}
+	if q.Type != "" && event.Type != q.Type {
+		return false
+	}
+	return q.Since.IsZero() || !event.Time.Before(q.Since)
+}
+
+// Searcher is a Sink that can answer queries, newest event first
+type Searcher interface {
+	Search(ctx context.Context, q Query) ([]Event, error)
+}
+
+// Recorder sends events to every sink. A failing sink is logged and does
+// not fail the action being audited.
+type Recorder struct {
+	emailKey []byte
+	sinks    []Sink
+}
+
+// NewRecorder records to sinks. Emails are hashed with HMAC-SHA256 under
+// emailKey, so they can be searched for without being stored.
+func NewRecorder(emailKey []byte, sinks ...Sink) *Recorder {
+	return &Recorder{emailKey: emailKey, sinks: sinks}
+}
+
+// HashEmail returns the pseudonym events carry in place of email
+func (r *Recorder) HashEmail(email string) string {
+	mac := hmac.New(sha256.New, r.emailKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Record stamps event with an ID, time and client and writes it to the sinks
+func (r *Recorder) Record(ctx context.Context, event Event) {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Email != "" {
+		event.EmailHash = r.HashEmail(event.Email)
+		event.Email = ""
+	}
+	if cl, ok := ctx.Value(clientKey{}).(client); ok {
+		if event.IP == "" {
+			event.IP = cl.ip
+		}
+		if event.UserAgent == "" {
+			event.UserAgent = cl.userAgent
+		}
+	}
+
+	for _, sink := range r.sinks {
+		if err := sink.Write(ctx, event); err != nil {
+			log.Printf("Failed to write audit event %s (%s): %v", event.ID, event.Type, err)
+		}
+	}
+}
+
+// closer is a Sink with work to finish on shutdown
+type closer interface {
+	Close(ctx context.Context) error
+}
+
+// Close closes the sinks that need it, e.g. to send buffered events
+func (r *Recorder) Close(ctx context.Context) error {
+	var errs []error
+	for _, sink := range r.sinks {
+		if c, ok := sink.(closer); ok {
+			if err := c.Close(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Search queries the first searchable sink
+func (r *Recorder) Search(ctx context.Context, q Query) ([]Event, error) {
+	if q.Email != "" {
+		q.EmailHash = r.HashEmail(q.Email)
+	}
+
+	for _, sink := range r.sinks {
+		if searcher, ok := sink.(Searcher); ok {
+			return searcher.Search(ctx, q)
+		}
+	}
+	return nil, ErrSearchUnavailable
+}
+
+// StreamSink writes events as JSON lines
+type StreamSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutSink writes events to stdout, for log collectors to pick up
+func NewStdoutSink() *StreamSink {
+	return &StreamSink{w: os.Stdout}
+}
+
+// NewFileSink appends events to the file at path, creating it (and its
+// directory) if needed
+func NewFileSink(path string) (*StreamSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamSink{w: f}, nil
+}
+
+func (s *StreamSink) Write(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
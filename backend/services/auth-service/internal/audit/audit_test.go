@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryMatches(t *testing.T) {
+	now := time.Now()
+	event := Event{Type: "auth.signin", UserID: "user-1", EmailHash: "hash-1", Time: now}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  bool
+	}{
+		{"user", Query{UserID: "user-1"}, true},
+		{"email hash", Query{EmailHash: "hash-1"}, true},
+		{"either identifier", Query{UserID: "user-2", EmailHash: "hash-1"}, true},
+		{"other user", Query{UserID: "user-2"}, false},
+		{"other email", Query{EmailHash: "hash-2"}, false},
+		{"no identifier", Query{Type: "auth.signin"}, false},
+		{"type", Query{UserID: "user-1", Type: "auth.signin"}, true},
+		{"other type", Query{UserID: "user-1", Type: "auth.signout"}, false},
+		{"since before", Query{UserID: "user-1", Since: now.Add(-time.Minute)}, true},
+		{"since at", Query{UserID: "user-1", Since: now}, true},
+		{"since after", Query{UserID: "user-1", Since: now.Add(time.Minute)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.matches(event); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	store := NewMemoryStore(10)
+	r := NewRecorder([]byte("key"), store)
+
+	ctx := WithClient(context.Background(), "192.0.2.1", "test-agent")
+	r.Record(ctx, Event{Type: "auth.signin", Email: " Alice@Example.com", Outcome: OutcomeFailure})
+
+	events, err := r.Search(context.Background(), Query{Email: "alice@example.com", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Search() = %d events, want the one recorded", len(events))
+	}
+
+	event := events[0]
+	if event.ID == "" || event.Time.IsZero() {
+		t.Errorf("event = %+v, want an ID and time", event)
+	}
+	if event.Email != "" || event.EmailHash != r.HashEmail("alice@example.com") {
+		t.Errorf("event email = %q, hash %q; want only the hash", event.Email, event.EmailHash)
+	}
+	if event.IP != "192.0.2.1" || event.UserAgent != "test-agent" {
+		t.Errorf("event client = %s %s, want the context's client", event.IP, event.UserAgent)
+	}
+
+	// Hashes are keyed, so another key can't find the events
+	if other := NewRecorder([]byte("other")); other.HashEmail("alice@example.com") == event.EmailHash {
+		t.Error("HashEmail() ignores the key")
+	}
+
+	if _, err := NewRecorder(nil, NewStdoutSink()).Search(ctx, Query{UserID: "user-1"}); !errors.Is(err, ErrSearchUnavailable) {
+		t.Errorf("Search() without a searchable sink error = %v, want %v", err, ErrSearchUnavailable)
+	}
+}
@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ec-recommend/backend/shared/go/observability"
+)
+
+const (
+	sqsBufferSize = 1000
+	// sqsBatchSize is the most messages SendMessageBatch accepts
+	sqsBatchSize     = 10
+	sqsFlushInterval = time.Second
+	sqsSendTimeout   = 10 * time.Second
+)
+
+var (
+	// errSQSBufferFull is returned by Write while the queue can't keep up
+	errSQSBufferFull = errors.New("audit queue buffer is full, event dropped")
+	// errSQSClosed is returned by Write once Close was called
+	errSQSClosed = errors.New("audit queue is closed, event dropped")
+)
+
+// sqsAPI is the part of the SQS client the sink uses
+type sqsAPI interface {
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+// SQSSink publishes events to an SQS queue for security tooling to consume.
+// Write only buffers the event; batches are sent in the background so a
+// slow queue never holds up a sign-in. Close sends what is still buffered.
+type SQSSink struct {
+	client   sqsAPI
+	queueURL string
+	events   chan Event
+
+	// closing stops run, which closes done once the buffer is sent
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewSQSSink publishes to queueURL. endpoint overrides the SQS endpoint,
+// for LocalStack.
+func NewSQSSink(queueURL, endpoint string) (*SQSSink, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config: %v", err)
+	}
+	observability.InstrumentAWS(&cfg)
+
+	client := sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	return newSQSSink(client, queueURL), nil
+}
+
+func newSQSSink(client sqsAPI, queueURL string) *SQSSink {
+	s := &SQSSink{
+		client:   client,
+		queueURL: queueURL,
+		events:   make(chan Event, sqsBufferSize),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+
+	return s
+}
+
+func (s *SQSSink) Write(ctx context.Context, event Event) error {
+	select {
+	case <-s.closing:
+		return errSQSClosed
+	default:
+	}
+
+	select {
+	case s.events <- event:
+		return nil
+	default:
+		return errSQSBufferFull
+	}
+}
+
+// Close stops accepting events and sends the buffered ones, waiting until
+// they are sent or ctx is done
+func (s *SQSSink) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("audit events left unsent: %w", ctx.Err())
+	}
+}
+
+// run sends buffered events whenever a batch fills or the flush interval
+// passes, until the sink is closed
+func (s *SQSSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(sqsFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, sqsBatchSize)
+	for {
+		select {
+		case event := <-s.events:
+			batch = append(batch, event)
+			if len(batch) < sqsBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-s.closing:
+			s.drain(batch)
+			return
+		}
+
+		s.send(batch)
+		batch = batch[:0]
+	}
+}
+
+// drain sends batch and every event still buffered
+func (s *SQSSink) drain(batch []Event) {
+	for {
+		select {
+		case event := <-s.events:
+			batch = append(batch, event)
+			if len(batch) < sqsBatchSize {
+				continue
+			}
+		default:
+			if len(batch) > 0 {
+				s.send(batch)
+			}
+			return
+		}
+
+		s.send(batch)
+		batch = batch[:0]
+	}
+}
+
+func (s *SQSSink) send(batch []Event) {
+	entries := make([]types.SendMessageBatchRequestEntry, 0, len(batch))
+	for i, event := range batch {
+		body, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Failed to encode audit event %s: %v", event.ID, err)
+			continue
+		}
+
+		entries = append(entries, types.SendMessageBatchRequestEntry{
+			Id:          aws.String(strconv.Itoa(i)),
+			MessageBody: aws.String(string(body)),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"type": {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+			},
+		})
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sqsSendTimeout)
+	defer cancel()
+
+	out, err := s.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(s.queueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		log.Printf("Failed to publish %d audit events: %v", len(entries), err)
+		return
+	}
+	for _, failed := range out.Failed {
+		// Entry IDs are indexes into batch
+		i, _ := strconv.Atoi(aws.ToString(failed.Id))
+		log.Printf("Failed to publish audit event %s: %s", batch[i].ID, aws.ToString(failed.Message))
+	}
+}
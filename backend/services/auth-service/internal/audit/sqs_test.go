@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// fakeSQS keeps the batches sent to it
+type fakeSQS struct {
+	mu      sync.Mutex
+	batches []int
+}
+
+func (f *fakeSQS) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, len(params.Entries))
+	return &sqs.SendMessageBatchOutput{}, nil
+}
+
+func TestSQSSinkCloseSendsBuffered(t *testing.T) {
+	client := &fakeSQS{}
+	sink := newSQSSink(client, "queue")
+
+	ctx := context.Background()
+	for i := 0; i < 25; i++ {
+		if err := sink.Write(ctx, Event{ID: fmt.Sprint(i), Type: "auth.signin"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	client.mu.Lock()
+	sent := 0
+	for _, n := range client.batches {
+		if n > sqsBatchSize {
+			t.Errorf("batch of %d events, want at most %d", n, sqsBatchSize)
+		}
+		sent += n
+	}
+	client.mu.Unlock()
+	if sent != 25 {
+		t.Errorf("sent %d events, want 25", sent)
+	}
+
+	if err := sink.Write(ctx, Event{ID: "late"}); err != errSQSClosed {
+		t.Errorf("Write() after Close = %v, want %v", err, errSQSClosed)
+	}
+	if err := sink.Close(ctx); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MemoryStore keeps the most recent events in process so they can be
+// searched without Redis. Only the instance that recorded an event sees it.
+type MemoryStore struct {
+	mu     sync.Mutex
+	events []Event
+	next   int
+	full   bool
+}
+
+// NewMemoryStore keeps the last capacity events
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{events: make([]Event, capacity)}
+}
+
+func (m *MemoryStore) Write(ctx context.Context, event Event) error {
+	if len(m.events) == 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events[m.next] = event
+	m.next = (m.next + 1) % len(m.events)
+	m.full = m.full || m.next == 0
+	return nil
+}
+
+func (m *MemoryStore) Search(ctx context.Context, q Query) ([]Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := m.next
+	if m.full {
+		count = len(m.events)
+	}
+
+	events := []Event{}
+	for i := 1; i <= count && len(events) < q.Limit; i++ {
+		event := m.events[(m.next-i+len(m.events))%len(m.events)]
+		if q.matches(event) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// Redis keys of the per-user event lists, newest first
+const (
+	redisUserPrefix  = "auth:audit:user:"
+	redisEmailPrefix = "auth:audit:email:"
+)
+
+// RedisStore keeps each user's recent events in Redis lists, one keyed by
+// user ID and one by email hash, so every instance can search them. Lists
+// are capped at perUser events and expire retention after the last one.
+type RedisStore struct {
+	client    *redis.Client
+	perUser   int64
+	retention time.Duration
+}
+
+// NewRedisStore connects to the Redis server at url (redis://...)
+func NewRedisStore(url string, perUser int, retention time.Duration) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %v", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return &RedisStore{client: client, perUser: int64(perUser), retention: retention}, nil
+}
+
+func (s *RedisStore) Write(ctx context.Context, event Event) error {
+	keys := s.keys(event.UserID, event.EmailHash)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.LPush(ctx, key, data)
+			pipe.LTrim(ctx, key, 0, s.perUser-1)
+			pipe.Expire(ctx, key, s.retention)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Search(ctx context.Context, q Query) ([]Event, error) {
+	seen := make(map[string]bool)
+	events := []Event{}
+
+	for _, key := range s.keys(q.UserID, q.EmailHash) {
+		values, err := s.client.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, value := range values {
+			var event Event
+			if err := json.Unmarshal([]byte(value), &event); err != nil {
+				return nil, err
+			}
+			if !seen[event.ID] && q.matches(event) {
+				seen[event.ID] = true
+				events = append(events, event)
+			}
+		}
+	}
+
+	// Events found under both keys are merged, so restore the order
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.After(events[j].Time)
+	})
+	if len(events) > q.Limit {
+		events = events[:q.Limit]
+	}
+	return events, nil
+}
+
+func (s *RedisStore) keys(userID, emailHash string) []string {
+	var keys []string
+	if userID != "" {
+		keys = append(keys, redisUserPrefix+userID)
+	}
+	if emailHash != "" {
+		keys = append(keys, redisEmailPrefix+emailHash)
+	}
+	return keys
+}
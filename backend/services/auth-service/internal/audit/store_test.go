@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// events returns n events about userID, a second apart starting at start
+func events(userID string, n int, start time.Time) []Event {
+	var list []Event
+	for i := 0; i < n; i++ {
+		list = append(list, Event{
+			ID:     fmt.Sprintf("%s-%d", userID, i),
+			Type:   "auth.signin",
+			UserID: userID,
+			Time:   start.Add(time.Duration(i) * time.Second),
+		})
+	}
+	return list
+}
+
+func ids(events []Event) []string {
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func equalIDs(got []Event, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].ID != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryStore(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name     string
+		capacity int
+		written  int
+		limit    int
+		want     []string
+	}{
+		{"below capacity", 5, 3, 10, []string{"alice-2", "alice-1", "alice-0"}},
+		{"at capacity", 3, 3, 10, []string{"alice-2", "alice-1", "alice-0"}},
+		{"oldest overwritten", 3, 5, 10, []string{"alice-4", "alice-3", "alice-2"}},
+		{"limit", 5, 5, 2, []string{"alice-4", "alice-3"}},
+		{"no capacity", 0, 3, 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemoryStore(tt.capacity)
+			ctx := context.Background()
+
+			m.Write(ctx, Event{ID: "bob-0", UserID: "bob", Time: start})
+			for _, event := range events("alice", tt.written, start) {
+				m.Write(ctx, event)
+			}
+
+			got, err := m.Search(ctx, Query{UserID: "alice", Limit: tt.limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalIDs(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", ids(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	s, err := NewRedisStore("redis://"+server.Addr(), 3, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	ctx := context.Background()
+	start := time.Now().UTC()
+
+	for _, event := range events("alice", 5, start) {
+		if err := s.Write(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A failed sign-in before the user was known, under the email hash only
+	s.Write(ctx, Event{ID: "email-0", Type: "auth.signin", EmailHash: "hash-1", Time: start.Add(2500 * time.Millisecond)})
+	// Events with no user are not kept
+	s.Write(ctx, Event{ID: "anonymous", Type: "auth.signin", Time: start})
+
+	// Each list keeps the newest perUser events and expires after retention
+	if n, _ := server.List(redisUserPrefix + "alice"); len(n) != 3 {
+		t.Errorf("user list length = %d, want 3", len(n))
+	}
+	if ttl := server.TTL(redisUserPrefix + "alice"); ttl != time.Hour {
+		t.Errorf("user list TTL = %v, want %v", ttl, time.Hour)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"user", Query{UserID: "alice", Limit: 10}, []string{"alice-4", "alice-3", "alice-2"}},
+		{"merged with email", Query{UserID: "alice", EmailHash: "hash-1", Limit: 10}, []string{"alice-4", "alice-3", "email-0", "alice-2"}},
+		{"limit", Query{UserID: "alice", EmailHash: "hash-1", Limit: 2}, []string{"alice-4", "alice-3"}},
+		{"since", Query{UserID: "alice", Since: start.Add(3 * time.Second), Limit: 10}, []string{"alice-4", "alice-3"}},
+		{"unknown user", Query{UserID: "bob", Limit: 10}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Search(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalIDs(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", ids(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package captcha verifies CAPTCHA responses with a siteverify endpoint, the
+// API shared by reCAPTCHA, hCaptcha and Cloudflare Turnstile.
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ec-recommend/backend/shared/go/observability"
+)
+
+// DefaultVerifyURL is reCAPTCHA's siteverify endpoint
+const DefaultVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+
+// ErrMissingToken is returned by Verify for an empty response token
+var ErrMissingToken = errors.New("captcha token is required")
+
+// Verifier checks CAPTCHA response tokens with the provider
+type Verifier struct {
+	verifyURL string
+	secret    string
+	client    *http.Client
+}
+
+// NewVerifier verifies tokens at verifyURL with the site's secret
+func NewVerifier(verifyURL, secret string) *Verifier {
+	return &Verifier{
+		verifyURL: verifyURL,
+		secret:    secret,
+		client:    observability.HTTPClient(10 * time.Second),
+	}
+}
+
+// Verify reports whether token is a valid, unused CAPTCHA response from the
+// client at ip. An error means the provider couldn't be asked.
+func (v *Verifier) Verify(ctx context.Context, token, ip string) (bool, error) {
+	if token == "" {
+		return false, ErrMissingToken
+	}
+
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if ip != "" {
+		form.Set("remoteip", ip)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("captcha verification failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("captcha verification failed: status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("invalid captcha verification response: %v", err)
+	}
+
+	return result.Success, nil
+}
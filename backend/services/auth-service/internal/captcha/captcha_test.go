@@ -0,0 +1,68 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	var form map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		form = map[string]string{
+			"secret":   r.PostForm.Get("secret"),
+			"response": r.PostForm.Get("response"),
+			"remoteip": r.PostForm.Get("remoteip"),
+		}
+
+		switch form["response"] {
+		case "passed":
+			w.Write([]byte(`{"success": true}`))
+		case "failed":
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		case "garbled":
+			w.Write([]byte(`<html>`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	v := NewVerifier(server.URL, "site-secret")
+
+	tests := []struct {
+		token   string
+		want    bool
+		wantErr bool
+	}{
+		{"passed", true, false},
+		{"failed", false, false},
+		{"garbled", false, true},
+		{"unavailable", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			got, err := v.Verify(context.Background(), tt.token, "192.0.2.1")
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() = %v, %v; want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+			if form["secret"] != "site-secret" || form["remoteip"] != "192.0.2.1" {
+				t.Errorf("form = %v, want the secret and client IP", form)
+			}
+		})
+	}
+}
+
+func TestVerifyMissingToken(t *testing.T) {
+	v := NewVerifier("http://127.0.0.1:0", "site-secret")
+
+	if passed, err := v.Verify(context.Background(), "", "192.0.2.1"); passed || !errors.Is(err, ErrMissingToken) {
+		t.Fatalf("Verify() = %v, %v; want false, %v", passed, err, ErrMissingToken)
+	}
+}
@@ -0,0 +1,402 @@
+package cognito
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+// AdminDeleteUser deletes a user from the pool by username. Unlike the
+// user's own DeleteUser it needs no access token, so scheduled deletions can
+// run after the user's tokens have expired.
+func (c *Client) AdminDeleteUser(ctx context.Context, username string) error {
+	input := &cognitoidentityprovider.AdminDeleteUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	}
+
+	_, err := c.cognitoClient.AdminDeleteUser(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// FindUserByEmail looks a user up by email attribute. It returns
+// ErrUserNotFound when no user has the email.
+func (c *Client) FindUserByEmail(ctx context.Context, email string) (*User, error) {
+	input := &cognitoidentityprovider.ListUsersInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Filter:     aws.String(fmt.Sprintf("email = %q", email)),
+		Limit:      aws.Int32(1),
+	}
+
+	result, err := c.cognitoClient.ListUsers(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", mapError(err))
+	}
+
+	if len(result.Users) == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	return userFromAttributes(aws.ToString(result.Users[0].Username), result.Users[0].Attributes), nil
+}
+
+// AdminCreateUser creates a confirmed user with a verified email and no
+// usable password, for accounts that sign in through a social provider.
+// Cognito sends no invitation.
+func (c *Client) AdminCreateUser(ctx context.Context, email, name string) (*User, error) {
+	attributes := []types.AttributeType{
+		{Name: aws.String("email"), Value: aws.String(email)},
+		{Name: aws.String("email_verified"), Value: aws.String("true")},
+	}
+	if name != "" {
+		attributes = append(attributes, types.AttributeType{Name: aws.String("name"), Value: aws.String(name)})
+	}
+
+	result, err := c.cognitoClient.AdminCreateUser(ctx, &cognitoidentityprovider.AdminCreateUserInput{
+		UserPoolId:     aws.String(c.userPoolID),
+		Username:       aws.String(email),
+		UserAttributes: attributes,
+		MessageAction:  types.MessageActionTypeSuppress,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", mapError(err))
+	}
+
+	username := aws.ToString(result.User.Username)
+
+	// A permanent random password moves the user out of
+	// FORCE_CHANGE_PASSWORD so custom auth can sign them in
+	password, err := randomPassword()
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.cognitoClient.AdminSetUserPassword(ctx, &cognitoidentityprovider.AdminSetUserPasswordInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+		Password:   aws.String(password),
+		Permanent:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm user: %w", mapError(err))
+	}
+
+	return userFromAttributes(username, result.User.Attributes), nil
+}
+
+// MaxListUsersLimit is the largest page ListUsers and ListUsersInGroup return
+const MaxListUsersLimit = 60
+
+// UserRecord is a user as administrators see them
+type UserRecord struct {
+	User
+	Subject   string    `json:"sub"`
+	Enabled   bool      `json:"enabled"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Groups is only filled in by AdminGetUser
+	Groups []string `json:"groups,omitempty"`
+}
+
+// UserFilter narrows ListUsers. Search matches the start of the email.
+type UserFilter struct {
+	Search string
+	Group  string
+	// Limit is the page size, up to MaxListUsersLimit
+	Limit int
+	// NextToken continues from the page that returned it
+	NextToken string
+}
+
+// UserPage is one page of users. NextToken is empty on the last page.
+type UserPage struct {
+	Users     []UserRecord
+	NextToken string
+}
+
+// ListUsers returns a page of users matching filter. Cognito pages with
+// opaque tokens in its own order and has no count, so callers walk pages
+// with NextToken rather than jumping to one.
+func (c *Client) ListUsers(ctx context.Context, filter UserFilter) (*UserPage, error) {
+	if filter.Group != "" {
+		return c.listUsersInGroup(ctx, filter)
+	}
+
+	input := &cognitoidentityprovider.ListUsersInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Limit:      aws.Int32(listUsersLimit(filter.Limit)),
+	}
+	if filter.Search != "" {
+		input.Filter = aws.String(fmt.Sprintf("email ^= %q", filter.Search))
+	}
+	if filter.NextToken != "" {
+		input.PaginationToken = aws.String(filter.NextToken)
+	}
+
+	result, err := c.cognitoClient.ListUsers(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", mapError(err))
+	}
+
+	page := &UserPage{Users: []UserRecord{}, NextToken: aws.ToString(result.PaginationToken)}
+	for _, u := range result.Users {
+		page.Users = append(page.Users, userRecord(u))
+	}
+	return page, nil
+}
+
+// listUsersInGroup lists a page of a group's members. Cognito can't filter
+// them, so Search is applied to each page here and a page may come back
+// short, or empty, with a NextToken still to follow.
+func (c *Client) listUsersInGroup(ctx context.Context, filter UserFilter) (*UserPage, error) {
+	input := &cognitoidentityprovider.ListUsersInGroupInput{
+		UserPoolId: aws.String(c.userPoolID),
+		GroupName:  aws.String(filter.Group),
+		Limit:      aws.Int32(listUsersLimit(filter.Limit)),
+	}
+	if filter.NextToken != "" {
+		input.NextToken = aws.String(filter.NextToken)
+	}
+
+	result, err := c.cognitoClient.ListUsersInGroup(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", mapError(err))
+	}
+
+	search := strings.ToLower(filter.Search)
+
+	page := &UserPage{Users: []UserRecord{}, NextToken: aws.ToString(result.NextToken)}
+	for _, u := range result.Users {
+		record := userRecord(u)
+		if strings.HasPrefix(strings.ToLower(record.Email), search) {
+			page.Users = append(page.Users, record)
+		}
+	}
+	return page, nil
+}
+
+// listUsersLimit clamps a requested page size to what Cognito accepts
+func listUsersLimit(limit int) int32 {
+	if limit < 1 || limit > MaxListUsersLimit {
+		return MaxListUsersLimit
+	}
+	return int32(limit)
+}
+
+// AdminGetUser returns a user and the groups they belong to
+func (c *Client) AdminGetUser(ctx context.Context, username string) (*UserRecord, error) {
+	result, err := c.cognitoClient.AdminGetUser(ctx, &cognitoidentityprovider.AdminGetUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", mapError(err))
+	}
+
+	record := userRecord(types.UserType{
+		Username:             result.Username,
+		Attributes:           result.UserAttributes,
+		Enabled:              result.Enabled,
+		UserStatus:           result.UserStatus,
+		UserCreateDate:       result.UserCreateDate,
+		UserLastModifiedDate: result.UserLastModifiedDate,
+	})
+
+	groups := &cognitoidentityprovider.AdminListGroupsForUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	}
+	for {
+		page, err := c.cognitoClient.AdminListGroupsForUser(ctx, groups)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list groups: %w", mapError(err))
+		}
+
+		for _, g := range page.Groups {
+			record.Groups = append(record.Groups, aws.ToString(g.GroupName))
+		}
+
+		if page.NextToken == nil {
+			return &record, nil
+		}
+		groups.NextToken = page.NextToken
+	}
+}
+
+// AdminSetUserEnabled enables or disables a user. Disabled users can't sign
+// in or refresh, but tokens already issued stay valid until they expire.
+func (c *Client) AdminSetUserEnabled(ctx context.Context, username string, enabled bool) error {
+	var err error
+	if enabled {
+		_, err = c.cognitoClient.AdminEnableUser(ctx, &cognitoidentityprovider.AdminEnableUserInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(username),
+		})
+	} else {
+		_, err = c.cognitoClient.AdminDisableUser(ctx, &cognitoidentityprovider.AdminDisableUserInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(username),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// AdminAddUserToGroup adds a user to a group. The change shows up in
+// cognito:groups from the user's next token.
+func (c *Client) AdminAddUserToGroup(ctx context.Context, username, group string) error {
+	_, err := c.cognitoClient.AdminAddUserToGroup(ctx, &cognitoidentityprovider.AdminAddUserToGroupInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+		GroupName:  aws.String(group),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add user to group: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// AdminRemoveUserFromGroup removes a user from a group
+func (c *Client) AdminRemoveUserFromGroup(ctx context.Context, username, group string) error {
+	_, err := c.cognitoClient.AdminRemoveUserFromGroup(ctx, &cognitoidentityprovider.AdminRemoveUserFromGroupInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+		GroupName:  aws.String(group),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove user from group: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// AdminResetUserPassword invalidates a user's password and emails them a
+// reset code. Sign-in fails with ErrPasswordResetRequired until they use it.
+func (c *Client) AdminResetUserPassword(ctx context.Context, username string) error {
+	_, err := c.cognitoClient.AdminResetUserPassword(ctx, &cognitoidentityprovider.AdminResetUserPasswordInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// AdminUserGlobalSignOut revokes all of a user's refresh tokens
+func (c *Client) AdminUserGlobalSignOut(ctx context.Context, username string) error {
+	_, err := c.cognitoClient.AdminUserGlobalSignOut(ctx, &cognitoidentityprovider.AdminUserGlobalSignOutInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sign user out: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// AdminUpdateUserAttributes sets attributes on a user, including ones the
+// app client may not write, such as custom:seller_id. Values are taken as
+// verified; nothing is sent to the user.
+func (c *Client) AdminUpdateUserAttributes(ctx context.Context, username string, attributes map[string]string) error {
+	input := &cognitoidentityprovider.AdminUpdateUserAttributesInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	}
+	for name, value := range attributes {
+		input.UserAttributes = append(input.UserAttributes, types.AttributeType{
+			Name:  aws.String(name),
+			Value: aws.String(value),
+		})
+	}
+
+	_, err := c.cognitoClient.AdminUpdateUserAttributes(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to update user attributes: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// AdminDeleteUserAttributes removes attributes from a user
+func (c *Client) AdminDeleteUserAttributes(ctx context.Context, username string, names []string) error {
+	_, err := c.cognitoClient.AdminDeleteUserAttributes(ctx, &cognitoidentityprovider.AdminDeleteUserAttributesInput{
+		UserPoolId:         aws.String(c.userPoolID),
+		Username:           aws.String(username),
+		UserAttributeNames: names,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete user attributes: %w", mapError(err))
+	}
+
+	return nil
+}
+
+func userRecord(u types.UserType) UserRecord {
+	record := UserRecord{
+		User:      *userFromAttributes(aws.ToString(u.Username), u.Attributes),
+		Enabled:   u.Enabled,
+		Status:    string(u.UserStatus),
+		CreatedAt: aws.ToTime(u.UserCreateDate),
+		UpdatedAt: aws.ToTime(u.UserLastModifiedDate),
+	}
+
+	record.Subject = record.Attributes["sub"]
+	if record.Subject == "" {
+		record.Subject = record.ID
+	}
+
+	return record
+}
+
+func userFromAttributes(username string, attributes []types.AttributeType) *User {
+	user := &User{
+		ID:         username,
+		Attributes: make(map[string]string),
+	}
+
+	for _, attr := range attributes {
+		key := aws.ToString(attr.Name)
+		value := aws.ToString(attr.Value)
+
+		switch key {
+		case "email":
+			user.Email = value
+		case "email_verified":
+			user.EmailVerified = value == "true"
+		case "name":
+			user.Name = value
+		default:
+			user.Attributes[key] = value
+		}
+	}
+
+	return user
+}
+
+// randomPassword returns a password no one knows that satisfies the
+// default pool policy (upper, lower, digit and symbol)
+func randomPassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate password: %v", err)
+	}
+	return "Aa1!" + base64.RawURLEncoding.EncodeToString(b), nil
+}
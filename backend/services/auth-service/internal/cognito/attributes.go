@@ -0,0 +1,54 @@
+package cognito
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+// UpdateUserAttributes updates the signed-in user's attributes. It returns
+// the attributes that now await a verification code, such as a new email.
+func (c *Client) UpdateUserAttributes(ctx context.Context, accessToken string, attributes map[string]string) ([]string, error) {
+	input := &cognitoidentityprovider.UpdateUserAttributesInput{
+		AccessToken: aws.String(accessToken),
+	}
+	for name, value := range attributes {
+		input.UserAttributes = append(input.UserAttributes, types.AttributeType{
+			Name:  aws.String(name),
+			Value: aws.String(value),
+		})
+	}
+
+	result, err := c.cognitoClient.UpdateUserAttributes(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update attributes: %w", mapError(err))
+	}
+
+	pending := []string{}
+	for _, delivery := range result.CodeDeliveryDetailsList {
+		if delivery.AttributeName != nil {
+			pending = append(pending, *delivery.AttributeName)
+		}
+	}
+
+	return pending, nil
+}
+
+// VerifyUserAttribute confirms a changed attribute with the code sent to it
+func (c *Client) VerifyUserAttribute(ctx context.Context, accessToken, attribute, code string) error {
+	input := &cognitoidentityprovider.VerifyUserAttributeInput{
+		AccessToken:   aws.String(accessToken),
+		AttributeName: aws.String(attribute),
+		Code:          aws.String(code),
+	}
+
+	_, err := c.cognitoClient.VerifyUserAttribute(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to verify attribute: %w", mapError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+var ErrUnsupportedChallenge = errors.New("unsupported authentication challenge")
+
+// ChallengeNewPasswordRequired is raised for users who must set a password,
+// and any required attributes they lack, before signing in
+const ChallengeNewPasswordRequired = string(types.ChallengeNameTypeNewPasswordRequired)
+
+// Challenge is an additional sign-in step required by Cognito. Session must
+// be sent back with the answer.
+type Challenge struct {
+	ChallengeName string            `json:"challengeName"`
+	Session       string            `json:"session"`
+	Parameters    map[string]string `json:"parameters,omitempty"`
+}
+
+// ChallengeAnswer answers a Challenge returned by SignIn
+type ChallengeAnswer struct {
+	ChallengeName string `json:"challengeName"`
+	Session       string `json:"session"`
+	Email         string `json:"email"`
+	// NewPassword answers NEW_PASSWORD_REQUIRED
+	NewPassword string `json:"newPassword,omitempty"`
+	// Attributes supplies required attributes for NEW_PASSWORD_REQUIRED
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Code answers SOFTWARE_TOKEN_MFA and SMS_MFA
+	Code string `json:"code,omitempty"`
+}
+
+// exposedChallengeParameters are the challenge parameters safe to pass on
+var exposedChallengeParameters = []string{
+	"requiredAttributes",
+	"CODE_DELIVERY_DELIVERY_MEDIUM",
+	"CODE_DELIVERY_DESTINATION",
+}
+
+func newChallenge(name types.ChallengeNameType, session *string, params map[string]string) (*Challenge, error) {
+	switch name {
+	case types.ChallengeNameTypeNewPasswordRequired,
+		types.ChallengeNameTypeSoftwareTokenMfa,
+		types.ChallengeNameTypeSmsMfa:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedChallenge, name)
+	}
+
+	challenge := &Challenge{
+		ChallengeName: string(name),
+		Session:       aws.ToString(session),
+		Parameters:    make(map[string]string),
+	}
+
+	for _, key := range exposedChallengeParameters {
+		if value, ok := params[key]; ok {
+			challenge.Parameters[key] = value
+		}
+	}
+
+	return challenge, nil
+}
+
+// RespondToChallenge answers a sign-in challenge. Cognito may follow one
+// challenge with another (e.g. MFA after a new password), in which case the
+// next Challenge is returned instead of tokens.
+func (c *Client) RespondToChallenge(ctx context.Context, answer ChallengeAnswer) (*AuthResponse, *Challenge, error) {
+	responses := map[string]string{
+		"USERNAME": answer.Email,
+	}
+
+	switch types.ChallengeNameType(answer.ChallengeName) {
+	case types.ChallengeNameTypeNewPasswordRequired:
+		responses["NEW_PASSWORD"] = answer.NewPassword
+		for name, value := range answer.Attributes {
+			responses["userAttributes."+name] = value
+		}
+	case types.ChallengeNameTypeSoftwareTokenMfa:
+		responses["SOFTWARE_TOKEN_MFA_CODE"] = answer.Code
+	case types.ChallengeNameTypeSmsMfa:
+		responses["SMS_MFA_CODE"] = answer.Code
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedChallenge, answer.ChallengeName)
+	}
+
+	input := &cognitoidentityprovider.RespondToAuthChallengeInput{
+		ChallengeName:      types.ChallengeNameType(answer.ChallengeName),
+		ClientId:           aws.String(c.clientID),
+		Session:            aws.String(answer.Session),
+		ChallengeResponses: responses,
+	}
+
+	result, err := c.cognitoClient.RespondToAuthChallenge(ctx, input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to respond to challenge: %w", mapError(err))
+	}
+
+	if result.AuthenticationResult == nil {
+		challenge, err := newChallenge(result.ChallengeName, result.Session, result.ChallengeParameters)
+		return nil, challenge, err
+	}
+
+	authResponse, err := c.authResponse(ctx, result.AuthenticationResult)
+	return authResponse, nil, err
+}
@@ -0,0 +1,56 @@
+package cognito
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+func TestNewChallenge(t *testing.T) {
+	params := map[string]string{
+		"requiredAttributes":            `["userAttributes.name"]`,
+		"CODE_DELIVERY_DESTINATION":     "+*******1234",
+		"USER_ID_FOR_SRP":               "alice",
+		"userAttributes":                `{"email":"alice@example.com"}`,
+		"CODE_DELIVERY_DELIVERY_MEDIUM": "SMS",
+	}
+
+	challenge, err := newChallenge(types.ChallengeNameTypeSmsMfa, aws.String("session-1"), params)
+	if err != nil {
+		t.Fatalf("newChallenge: %v", err)
+	}
+	if challenge.ChallengeName != "SMS_MFA" || challenge.Session != "session-1" {
+		t.Errorf("challenge = %+v", challenge)
+	}
+
+	// Only the parameters a client needs are passed on
+	want := map[string]string{
+		"requiredAttributes":            `["userAttributes.name"]`,
+		"CODE_DELIVERY_DESTINATION":     "+*******1234",
+		"CODE_DELIVERY_DELIVERY_MEDIUM": "SMS",
+	}
+	if len(challenge.Parameters) != len(want) {
+		t.Errorf("Parameters = %v, want %v", challenge.Parameters, want)
+	}
+	for key, value := range want {
+		if challenge.Parameters[key] != value {
+			t.Errorf("Parameters[%s] = %q, want %q", key, challenge.Parameters[key], value)
+		}
+	}
+}
+
+func TestUnsupportedChallenge(t *testing.T) {
+	if _, err := newChallenge(types.ChallengeNameTypeDeviceSrpAuth, nil, nil); !errors.Is(err, ErrUnsupportedChallenge) {
+		t.Errorf("newChallenge(DEVICE_SRP_AUTH) error = %v, want %v", err, ErrUnsupportedChallenge)
+	}
+
+	// Unsupported answers are rejected before reaching Cognito
+	c := &Client{clientID: "client"}
+	_, _, err := c.RespondToChallenge(context.Background(), ChallengeAnswer{ChallengeName: "CUSTOM_CHALLENGE", Session: "s", Email: "alice@example.com"})
+	if !errors.Is(err, ErrUnsupportedChallenge) {
+		t.Errorf("RespondToChallenge(CUSTOM_CHALLENGE) error = %v, want %v", err, ErrUnsupportedChallenge)
+	}
+}
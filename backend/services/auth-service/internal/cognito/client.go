@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/ec-recommend/backend/shared/go/observability"
 )
 
 type Client struct {
@@ -36,11 +37,11 @@ type AuthResponse struct {
 }
 
 type User struct {
-	ID           string            `json:"id"`
-	Email        string            `json:"email"`
-	EmailVerified bool             `json:"emailVerified"`
-	Name         string            `json:"name,omitempty"`
-	Attributes   map[string]string `json:"attributes"`
+	ID            string            `json:"id"`
+	Email         string            `json:"email"`
+	EmailVerified bool              `json:"emailVerified"`
+	Name          string            `json:"name,omitempty"`
+	Attributes    map[string]string `json:"attributes"`
 }
 
 func NewClient(userPoolID, clientID string) (*Client, error) {
@@ -68,6 +69,7 @@ func NewClient(userPoolID, clientID string) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to load SDK config: %v", err)
 	}
+	observability.InstrumentAWS(&cfg)
 
 	return &Client{
 		cognitoClient: cognitoidentityprovider.NewFromConfig(cfg),
@@ -98,13 +100,15 @@ func (c *Client) SignUp(ctx context.Context, req SignUpRequest) error {
 
 	_, err := c.cognitoClient.SignUp(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to sign up user: %v", err)
+		return fmt.Errorf("failed to sign up user: %w", mapError(err))
 	}
 
 	return nil
 }
 
-func (c *Client) SignIn(ctx context.Context, req SignInRequest) (*AuthResponse, error) {
+// SignIn authenticates with email and password. When Cognito requires another
+// step (new password, MFA) it returns a Challenge instead of tokens.
+func (c *Client) SignIn(ctx context.Context, req SignInRequest) (*AuthResponse, *Challenge, error) {
 	input := &cognitoidentityprovider.InitiateAuthInput{
 		AuthFlow: types.AuthFlowTypeUserPasswordAuth,
 		ClientId: aws.String(c.clientID),
@@ -116,27 +120,74 @@ func (c *Client) SignIn(ctx context.Context, req SignInRequest) (*AuthResponse,
 
 	result, err := c.cognitoClient.InitiateAuth(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign in user: %v", err)
+		return nil, nil, fmt.Errorf("failed to sign in user: %w", mapError(err))
 	}
 
 	if result.AuthenticationResult == nil {
-		return nil, fmt.Errorf("authentication failed")
+		challenge, err := newChallenge(result.ChallengeName, result.Session, result.ChallengeParameters)
+		return nil, challenge, err
 	}
 
+	authResponse, err := c.authResponse(ctx, result.AuthenticationResult)
+	return authResponse, nil, err
+}
+
+// authResponse builds the AuthResponse for a completed authentication
+func (c *Client) authResponse(ctx context.Context, result *types.AuthenticationResultType) (*AuthResponse, error) {
 	// Get user info
-	userInfo, err := c.getUser(ctx, *result.AuthenticationResult.AccessToken)
+	userInfo, err := c.getUser(ctx, *result.AccessToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user info: %v", err)
+		return nil, fmt.Errorf("failed to get user info: %w", mapError(err))
 	}
 
 	return &AuthResponse{
-		AccessToken:  *result.AuthenticationResult.AccessToken,
-		IdToken:      *result.AuthenticationResult.IdToken,
-		RefreshToken: aws.ToString(result.AuthenticationResult.RefreshToken),
+		AccessToken:  *result.AccessToken,
+		IdToken:      *result.IdToken,
+		RefreshToken: aws.ToString(result.RefreshToken),
 		User:         *userInfo,
 	}, nil
 }
 
+// SignInWithCustomChallenge runs the CUSTOM_AUTH flow for username and
+// answers the pool's custom challenge with answer. Used to exchange a
+// verified passkey assertion for Cognito tokens; the pool's verify auth
+// challenge trigger must check the answer as proof.Verify does.
+func (c *Client) SignInWithCustomChallenge(ctx context.Context, username, answer string) (*AuthResponse, error) {
+	initResult, err := c.cognitoClient.InitiateAuth(ctx, &cognitoidentityprovider.InitiateAuthInput{
+		AuthFlow: types.AuthFlowTypeCustomAuth,
+		ClientId: aws.String(c.clientID),
+		AuthParameters: map[string]string{
+			"USERNAME": username,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate custom auth: %w", mapError(err))
+	}
+
+	if initResult.ChallengeName != types.ChallengeNameTypeCustomChallenge {
+		return nil, fmt.Errorf("unexpected challenge: %s", initResult.ChallengeName)
+	}
+
+	result, err := c.cognitoClient.RespondToAuthChallenge(ctx, &cognitoidentityprovider.RespondToAuthChallengeInput{
+		ChallengeName: types.ChallengeNameTypeCustomChallenge,
+		ClientId:      aws.String(c.clientID),
+		Session:       initResult.Session,
+		ChallengeResponses: map[string]string{
+			"USERNAME": username,
+			"ANSWER":   answer,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to respond to custom challenge: %w", mapError(err))
+	}
+
+	if result.AuthenticationResult == nil {
+		return nil, ErrNotAuthorized
+	}
+
+	return c.authResponse(ctx, result.AuthenticationResult)
+}
+
 func (c *Client) getUser(ctx context.Context, accessToken string) (*User, error) {
 	input := &cognitoidentityprovider.GetUserInput{
 		AccessToken: aws.String(accessToken),
@@ -147,26 +198,14 @@ func (c *Client) getUser(ctx context.Context, accessToken string) (*User, error)
 		return nil, err
 	}
 
-	user := &User{
-		ID:         *result.Username,
-		Attributes: make(map[string]string),
-	}
-
-	// Parse user attributes
-	for _, attr := range result.UserAttributes {
-		key := *attr.Name
-		value := *attr.Value
+	return userFromAttributes(*result.Username, result.UserAttributes), nil
+}
 
-		switch key {
-		case "email":
-			user.Email = value
-		case "email_verified":
-			user.EmailVerified = value == "true"
-		case "name":
-			user.Name = value
-		default:
-			user.Attributes[key] = value
-		}
+// GetUser returns the profile of the access token's user
+func (c *Client) GetUser(ctx context.Context, accessToken string) (*User, error) {
+	user, err := c.getUser(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", mapError(err))
 	}
 
 	return user, nil
@@ -181,7 +220,7 @@ func (c *Client) ConfirmSignUp(ctx context.Context, email, confirmationCode stri
 
 	_, err := c.cognitoClient.ConfirmSignUp(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to confirm sign up: %v", err)
+		return fmt.Errorf("failed to confirm sign up: %w", mapError(err))
 	}
 
 	return nil
@@ -198,7 +237,7 @@ func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*AuthRe
 
 	result, err := c.cognitoClient.InitiateAuth(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to refresh token: %v", err)
+		return nil, fmt.Errorf("failed to refresh token: %w", mapError(err))
 	}
 
 	if result.AuthenticationResult == nil {
@@ -208,7 +247,7 @@ func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*AuthRe
 	// Get user info
 	userInfo, err := c.getUser(ctx, *result.AuthenticationResult.AccessToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user info: %v", err)
+		return nil, fmt.Errorf("failed to get user info: %w", mapError(err))
 	}
 
 	return &AuthResponse{
@@ -217,4 +256,96 @@ func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*AuthRe
 		RefreshToken: refreshToken, // Keep the original refresh token
 		User:         *userInfo,
 	}, nil
-}
\ No newline at end of file
+}
+
+// RevokeToken revokes a refresh token and the access tokens issued from it
+func (c *Client) RevokeToken(ctx context.Context, refreshToken string) error {
+	input := &cognitoidentityprovider.RevokeTokenInput{
+		ClientId: aws.String(c.clientID),
+		Token:    aws.String(refreshToken),
+	}
+
+	_, err := c.cognitoClient.RevokeToken(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GlobalSignOut invalidates all refresh tokens issued to the access token's user
+func (c *Client) GlobalSignOut(ctx context.Context, accessToken string) error {
+	input := &cognitoidentityprovider.GlobalSignOutInput{
+		AccessToken: aws.String(accessToken),
+	}
+
+	_, err := c.cognitoClient.GlobalSignOut(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to sign out globally: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// ForgotPassword sends a password reset code to the user's email
+func (c *Client) ForgotPassword(ctx context.Context, email string) error {
+	input := &cognitoidentityprovider.ForgotPasswordInput{
+		ClientId: aws.String(c.clientID),
+		Username: aws.String(email),
+	}
+
+	_, err := c.cognitoClient.ForgotPassword(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to start password reset: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// ConfirmForgotPassword sets a new password using the emailed reset code
+func (c *Client) ConfirmForgotPassword(ctx context.Context, email, confirmationCode, newPassword string) error {
+	input := &cognitoidentityprovider.ConfirmForgotPasswordInput{
+		ClientId:         aws.String(c.clientID),
+		Username:         aws.String(email),
+		ConfirmationCode: aws.String(confirmationCode),
+		Password:         aws.String(newPassword),
+	}
+
+	_, err := c.cognitoClient.ConfirmForgotPassword(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// ChangePassword changes the signed-in user's password
+func (c *Client) ChangePassword(ctx context.Context, accessToken, previousPassword, proposedPassword string) error {
+	input := &cognitoidentityprovider.ChangePasswordInput{
+		AccessToken:      aws.String(accessToken),
+		PreviousPassword: aws.String(previousPassword),
+		ProposedPassword: aws.String(proposedPassword),
+	}
+
+	_, err := c.cognitoClient.ChangePassword(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// ResendConfirmationCode re-sends the sign-up verification code
+func (c *Client) ResendConfirmationCode(ctx context.Context, email string) error {
+	input := &cognitoidentityprovider.ResendConfirmationCodeInput{
+		ClientId: aws.String(c.clientID),
+		Username: aws.String(email),
+	}
+
+	_, err := c.cognitoClient.ResendConfirmationCode(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to resend confirmation code: %w", mapError(err))
+	}
+
+	return nil
+}
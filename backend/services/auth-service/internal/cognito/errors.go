@@ -0,0 +1,77 @@
+package cognito
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+var (
+	ErrCodeMismatch    = errors.New("verification code does not match")
+	ErrCodeExpired     = errors.New("verification code has expired")
+	ErrLimitExceeded   = errors.New("attempt limit exceeded, try again later")
+	ErrInvalidPassword = errors.New("password does not meet the password policy")
+	ErrNotAuthorized   = errors.New("incorrect username or password")
+	ErrUserNotFound    = errors.New("user not found")
+
+	ErrUsernameExists        = errors.New("an account with this email already exists")
+	ErrUserNotConfirmed      = errors.New("user is not confirmed")
+	ErrPasswordResetRequired = errors.New("password reset required")
+	ErrTooManyRequests       = errors.New("too many requests")
+	ErrTooManyFailedAttempts = errors.New("too many failed attempts")
+	ErrInvalidParameter      = errors.New("invalid parameter")
+	ErrAliasExists           = errors.New("email is already in use by another account")
+)
+
+// mapError turns Cognito SDK exceptions into the package's domain errors.
+// Unrecognized errors are returned unchanged.
+func mapError(err error) error {
+	var (
+		codeMismatch    *types.CodeMismatchException
+		codeExpired     *types.ExpiredCodeException
+		limitExceeded   *types.LimitExceededException
+		invalidPassword *types.InvalidPasswordException
+		notAuthorized   *types.NotAuthorizedException
+		userNotFound    *types.UserNotFoundException
+
+		usernameExists        *types.UsernameExistsException
+		userNotConfirmed      *types.UserNotConfirmedException
+		passwordResetRequired *types.PasswordResetRequiredException
+		tooManyRequests       *types.TooManyRequestsException
+		tooManyFailedAttempts *types.TooManyFailedAttemptsException
+		invalidParameter      *types.InvalidParameterException
+		aliasExists           *types.AliasExistsException
+		enableSoftwareToken   *types.EnableSoftwareTokenMFAException
+	)
+
+	switch {
+	case errors.As(err, &codeMismatch), errors.As(err, &enableSoftwareToken):
+		return ErrCodeMismatch
+	case errors.As(err, &codeExpired):
+		return ErrCodeExpired
+	case errors.As(err, &limitExceeded):
+		return ErrLimitExceeded
+	case errors.As(err, &invalidPassword):
+		return ErrInvalidPassword
+	case errors.As(err, &notAuthorized):
+		return ErrNotAuthorized
+	case errors.As(err, &userNotFound):
+		return ErrUserNotFound
+	case errors.As(err, &usernameExists):
+		return ErrUsernameExists
+	case errors.As(err, &userNotConfirmed):
+		return ErrUserNotConfirmed
+	case errors.As(err, &passwordResetRequired):
+		return ErrPasswordResetRequired
+	case errors.As(err, &tooManyRequests):
+		return ErrTooManyRequests
+	case errors.As(err, &tooManyFailedAttempts):
+		return ErrTooManyFailedAttempts
+	case errors.As(err, &invalidParameter):
+		return ErrInvalidParameter
+	case errors.As(err, &aliasExists):
+		return ErrAliasExists
+	}
+
+	return err
+}
@@ -0,0 +1,52 @@
+package cognito
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+func TestMapError(t *testing.T) {
+	unrelated := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"code mismatch", &types.CodeMismatchException{}, ErrCodeMismatch},
+		{"wrong TOTP code", &types.EnableSoftwareTokenMFAException{}, ErrCodeMismatch},
+		{"expired code", &types.ExpiredCodeException{}, ErrCodeExpired},
+		{"limit exceeded", &types.LimitExceededException{}, ErrLimitExceeded},
+		{"invalid password", &types.InvalidPasswordException{}, ErrInvalidPassword},
+		{"not authorized", &types.NotAuthorizedException{}, ErrNotAuthorized},
+		{"user not found", &types.UserNotFoundException{}, ErrUserNotFound},
+		{"username exists", &types.UsernameExistsException{}, ErrUsernameExists},
+		{"user not confirmed", &types.UserNotConfirmedException{}, ErrUserNotConfirmed},
+		{"password reset required", &types.PasswordResetRequiredException{}, ErrPasswordResetRequired},
+		{"too many requests", &types.TooManyRequestsException{}, ErrTooManyRequests},
+		{"too many failed attempts", &types.TooManyFailedAttemptsException{}, ErrTooManyFailedAttempts},
+		{"invalid parameter", &types.InvalidParameterException{}, ErrInvalidParameter},
+		{"alias exists", &types.AliasExistsException{}, ErrAliasExists},
+		{"wrapped by the SDK", fmt.Errorf("operation error: %w", &types.NotAuthorizedException{}), ErrNotAuthorized},
+		{"unrecognized", unrelated, unrelated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapError(tt.err); got != tt.want {
+				t.Errorf("mapError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotAuthorizedMessageIsNeutral(t *testing.T) {
+	// Sign-in returns it for unknown users too, so it must not say which
+	// part of the credentials was wrong
+	if got, want := ErrNotAuthorized.Error(), "incorrect username or password"; got != want {
+		t.Errorf("ErrNotAuthorized = %q, want %q", got, want)
+	}
+}
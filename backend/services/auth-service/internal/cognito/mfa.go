@@ -0,0 +1,125 @@
+package cognito
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+const (
+	MFAMethodTOTP = "totp"
+	MFAMethodSMS  = "sms"
+)
+
+// MFAStatus summarizes a user's second factors
+type MFAStatus struct {
+	Enabled   bool     `json:"enabled"`
+	Preferred string   `json:"preferred,omitempty"`
+	Methods   []string `json:"methods"`
+}
+
+// AssociateSoftwareToken starts TOTP enrollment and returns the shared secret
+func (c *Client) AssociateSoftwareToken(ctx context.Context, accessToken string) (string, error) {
+	input := &cognitoidentityprovider.AssociateSoftwareTokenInput{
+		AccessToken: aws.String(accessToken),
+	}
+
+	result, err := c.cognitoClient.AssociateSoftwareToken(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to associate software token: %w", mapError(err))
+	}
+
+	return aws.ToString(result.SecretCode), nil
+}
+
+// VerifySoftwareToken checks the first TOTP code and, on success, enables
+// TOTP as the user's preferred MFA method
+func (c *Client) VerifySoftwareToken(ctx context.Context, accessToken, code, deviceName string) error {
+	input := &cognitoidentityprovider.VerifySoftwareTokenInput{
+		AccessToken: aws.String(accessToken),
+		UserCode:    aws.String(code),
+	}
+	if deviceName != "" {
+		input.FriendlyDeviceName = aws.String(deviceName)
+	}
+
+	result, err := c.cognitoClient.VerifySoftwareToken(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to verify software token: %w", mapError(err))
+	}
+
+	if result.Status != types.VerifySoftwareTokenResponseTypeSuccess {
+		return ErrCodeMismatch
+	}
+
+	return c.SetMFAPreference(ctx, accessToken, MFAMethodTOTP, true)
+}
+
+// SetMFAPreference enables or disables an MFA method. An enabled method
+// becomes the preferred one.
+func (c *Client) SetMFAPreference(ctx context.Context, accessToken, method string, enabled bool) error {
+	input := &cognitoidentityprovider.SetUserMFAPreferenceInput{
+		AccessToken: aws.String(accessToken),
+	}
+
+	switch method {
+	case MFAMethodTOTP:
+		input.SoftwareTokenMfaSettings = &types.SoftwareTokenMfaSettingsType{
+			Enabled:      enabled,
+			PreferredMfa: enabled,
+		}
+	case MFAMethodSMS:
+		input.SMSMfaSettings = &types.SMSMfaSettingsType{
+			Enabled:      enabled,
+			PreferredMfa: enabled,
+		}
+	default:
+		return fmt.Errorf("%w: unknown MFA method %s", ErrInvalidParameter, method)
+	}
+
+	_, err := c.cognitoClient.SetUserMFAPreference(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to set MFA preference: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GetMFAStatus reports which MFA methods the user has enabled
+func (c *Client) GetMFAStatus(ctx context.Context, accessToken string) (*MFAStatus, error) {
+	input := &cognitoidentityprovider.GetUserInput{
+		AccessToken: aws.String(accessToken),
+	}
+
+	result, err := c.cognitoClient.GetUser(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", mapError(err))
+	}
+
+	status := &MFAStatus{
+		Methods:   []string{},
+		Preferred: mfaMethodName(aws.ToString(result.PreferredMfaSetting)),
+	}
+
+	for _, setting := range result.UserMFASettingList {
+		if method := mfaMethodName(setting); method != "" {
+			status.Methods = append(status.Methods, method)
+		}
+	}
+	status.Enabled = len(status.Methods) > 0
+
+	return status, nil
+}
+
+func mfaMethodName(setting string) string {
+	switch setting {
+	case "SOFTWARE_TOKEN_MFA":
+		return MFAMethodTOTP
+	case "SMS_MFA":
+		return MFAMethodSMS
+	}
+	return ""
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/account"
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeletionGracePeriod = 30 * 24 * time.Hour
+	// defaultDeletionMaxAuthAge makes users sign in again shortly before
+	// requesting deletion, so a stolen long-lived session can't do it
+	defaultDeletionMaxAuthAge = 15 * time.Minute
+	maxDeletionSweepInterval  = time.Minute
+)
+
+type AccountHandler struct {
+	auth       *AuthHandler
+	deletions  *account.Deletions
+	records    account.DataSource
+	maxAuthAge time.Duration
+	// sweepInterval is how often RunDeletions looks for due deletions
+	sweepInterval time.Duration
+}
+
+func NewAccountHandler(auth *AuthHandler) (*AccountHandler, error) {
+	grace, err := durationEnv("ACCOUNT_DELETION_GRACE_PERIOD", defaultDeletionGracePeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAuthAge, err := durationEnv("ACCOUNT_DELETION_MAX_AUTH_AGE", defaultDeletionMaxAuthAge)
+	if err != nil {
+		return nil, err
+	}
+
+	var publisher account.Publisher = account.LogPublisher{}
+	if url := os.Getenv("ACCOUNT_EVENTS_WEBHOOK_URL"); url != "" {
+		publisher = account.NewWebhookPublisher(url)
+	}
+
+	h := &AccountHandler{
+		auth:       auth,
+		maxAuthAge: maxAuthAge,
+	}
+
+	// Scheduled deletions must outlive restarts for the whole grace period,
+	// so they are kept in the database whenever there is one
+	var store account.DeletionStore
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		records, err := account.NewPostgresDataSource(databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		h.records = records
+
+		deletions, err := account.NewPostgresDeletionStore(databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		store = deletions
+	} else {
+		log.Println("DATABASE_URL is not set; data exports contain the identity profile only and scheduled deletions are kept in memory")
+		store = account.NewMemoryDeletionStore()
+	}
+	h.deletions = account.NewDeletions(grace, store, publisher, h.deleteUser)
+
+	h.sweepInterval = max(min(grace, maxDeletionSweepInterval), time.Second)
+
+	return h, nil
+}
+
+// RunDeletions carries out scheduled deletions once their grace period ends,
+// until ctx is done. Run it in its own goroutine.
+func (h *AccountHandler) RunDeletions(ctx context.Context) {
+	h.deletions.Run(ctx, h.sweepInterval)
+}
+
+// durationEnv parses a duration setting, using def when it is unset
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return d, nil
+}
+
+// deleteUser removes the user from the identity provider and revokes the
+// tokens they still hold
+func (h *AccountHandler) deleteUser(ctx context.Context, d account.Deletion) error {
+	deleter, ok := h.auth.provider.(userDeleter)
+	if !ok {
+		return errors.New("identity provider cannot delete users")
+	}
+
+	err := deleter.AdminDeleteUser(ctx, d.Username)
+	if err != nil && !errors.Is(err, cognito.ErrUserNotFound) {
+		return err
+	}
+
+	h.auth.denylist.RevokeSubject(d.Subject, time.Now())
+	h.auth.profiles.invalidate(d.Subject)
+	return nil
+}
+
+// RequestDeletion schedules the signed-in user's account for deletion after
+// the grace period. It requires a recent sign-in.
+func (h *AccountHandler) RequestDeletion(c *gin.Context) {
+	if _, ok := h.auth.provider.(userDeleter); !ok {
+		notSupported(c)
+		return
+	}
+
+	claims, accessToken, ok := h.auth.requireAccessToken(c, jwt.MaxAuthAge(h.maxAuthAge))
+	if !ok {
+		return
+	}
+
+	if claims.Pool != "" && claims.Pool != buyerPool {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "deletion_not_allowed",
+			Message: "Only buyer accounts can be deleted here",
+		})
+		return
+	}
+
+	// Access tokens carry no email; downstream services want it in the event
+	profile, err := h.auth.currentUser(c.Request.Context(), claims, accessToken)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "deletion_failed", "Failed to delete account")
+		c.JSON(status, resp)
+		return
+	}
+
+	deletion, err := h.deletions.Schedule(c.Request.Context(), account.Deletion{
+		Subject:  claims.Subject,
+		Username: claims.Username,
+		Email:    profile.Email,
+		Pool:     claims.Pool,
+	})
+	if errors.Is(err, account.ErrDeletionScheduled) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "deletion_scheduled",
+			Message: "Account deletion is already scheduled",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "deletion_failed",
+			Message: "Failed to delete account",
+		})
+		return
+	}
+
+	if h.deletions.GracePeriod() <= 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Account deleted",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Account deletion scheduled",
+		"deletion": deletion,
+	})
+}
+
+// GetDeletion reports the signed-in user's scheduled deletion
+func (h *AccountHandler) GetDeletion(c *gin.Context) {
+	claims, _, ok := h.auth.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	deletion, err := h.deletions.Status(c.Request.Context(), claims.Subject)
+	if err != nil {
+		deletionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deletion": deletion,
+	})
+}
+
+// CancelDeletion withdraws the signed-in user's scheduled deletion
+func (h *AccountHandler) CancelDeletion(c *gin.Context) {
+	claims, _, ok := h.auth.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	if err := h.deletions.Cancel(c.Request.Context(), claims.Subject); err != nil {
+		deletionErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Account deletion cancelled",
+	})
+}
+
+func deletionErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, account.ErrDeletionNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "deletion_not_found",
+			Message: "No account deletion is scheduled",
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "deletion_failed",
+		Message: "Failed to read account deletion",
+	})
+}
+
+// accountExport is the downloadable archive of a user's data
+type accountExport struct {
+	ExportedAt time.Time     `json:"exportedAt"`
+	Profile    *cognito.User `json:"profile"`
+	*account.Records
+}
+
+// ExportData returns the signed-in user's profile, addresses, orders,
+// reviews and favorites as a JSON attachment
+func (h *AccountHandler) ExportData(c *gin.Context) {
+	claims, accessToken, ok := h.auth.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	profile, err := h.auth.currentUser(c.Request.Context(), claims, accessToken)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "export_failed", "Failed to export account data")
+		c.JSON(status, resp)
+		return
+	}
+
+	export := accountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    profile,
+	}
+
+	if h.records != nil {
+		export.Records, err = h.records.UserRecords(c.Request.Context(), claims.Subject)
+		if err != nil {
+			log.Printf("Data export for %s failed: %v", claims.Subject, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "export_failed",
+				Message: "Failed to export account data",
+			})
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("account-export-%s.json", export.ExportedAt.Format("20060102"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.JSON(http.StatusOK, export)
+}
@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ec-recommend/auth-service/internal/audit"
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/ec-recommend/backend/shared/go/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// adminGroup is the Cognito group whose members may use the admin routes
+const adminGroup = "admin"
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Admin audit event types
+const (
+	auditAdminDenied         = "admin.access_denied"
+	auditAdminListUsers      = "admin.users_listed"
+	auditAdminGetUser        = "admin.user_viewed"
+	auditAdminDisableUser    = "admin.user_disabled"
+	auditAdminEnableUser     = "admin.user_enabled"
+	auditAdminAddGroup       = "admin.group_added"
+	auditAdminRemoveGroup    = "admin.group_removed"
+	auditAdminResetPass      = "admin.password_reset"
+	auditAdminGlobalSignOut  = "admin.signed_out"
+	auditAdminEventsSearched = "admin.audit_searched"
+)
+
+type AdminHandler struct {
+	auth *AuthHandler
+	// access is the shared auth middleware, verifying tokens with auth
+	access *middleware.AuthMiddleware
+	// groups are the groups administrators may grant and revoke
+	groups []string
+}
+
+// NewAdminHandler serves the user-management routes. ADMIN_MANAGED_GROUPS
+// lists the groups that can be granted (default seller,admin).
+func NewAdminHandler(auth *AuthHandler) (*AdminHandler, error) {
+	groups := splitList(os.Getenv("ADMIN_MANAGED_GROUPS"))
+	if len(groups) == 0 {
+		groups = []string{"seller", adminGroup}
+	}
+
+	access, err := middleware.NewAuthMiddleware(nil, "", "", "", middleware.WithTokenVerifier(auth))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin auth middleware: %v", err)
+	}
+
+	return &AdminHandler{
+		auth:   auth,
+		access: access,
+		groups: groups,
+	}, nil
+}
+
+// splitList splits a comma-separated setting, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// VerifyToken checks an access token for the shared auth middleware on the
+// admin routes. Rejected tokens are audited like on the other routes.
+func (h *AuthHandler) VerifyToken(ctx context.Context, token string) (*middleware.AuthInfo, error) {
+	claims, err := h.jwtValidator.ValidateToken(token, jwt.TokenUses("access"))
+	if err != nil {
+		_, resp := tokenErrorResponse(err)
+		h.audit.Record(ctx, audit.Event{
+			Type:    auditTokenRejected,
+			Outcome: audit.OutcomeFailure,
+			Reason:  resp.Error,
+		})
+		return nil, errors.New(resp.Message)
+	}
+
+	sellerID, _ := claims.Claim(sellerIDAttribute)
+	info := &middleware.AuthInfo{
+		UserID:      claims.Subject,
+		Email:       claims.Email,
+		Roles:       claims.Groups,
+		Permissions: strings.Fields(claims.Scope),
+		Pool:        claims.Pool,
+	}
+	info.SellerID, _ = sellerID.(string)
+	return info, nil
+}
+
+// Middleware guards the admin routes: the shared auth middleware admits
+// access tokens of admin group members, verified by the auth handler.
+// Session cookies are accepted as in the other routes, behind CSRFProtect.
+func (h *AdminHandler) Middleware() gin.HandlersChain {
+	return gin.HandlersChain{
+		h.auth.CSRFProtect(),
+		h.auth.sessionAuthorization(),
+		h.auditDenied(),
+		h.access.GinAuth(adminGroup),
+	}
+}
+
+// auditDenied records callers the auth middleware turned away for not
+// being in the admin group
+func (h *AdminHandler) auditDenied() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		caller, ok := middleware.GinAuthInfo(c)
+		if !ok || !c.IsAborted() || c.Writer.Status() != http.StatusForbidden {
+			return
+		}
+		h.auth.audit.Record(c.Request.Context(), audit.Event{
+			Type:    auditAdminDenied,
+			Actor:   caller.UserID,
+			Outcome: audit.OutcomeFailure,
+			Reason:  "not in the admin group",
+			Details: map[string]string{"route": c.FullPath()},
+		})
+	}
+}
+
+// caller returns the administrator Middleware let through. Routes mounted
+// without it are refused.
+func (h *AdminHandler) caller(c *gin.Context) (*middleware.AuthInfo, bool) {
+	caller, ok := middleware.GinAuthInfo(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "admin_required",
+			Message: "Administrator access is required",
+		})
+		return nil, false
+	}
+	return caller, true
+}
+
+// requireAdmin returns the caller and the provider's user administration,
+// writing an error response when either is missing
+func (h *AdminHandler) requireAdmin(c *gin.Context) (*middleware.AuthInfo, userAdministrator, bool) {
+	admin, ok := h.auth.provider.(userAdministrator)
+	if !ok {
+		notSupported(c)
+		return nil, nil, false
+	}
+
+	caller, ok := h.caller(c)
+	if !ok {
+		return nil, nil, false
+	}
+	return caller, admin, true
+}
+
+// record adds an admin action to the audit trail
+func (h *AdminHandler) record(ctx context.Context, eventType string, actor *middleware.AuthInfo, userID string, err error, details map[string]string) {
+	event := audit.Event{
+		Type:    eventType,
+		Actor:   actor.UserID,
+		UserID:  userID,
+		Outcome: audit.OutcomeSuccess,
+		Details: details,
+	}
+	if err != nil {
+		event.Outcome = audit.OutcomeFailure
+		event.Reason = err.Error()
+	}
+
+	h.auth.audit.Record(ctx, event)
+}
+
+// pageRequest follows common.PageRequest: page is 1-based
+type pageRequest struct {
+	Page       int
+	PageSize   int
+	SortBy     string
+	Descending bool
+}
+
+// pageResponse follows common.PageResponse
+type pageResponse struct {
+	TotalItems  int `json:"total_items"`
+	TotalPages  int `json:"total_pages"`
+	CurrentPage int `json:"current_page"`
+	PageSize    int `json:"page_size"`
+}
+
+// offset is the index of the page's first item
+func (p pageRequest) offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// response describes the page within totalItems
+func (p pageRequest) response(totalItems int) pageResponse {
+	return pageResponse{
+		TotalItems:  totalItems,
+		TotalPages:  (totalItems + p.PageSize - 1) / p.PageSize,
+		CurrentPage: p.Page,
+		PageSize:    p.PageSize,
+	}
+}
+
+// parsePageRequest reads page, page_size, sort_by and descending from the
+// query, reporting false if any is invalid
+func parsePageRequest(c *gin.Context, defaultSort string) (pageRequest, bool) {
+	page := pageRequest{
+		Page:     1,
+		PageSize: defaultPageSize,
+		SortBy:   c.DefaultQuery("sort_by", defaultSort),
+	}
+
+	var err error
+	if v := c.Query("page"); v != "" {
+		if page.Page, err = strconv.Atoi(v); err != nil || page.Page < 1 {
+			return page, false
+		}
+	}
+	if v := c.Query("page_size"); v != "" {
+		if page.PageSize, err = strconv.Atoi(v); err != nil || page.PageSize < 1 || page.PageSize > maxPageSize {
+			return page, false
+		}
+	}
+	if v := c.Query("descending"); v != "" {
+		if page.Descending, err = strconv.ParseBool(v); err != nil {
+			return page, false
+		}
+	}
+
+	return page, true
+}
+
+// cursorPageResponse describes a page of a list walked with next_token,
+// for lists that can't count or jump to a page
+type cursorPageResponse struct {
+	PageSize  int    `json:"page_size"`
+	NextToken string `json:"next_token,omitempty"`
+}
+
+// ListUsers lists users a page at a time, following next_token from the
+// previous page. search matches the start of the email and group limits the
+// list to a group's members. Users come in the identity provider's order.
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	caller, admin, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	filter := cognito.UserFilter{
+		Search:    strings.TrimSpace(c.Query("search")),
+		Group:     strings.TrimSpace(c.Query("group")),
+		Limit:     defaultPageSize,
+		NextToken: c.Query("next_token"),
+	}
+	if v := c.Query("page_size"); v != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > cognito.MaxListUsersLimit {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_parameter",
+				Message: fmt.Sprintf("page_size must be 1-%d", cognito.MaxListUsersLimit),
+			})
+			return
+		}
+	}
+
+	page, err := admin.ListUsers(c.Request.Context(), filter)
+	h.record(c.Request.Context(), auditAdminListUsers, caller, "", err, map[string]string{
+		"search": filter.Search,
+		"group":  filter.Group,
+	})
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "list_users_failed", "Failed to list users")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": page.Users,
+		"pagination": cursorPageResponse{
+			PageSize:  filter.Limit,
+			NextToken: page.NextToken,
+		},
+	})
+}
+
+// GetUser returns a user with their groups
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	caller, admin, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	username := c.Param("username")
+	user, err := admin.AdminGetUser(c.Request.Context(), username)
+	h.record(c.Request.Context(), auditAdminGetUser, caller, username, err, nil)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "get_user_failed", "Failed to get user")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// DisableUser blocks a user from signing in and revokes their tokens
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	h.setEnabled(c, false)
+}
+
+// EnableUser lets a disabled user sign in again
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	h.setEnabled(c, true)
+}
+
+func (h *AdminHandler) setEnabled(c *gin.Context, enabled bool) {
+	caller, admin, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	eventType, message := auditAdminDisableUser, "User disabled"
+	if enabled {
+		eventType, message = auditAdminEnableUser, "User enabled"
+	}
+
+	h.act(c, caller, admin, eventType, message, nil, func(ctx context.Context, user *cognito.UserRecord) error {
+		if !enabled && user.Subject == caller.UserID {
+			return errSelfModification
+		}
+		if err := admin.AdminSetUserEnabled(ctx, user.ID, enabled); err != nil {
+			return err
+		}
+		if !enabled {
+			h.revoke(user)
+		}
+		return nil
+	})
+}
+
+// AddUserToGroup grants a group. The user sees it in cognito:groups once
+// they refresh their tokens.
+func (h *AdminHandler) AddUserToGroup(c *gin.Context) {
+	caller, admin, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	group := c.Param("group")
+	if !h.managedGroup(c, group) {
+		return
+	}
+
+	h.act(c, caller, admin, auditAdminAddGroup, "User added to group", map[string]string{"group": group}, func(ctx context.Context, user *cognito.UserRecord) error {
+		return admin.AdminAddUserToGroup(ctx, user.ID, group)
+	})
+}
+
+// RemoveUserFromGroup revokes a group. The user's tokens are revoked too, so
+// the group stops applying now rather than when they expire.
+func (h *AdminHandler) RemoveUserFromGroup(c *gin.Context) {
+	caller, admin, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	group := c.Param("group")
+	if !h.managedGroup(c, group) {
+		return
+	}
+
+	h.act(c, caller, admin, auditAdminRemoveGroup, "User removed from group", map[string]string{"group": group}, func(ctx context.Context, user *cognito.UserRecord) error {
+		if group == adminGroup && user.Subject == caller.UserID {
+			return errSelfModification
+		}
+		if err := admin.AdminRemoveUserFromGroup(ctx, user.ID, group); err != nil {
+			return err
+		}
+		h.revoke(user)
+		return nil
+	})
+}
+
+// ResetPassword invalidates a user's password and sends them a reset code
+func (h *AdminHandler) ResetPassword(c *gin.Context) {
+	caller, admin, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	h.act(c, caller, admin, auditAdminResetPass, "Password reset required", nil, func(ctx context.Context, user *cognito.UserRecord) error {
+		return admin.AdminResetUserPassword(ctx, user.ID)
+	})
+}
+
+// SignOutUser signs a user out of every session and revokes their tokens
+func (h *AdminHandler) SignOutUser(c *gin.Context) {
+	caller, admin, ok := h.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	h.act(c, caller, admin, auditAdminGlobalSignOut, "User signed out of all sessions", nil, func(ctx context.Context, user *cognito.UserRecord) error {
+		if err := admin.AdminUserGlobalSignOut(ctx, user.ID); err != nil {
+			return err
+		}
+		h.revoke(user)
+		return nil
+	})
+}
+
+// errSelfModification stops admins from disabling or demoting themselves,
+// which could leave no one able to undo it
+var errSelfModification = errors.New("administrators cannot disable or demote themselves")
+
+// act looks up the :username user, runs action on them and records the
+// outcome in the audit trail
+func (h *AdminHandler) act(c *gin.Context, caller *middleware.AuthInfo, admin userAdministrator, eventType, message string, details map[string]string, action func(context.Context, *cognito.UserRecord) error) {
+	ctx := c.Request.Context()
+	username := c.Param("username")
+
+	user, err := admin.AdminGetUser(ctx, username)
+	if err == nil {
+		err = action(ctx, user)
+	}
+	h.record(ctx, eventType, caller, username, err, details)
+
+	if errors.Is(err, errSelfModification) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "self_modification",
+			Message: "Administrators cannot disable or demote themselves",
+		})
+		return
+	}
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "admin_action_failed", "Failed to update user")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+// revoke makes the auth service reject tokens the user already holds
+func (h *AdminHandler) revoke(user *cognito.UserRecord) {
+	h.auth.revokeTokens(user.Subject)
+}
+
+// managedGroup checks group may be granted, writing an error response if not
+func (h *AdminHandler) managedGroup(c *gin.Context, group string) bool {
+	for _, g := range h.groups {
+		if g == group {
+			return true
+		}
+	}
+
+	c.JSON(http.StatusBadRequest, ErrorResponse{
+		Error:   "invalid_group",
+		Message: "Group must be one of: " + strings.Join(h.groups, ", "),
+	})
+	return false
+}
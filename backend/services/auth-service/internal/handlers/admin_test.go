@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/ec-recommend/auth-service/internal/audit"
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/ec-recommend/auth-service/internal/local"
+	"github.com/gin-gonic/gin"
+)
+
+// auditLog collects audit events
+type auditLog struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (l *auditLog) Write(ctx context.Context, event audit.Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	return nil
+}
+
+// types returns the recorded event types with their outcomes
+func (l *auditLog) types() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var types []string
+	for _, event := range l.events {
+		types = append(types, event.Type+":"+event.Outcome)
+	}
+	return types
+}
+
+// newAdminHandler returns an admin handler over the local provider, where
+// root@example.com signs up in the admin group
+func newAdminHandler(t *testing.T) (*AdminHandler, *local.Provider, *auditLog) {
+	t.Helper()
+
+	p, err := local.NewProvider(local.Config{
+		UserPoolID:  "ap-northeast-1_local",
+		Region:      "ap-northeast-1",
+		ClientID:    "local-client",
+		AutoConfirm: true,
+		AdminEmails: []string{"root@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := jwt.NewValidator("ap-northeast-1_local", "ap-northeast-1", "local-client", jwt.WithKeySource(p.JWKS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(v.Close)
+
+	log := &auditLog{}
+	auth := &AuthHandler{
+		provider:     p,
+		jwtValidator: v,
+		profiles:     newProfileCache(0),
+		denylist:     jwt.NewMemoryDenylist(),
+		audit:        audit.NewRecorder(nil, log, audit.NewMemoryStore(100)),
+	}
+	h, err := NewAdminHandler(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, p, log
+}
+
+// serveAdmin sends a request through the admin routes and returns the
+// status and body
+func serveAdmin(t *testing.T, h *AdminHandler, method, target, token string) (int, []byte) {
+	t.Helper()
+
+	r := gin.New()
+	admin := r.Group("/admin/users", h.Middleware()...)
+	{
+		admin.GET("", h.ListUsers)
+		admin.GET("/:username", h.GetUser)
+		admin.POST("/:username/disable", h.DisableUser)
+		admin.POST("/:username/enable", h.EnableUser)
+		admin.PUT("/:username/groups/:group", h.AddUserToGroup)
+		admin.DELETE("/:username/groups/:group", h.RemoveUserFromGroup)
+		admin.POST("/:username/password/reset", h.ResetPassword)
+		admin.POST("/:username/signout", h.SignOutUser)
+	}
+
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, w.Body.Bytes()
+}
+
+func errorCode(body []byte) string {
+	var resp ErrorResponse
+	json.Unmarshal(body, &resp)
+	return resp.Error
+}
+
+func TestAdminRequiresAdminGroup(t *testing.T) {
+	h, p, log := newAdminHandler(t)
+	alice := signedIn(t, p, "alice@example.com")
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+		wantErr  string
+	}{
+		{"no token", "", http.StatusUnauthorized, "missing_token"},
+		{"id token", alice.IdToken, http.StatusUnauthorized, "invalid_token"},
+		{"not an admin", alice.AccessToken, http.StatusForbidden, "insufficient_permissions"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveAdmin(t, h, http.MethodGet, "/admin/users", tt.token)
+			if code != tt.wantCode || errorCode(body) != tt.wantErr {
+				t.Errorf("response = %d %s, want %d %q", code, body, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+
+	want := []string{auditTokenRejected + ":" + audit.OutcomeFailure, auditAdminDenied + ":" + audit.OutcomeFailure}
+	if got := log.types(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("audit events = %v, want %v", got, want)
+	}
+}
+
+// listUsers serves a ListUsers request and returns the page's emails and
+// next token
+func listUsers(t *testing.T, h *AdminHandler, token, query string) (int, []string, string) {
+	t.Helper()
+
+	code, body := serveAdmin(t, h, http.MethodGet, "/admin/users"+query, token)
+	if code != http.StatusOK {
+		return code, nil, ""
+	}
+
+	var resp struct {
+		Users      []cognito.UserRecord `json:"users"`
+		Pagination cursorPageResponse   `json:"pagination"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	emails := []string{}
+	for _, user := range resp.Users {
+		emails = append(emails, user.Email)
+	}
+	return code, emails, resp.Pagination.NextToken
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAdminListUsers(t *testing.T) {
+	h, p, _ := newAdminHandler(t)
+	root := signedIn(t, p, "root@example.com")
+	for _, email := range []string{"carol@example.com", "alice@example.com", "bob@example.com"} {
+		signedIn(t, p, email)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantCode   int
+		wantEmails []string
+	}{
+		{"all", "", http.StatusOK, []string{"alice@example.com", "bob@example.com", "carol@example.com", "root@example.com"}},
+		{"search", "?search=Bo", http.StatusOK, []string{"bob@example.com"}},
+		{"group", "?group=admin", http.StatusOK, []string{"root@example.com"}},
+		{"no match", "?search=zed", http.StatusOK, []string{}},
+		{"page size zero", "?page_size=0", http.StatusBadRequest, nil},
+		{"page size too large", "?page_size=61", http.StatusBadRequest, nil},
+		{"page size not a number", "?page_size=ten", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, emails, next := listUsers(t, h, root.AccessToken, tt.query)
+			if code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", code, tt.wantCode)
+			}
+			if code == http.StatusOK && (!equalStrings(emails, tt.wantEmails) || next != "") {
+				t.Errorf("users = %v, next %q; want %v on one page", emails, next, tt.wantEmails)
+			}
+		})
+	}
+}
+
+func TestAdminListUsersPages(t *testing.T) {
+	h, p, _ := newAdminHandler(t)
+	root := signedIn(t, p, "root@example.com")
+	for _, email := range []string{"carol@example.com", "alice@example.com", "bob@example.com", "dave@example.com"} {
+		signedIn(t, p, email)
+	}
+
+	// Following next_token visits every user once, a page at a time
+	want := [][]string{
+		{"alice@example.com", "bob@example.com"},
+		{"carol@example.com", "dave@example.com"},
+		{"root@example.com"},
+	}
+
+	query := "?page_size=2"
+	for i, wantEmails := range want {
+		code, emails, next := listUsers(t, h, root.AccessToken, query)
+		if code != http.StatusOK || !equalStrings(emails, wantEmails) {
+			t.Fatalf("page %d = %d %v, want %v", i+1, code, emails, wantEmails)
+		}
+		if last := i == len(want)-1; last != (next == "") {
+			t.Fatalf("page %d next token = %q, want one on all but the last page", i+1, next)
+		}
+		query = "?page_size=2&next_token=" + url.QueryEscape(next)
+	}
+}
+
+func TestAdminActions(t *testing.T) {
+	h, p, log := newAdminHandler(t)
+	root := signedIn(t, p, "root@example.com")
+	alice := signedIn(t, p, "alice@example.com")
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+		wantErr  string
+	}{
+		{"disable", http.MethodPost, "/admin/users/" + alice.User.ID + "/disable", http.StatusOK, ""},
+		{"enable", http.MethodPost, "/admin/users/" + alice.User.ID + "/enable", http.StatusOK, ""},
+		{"grant seller", http.MethodPut, "/admin/users/" + alice.User.ID + "/groups/seller", http.StatusOK, ""},
+		{"grant unmanaged group", http.MethodPut, "/admin/users/" + alice.User.ID + "/groups/staff", http.StatusBadRequest, "invalid_group"},
+		{"revoke seller", http.MethodDelete, "/admin/users/" + alice.User.ID + "/groups/seller", http.StatusOK, ""},
+		{"disable self", http.MethodPost, "/admin/users/" + root.User.ID + "/disable", http.StatusBadRequest, "self_modification"},
+		{"demote self", http.MethodDelete, "/admin/users/" + root.User.ID + "/groups/admin", http.StatusBadRequest, "self_modification"},
+		{"unknown user", http.MethodPost, "/admin/users/nobody/signout", http.StatusNotFound, "user_not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveAdmin(t, h, tt.method, tt.target, root.AccessToken)
+			if code != tt.wantCode || errorCode(body) != tt.wantErr {
+				t.Errorf("response = %d %s, want %d %q", code, body, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+
+	want := []string{
+		auditAdminDisableUser + ":" + audit.OutcomeSuccess,
+		auditAdminEnableUser + ":" + audit.OutcomeSuccess,
+		auditAdminAddGroup + ":" + audit.OutcomeSuccess,
+		auditAdminRemoveGroup + ":" + audit.OutcomeSuccess,
+		auditAdminDisableUser + ":" + audit.OutcomeFailure,
+		auditAdminRemoveGroup + ":" + audit.OutcomeFailure,
+		auditAdminGlobalSignOut + ":" + audit.OutcomeFailure,
+	}
+	got := log.types()
+	if len(got) != len(want) {
+		t.Fatalf("audit events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("audit events = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestAdminDisableBlocksSignIn(t *testing.T) {
+	h, p, _ := newAdminHandler(t)
+	root := signedIn(t, p, "root@example.com")
+	alice := signedIn(t, p, "alice@example.com")
+
+	if code, body := serveAdmin(t, h, http.MethodPost, "/admin/users/"+alice.User.ID+"/disable", root.AccessToken); code != http.StatusOK {
+		t.Fatalf("disable status = %d (%s)", code, body)
+	}
+
+	_, _, err := p.SignIn(context.Background(), cognito.SignInRequest{Email: "alice@example.com", Password: testPassword})
+	if !errors.Is(err, cognito.ErrNotAuthorized) {
+		t.Errorf("SignIn() after disabling error = %v, want %v", err, cognito.ErrNotAuthorized)
+	}
+
+	code, body := serveAdmin(t, h, http.MethodGet, "/admin/users/"+alice.User.ID, root.AccessToken)
+	var resp struct {
+		User cognito.UserRecord `json:"user"`
+	}
+	json.Unmarshal(body, &resp)
+	if code != http.StatusOK || resp.User.Enabled {
+		t.Errorf("GetUser() = %d %s, want the user disabled", code, body)
+	}
+}
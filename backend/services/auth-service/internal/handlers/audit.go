@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/audit"
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication audit event types
+const (
+	auditSignIn            = "auth.signin"
+	auditChallengeIssued   = "auth.challenge_issued"
+	auditChallenge         = "auth.challenge"
+	auditRefresh           = "auth.refresh"
+	auditConfirm           = "auth.confirm"
+	auditSignOut           = "auth.signout"
+	auditPasskeyRegistered = "auth.passkey_registered"
+	auditPasskeySignIn     = "auth.passkey_signin"
+	auditOAuthSignIn       = "auth.oauth_signin"
+	auditTokenRejected     = "auth.token_rejected"
+	auditSignInLocked      = "auth.signin_locked"
+	auditSignInUnlocked    = "auth.signin_unlocked"
+)
+
+const (
+	defaultAuditSearchLimit = 100
+	maxAuditSearchLimit     = 500
+)
+
+// newAuditRecorder builds the audit trail from AUDIT_* settings. Events go
+// to the sinks named in AUDIT_SINKS (stdout, file, sqs), and always to a
+// searchable store: Redis when REDIS_URL is set, memory otherwise.
+func newAuditRecorder() (*audit.Recorder, error) {
+	var sinks []audit.Sink
+
+	names := splitList(os.Getenv("AUDIT_SINKS"))
+	if len(names) == 0 {
+		names = []string{"stdout"}
+	}
+	for _, name := range names {
+		switch name {
+		case "stdout":
+			sinks = append(sinks, audit.NewStdoutSink())
+		case "file":
+			path := os.Getenv("AUDIT_FILE_PATH")
+			if path == "" {
+				return nil, errors.New("AUDIT_FILE_PATH is required for the file audit sink")
+			}
+			sink, err := audit.NewFileSink(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open audit log: %v", err)
+			}
+			sinks = append(sinks, sink)
+		case "sqs":
+			queueURL := os.Getenv("AUDIT_SQS_QUEUE_URL")
+			if queueURL == "" {
+				return nil, errors.New("AUDIT_SQS_QUEUE_URL is required for the sqs audit sink")
+			}
+			sink, err := audit.NewSQSSink(queueURL, os.Getenv("SQS_ENDPOINT"))
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+
+	perUser := 200
+	if value := os.Getenv("AUDIT_EVENTS_PER_USER"); value != "" {
+		var err error
+		if perUser, err = strconv.Atoi(value); err != nil || perUser < 1 {
+			return nil, fmt.Errorf("invalid AUDIT_EVENTS_PER_USER %q", value)
+		}
+	}
+	retention, err := durationEnv("AUDIT_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		store, err := audit.NewRedisStore(redisURL, perUser, retention)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, store)
+	} else {
+		log.Println("REDIS_URL is not set; recent audit events are searchable per process only")
+		sinks = append(sinks, audit.NewMemoryStore(10000))
+	}
+
+	emailKey := []byte(os.Getenv("AUDIT_EMAIL_HASH_KEY"))
+	if len(emailKey) == 0 {
+		// Unkeyed hashes can be reversed by hashing guessed emails
+		log.Println("WARNING: AUDIT_EMAIL_HASH_KEY is not set, audit email hashes are unkeyed")
+	}
+
+	return audit.NewRecorder(emailKey, sinks...), nil
+}
+
+// AuditContext attaches the client's IP and user agent to the request
+// context, so every audit event recorded while serving it carries them
+func (h *AuthHandler) AuditContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(audit.WithClient(c.Request.Context(), c.ClientIP(), c.Request.UserAgent()))
+		c.Next()
+	}
+}
+
+// CloseAudit sends audit events still buffered for their sinks, waiting
+// until they are sent or ctx is done. Call it on shutdown.
+func (h *AuthHandler) CloseAudit(ctx context.Context) error {
+	return h.audit.Close(ctx)
+}
+
+// recordAuth adds an authentication event to the audit trail. email
+// identifies the user when userID is unknown; reason is the failure, empty
+// on success.
+func (h *AuthHandler) recordAuth(c *gin.Context, eventType, userID, email, reason string, details map[string]string) {
+	event := audit.Event{
+		Type:    eventType,
+		UserID:  userID,
+		Email:   email,
+		Outcome: audit.OutcomeSuccess,
+		Reason:  reason,
+		Details: details,
+	}
+	if reason != "" {
+		event.Outcome = audit.OutcomeFailure
+	}
+
+	h.audit.Record(c.Request.Context(), event)
+}
+
+// recordTokenRejected audits a presented token that failed validation.
+// Requests without a token aren't recorded.
+func (h *AuthHandler) recordTokenRejected(c *gin.Context, err error) {
+	if errors.Is(err, errMissingToken) {
+		return
+	}
+
+	_, resp := tokenErrorResponse(err)
+	h.recordAuth(c, auditTokenRejected, "", "", resp.Error, map[string]string{"route": c.FullPath()})
+}
+
+// SearchEvents returns a user's recent audit events, newest first. The user
+// is given by user_id (their sub) or email; type, since (RFC 3339) and
+// limit narrow the results.
+func (h *AdminHandler) SearchEvents(c *gin.Context) {
+	caller, ok := h.caller(c)
+	if !ok {
+		return
+	}
+
+	q := audit.Query{
+		UserID: c.Query("user_id"),
+		Email:  c.Query("email"),
+		Type:   c.Query("type"),
+		Limit:  defaultAuditSearchLimit,
+	}
+	if q.UserID == "" && q.Email == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "user_id or email is required",
+		})
+		return
+	}
+
+	valid := true
+	if since := c.Query("since"); since != "" {
+		var err error
+		q.Since, err = time.Parse(time.RFC3339, since)
+		valid = err == nil
+	}
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		q.Limit, err = strconv.Atoi(limit)
+		valid = valid && err == nil && q.Limit >= 1 && q.Limit <= maxAuditSearchLimit
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_parameter",
+			Message: fmt.Sprintf("since must be RFC 3339 and limit 1-%d", maxAuditSearchLimit),
+		})
+		return
+	}
+
+	events, err := h.auth.audit.Search(c.Request.Context(), q)
+	h.record(c.Request.Context(), auditAdminEventsSearched, caller, q.UserID, err, nil)
+	if err != nil {
+		log.Printf("Audit search failed: %v", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "audit_search_failed",
+			Message: "Failed to search audit events",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+	})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/audit"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSearchEvents(t *testing.T) {
+	h, p, _ := newAdminHandler(t)
+	root := signedIn(t, p, "root@example.com")
+	alice := signedIn(t, p, "alice@example.com")
+
+	ctx := context.Background()
+	since := time.Now().UTC()
+	h.auth.audit.Record(ctx, audit.Event{Type: auditSignIn, Email: "alice@example.com", Outcome: audit.OutcomeFailure, Time: since.Add(-time.Minute)})
+	h.auth.audit.Record(ctx, audit.Event{Type: auditSignIn, UserID: alice.User.ID, Email: "alice@example.com", Outcome: audit.OutcomeSuccess, Time: since})
+	h.auth.audit.Record(ctx, audit.Event{Type: auditSignOut, UserID: alice.User.ID, Email: "alice@example.com", Outcome: audit.OutcomeSuccess, Time: since.Add(time.Second)})
+
+	tests := []struct {
+		name      string
+		query     url.Values
+		wantCode  int
+		wantTypes []string
+	}{
+		// Searches are audited under the user searched for, so only the
+		// first case sees none
+		{"by user", url.Values{"user_id": {alice.User.ID}}, http.StatusOK, []string{auditSignOut, auditSignIn}},
+		{"by email", url.Values{"email": {"Alice@example.com"}}, http.StatusOK, []string{auditSignOut, auditSignIn, auditSignIn}},
+		{"by type", url.Values{"user_id": {alice.User.ID}, "type": {auditSignIn}}, http.StatusOK, []string{auditSignIn}},
+		{"since", url.Values{"email": {"alice@example.com"}, "since": {since.Format(time.RFC3339Nano)}}, http.StatusOK, []string{auditSignOut, auditSignIn}},
+		{"limit", url.Values{"email": {"alice@example.com"}, "limit": {"1"}}, http.StatusOK, []string{auditSignOut}},
+		{"no user", url.Values{"type": {auditSignIn}}, http.StatusBadRequest, nil},
+		{"invalid since", url.Values{"user_id": {alice.User.ID}, "since": {"yesterday"}}, http.StatusBadRequest, nil},
+		{"limit too large", url.Values{"user_id": {alice.User.ID}, "limit": {"501"}}, http.StatusBadRequest, nil},
+	}
+
+	r := gin.New()
+	r.GET("/admin/audit/events", append(h.Middleware(), h.SearchEvents)...)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/audit/events?"+tt.query.Encode(), nil)
+			req.Header.Set("Authorization", "Bearer "+root.AccessToken)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Events []audit.Event `json:"events"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			var types []string
+			for _, event := range resp.Events {
+				types = append(types, event.Type)
+			}
+			if len(types) != len(tt.wantTypes) {
+				t.Fatalf("events = %v, want %v", types, tt.wantTypes)
+			}
+			for i := range types {
+				if types[i] != tt.wantTypes[i] {
+					t.Errorf("events = %v, want %v", types, tt.wantTypes)
+					break
+				}
+			}
+		})
+	}
+
+	// Non-admins can't search
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit/events?user_id="+alice.User.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+alice.AccessToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("non-admin status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
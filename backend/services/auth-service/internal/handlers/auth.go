@@ -1,22 +1,48 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/ec-recommend/auth-service/internal/audit"
+	"github.com/ec-recommend/auth-service/internal/captcha"
 	"github.com/ec-recommend/auth-service/internal/cognito"
 	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/ec-recommend/auth-service/internal/local"
+	"github.com/ec-recommend/auth-service/internal/lockout"
 	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
-	cognitoClient *cognito.Client
-	jwtValidator  interface{
-		ValidateToken(string) (*jwt.Claims, error)
+	provider     IdentityProvider
+	profiles     *profileCache
+	jwtValidator interface {
+		ValidateToken(string, ...jwt.Requirement) (*jwt.Claims, error)
 		RefreshJWKS() error
 	}
+	denylist jwt.Denylist
+	signins  *lockout.Limiter
+	// captcha verifies the CAPTCHA signins asks for; nil when it never does
+	captcha *captcha.Verifier
+	// sessions is set in cookie session mode
+	sessions *sessionCookies
+	// proofSecret signs custom auth challenge answers (passkeys, social login)
+	proofSecret []byte
+	audit       *audit.Recorder
+	// mfaMaxAuthAge is how recent a sign-in must be to disable MFA
+	mfaMaxAuthAge time.Duration
 }
 
+// buyerPool names the primary user pool in Claims.Pool
+const buyerPool = "buyer"
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
@@ -33,30 +59,164 @@ func NewAuthHandler() (*AuthHandler, error) {
 		region = "us-east-1"
 	}
 
-	cognitoClient, err := cognito.NewClient(userPoolID, clientID)
-	if err != nil {
-		return nil, err
+	// Revocations go to Redis when it is configured, so other services
+	// using the shared auth middleware reject revoked tokens too
+	var denylist jwt.Denylist = jwt.NewMemoryDenylist()
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		redisDenylist, err := jwt.NewRedisDenylist(redisURL)
+		if err != nil {
+			return nil, err
+		}
+		denylist = redisDenylist
+	}
+	opts := []jwt.Option{jwt.WithDenylist(denylist)}
+
+	proofSecret := []byte(os.Getenv("PASSKEY_PROOF_SECRET"))
+	if len(proofSecret) == 0 {
+		// Without a shared secret Cognito's verify trigger cannot check proofs,
+		// so this only suits local development
+		log.Println("WARNING: PASSKEY_PROOF_SECRET is not set, using a random per-process secret")
+		proofSecret = make([]byte, 32)
+		if _, err := rand.Read(proofSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	// IDENTITY_PROVIDER=local runs against an in-memory user store instead
+	// of Cognito, validating tokens against the local provider's own keys
+	var provider IdentityProvider
+	switch os.Getenv("IDENTITY_PROVIDER") {
+	case "", "cognito":
+		cognitoClient, err := cognito.NewClient(userPoolID, clientID)
+		if err != nil {
+			return nil, err
+		}
+		provider = cognitoClient
+	case "local":
+		localProvider, err := local.NewProvider(local.Config{
+			UserPoolID:  userPoolID,
+			Region:      region,
+			ClientID:    clientID,
+			AutoConfirm: os.Getenv("LOCAL_AUTO_CONFIRM") == "true",
+			AdminEmails: splitList(os.Getenv("LOCAL_ADMIN_EMAILS")),
+			ProofSecret: proofSecret,
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Using the local in-memory identity provider; users are lost on restart")
+		provider = localProvider
+		opts = append(opts, jwt.WithKeySource(localProvider.JWKS))
+	default:
+		return nil, fmt.Errorf("unknown IDENTITY_PROVIDER %q", os.Getenv("IDENTITY_PROVIDER"))
 	}
 
-	// Use mock validator if COGNITO_ENDPOINT is set (indicating mock environment)
-	var jwtValidator interface{ 
-		ValidateToken(string) (*jwt.Claims, error)
+	// JWT_VALIDATION_MODE=test verifies tokens against a local key pair
+	// (JWT_TEST_PRIVATE_KEY_FILE, or a generated one) instead of the pool's
+	// JWKS; unverified skips signature checks entirely and is only for mocks
+	var jwtValidator interface {
+		ValidateToken(string, ...jwt.Requirement) (*jwt.Claims, error)
 		RefreshJWKS() error
 	}
-	
-	if os.Getenv("COGNITO_ENDPOINT") != "" {
-		jwtValidator = jwt.NewMockValidator(userPoolID, region, clientID)
-	} else {
+
+	_, isLocal := provider.(*local.Provider)
+	mode := os.Getenv("JWT_VALIDATION_MODE")
+
+	switch {
+	case isLocal, mode == "", mode == "cognito":
+		// Keys come from the pool's JWKS or the local provider
+	case mode == "test":
+		var signer *jwt.TestSigner
 		var err error
-		jwtValidator, err = jwt.NewValidator(userPoolID, region, clientID)
+		if keyFile := os.Getenv("JWT_TEST_PRIVATE_KEY_FILE"); keyFile != "" {
+			signer, err = jwt.LoadTestSigner(keyFile, userPoolID, region, clientID)
+		} else {
+			signer, err = jwt.NewTestSigner(userPoolID, region, clientID)
+		}
 		if err != nil {
 			return nil, err
 		}
+		log.Println("JWT validation uses a test key pair, not the Cognito JWKS")
+		opts = append(opts, jwt.WithKeySource(signer.JWKS))
+	case mode == "unverified":
+		var err error
+		jwtValidator, err = jwt.NewUnverifiedValidator(userPoolID, region, clientID, denylist)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown JWT_VALIDATION_MODE %q", mode)
+	}
+
+	if jwtValidator == nil {
+		if d, err := time.ParseDuration(os.Getenv("JWKS_REFRESH_INTERVAL")); err == nil {
+			opts = append(opts, jwt.WithRefreshInterval(d))
+		}
+		if d, err := time.ParseDuration(os.Getenv("JWKS_STALE_KEY_GRACE")); err == nil {
+			opts = append(opts, jwt.WithStaleKeyGrace(d))
+		}
+		if d, err := time.ParseDuration(os.Getenv("JWT_LEEWAY")); err == nil {
+			opts = append(opts, jwt.WithPolicy(jwt.Leeway(d)))
+		}
+		// Extra app clients of the primary pool whose tokens are accepted
+		if extra := os.Getenv("JWT_ALLOWED_CLIENT_IDS"); extra != "" {
+			opts = append(opts, jwt.WithClientIDs(strings.Split(extra, ",")...))
+		}
+
+		// Sellers and admins may sign in through pools of their own
+		opts = append(opts, jwt.WithPoolName(buyerPool))
+		for _, realm := range []string{"seller", "admin"} {
+			prefix := "COGNITO_" + strings.ToUpper(realm) + "_"
+			if poolID := os.Getenv(prefix + "USER_POOL_ID"); poolID != "" {
+				opts = append(opts, jwt.WithPool(jwt.Pool{
+					Name:       realm,
+					UserPoolID: poolID,
+					Region:     region,
+					ClientIDs:  strings.Split(os.Getenv(prefix+"CLIENT_ID"), ","),
+				}))
+			}
+		}
+
+		var err error
+		jwtValidator, err = jwt.NewValidator(userPoolID, region, clientID, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	profileCacheTTL, _ := time.ParseDuration(os.Getenv("PROFILE_CACHE_TTL"))
+
+	mfaMaxAuthAge, err := durationEnv("MFA_DISABLE_MAX_AUTH_AGE", defaultMFADisableMaxAuthAge)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder, err := newAuditRecorder()
+	if err != nil {
+		return nil, err
+	}
+
+	signins, captchaVerifier, err := newSignInLimiter(recorder)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := newSessionCookies()
+	if err != nil {
+		return nil, err
 	}
 
 	return &AuthHandler{
-		cognitoClient: cognitoClient,
+		provider:      provider,
+		profiles:      newProfileCache(profileCacheTTL),
 		jwtValidator:  jwtValidator,
+		denylist:      denylist,
+		signins:       signins,
+		captcha:       captchaVerifier,
+		sessions:      sessions,
+		proofSecret:   proofSecret,
+		audit:         recorder,
+		mfaMaxAuthAge: mfaMaxAuthAge,
 	}, nil
 }
 
@@ -78,12 +238,10 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 		return
 	}
 
-	err := h.cognitoClient.SignUp(c.Request.Context(), req)
+	err := h.provider.SignUp(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "signup_failed",
-			Message: err.Error(),
-		})
+		status, resp := cognitoErrorResponse(err, "signup_failed", "Failed to sign up")
+		c.JSON(status, resp)
 		return
 	}
 
@@ -93,7 +251,11 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 }
 
 func (h *AuthHandler) SignIn(c *gin.Context) {
-	var req cognito.SignInRequest
+	var req struct {
+		cognito.SignInRequest
+		// CaptchaToken answers the CAPTCHA once a response had captchaRequired
+		CaptchaToken string `json:"captchaToken"`
+	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
@@ -110,16 +272,162 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 		return
 	}
 
-	authResponse, err := h.cognitoClient.SignIn(c.Request.Context(), req)
+	// The attempt counts against the limits until it's known not to have
+	// failed, so concurrent guesses can't all get in under them
+	ctx := c.Request.Context()
+	decision := h.signins.Reserve(ctx, req.Email, c.ClientIP())
+	if !decision.Allowed {
+		h.recordAuth(c, auditSignIn, "", req.Email, "throttled", nil)
+		signInThrottled(c, decision)
+		return
+	}
+
+	if decision.CaptchaRequired && h.captcha != nil {
+		passed, err := h.captcha.Verify(ctx, req.CaptchaToken, c.ClientIP())
+		if err != nil && !errors.Is(err, captcha.ErrMissingToken) {
+			h.signins.Release(ctx, req.Email, c.ClientIP())
+			log.Printf("CAPTCHA verification failed: %v", err)
+			c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "captcha_unavailable",
+				Message: "The CAPTCHA could not be checked, try again later",
+			})
+			return
+		}
+		if !passed {
+			h.signins.Release(ctx, req.Email, c.ClientIP())
+			h.recordAuth(c, auditSignIn, "", req.Email, "captcha_failed", nil)
+			signInCaptchaFailed(c)
+			return
+		}
+	}
+
+	authResponse, challenge, err := h.provider.SignIn(ctx, req.SignInRequest)
+	if err != nil {
+		// The audit trail keeps the real reason, such as user_not_found
+		_, reason := cognitoErrorResponse(err, "signin_failed", "")
+		h.recordAuth(c, auditSignIn, "", req.Email, reason.Error, nil)
+
+		// Unknown users and wrong passwords look the same to the client
+		if errors.Is(err, cognito.ErrNotAuthorized) || errors.Is(err, cognito.ErrUserNotFound) {
+			decision := h.signins.Failure(ctx, req.Email, c.ClientIP())
+			c.JSON(http.StatusUnauthorized, signInErrorResponse{
+				ErrorResponse: ErrorResponse{
+					Error:   "signin_failed",
+					Message: "Invalid email or password",
+				},
+				CaptchaRequired: decision.CaptchaRequired,
+			})
+			return
+		}
+		h.signins.Release(ctx, req.Email, c.ClientIP())
+		status, resp := cognitoErrorResponse(err, "signin_failed", "Failed to sign in")
+		if status == http.StatusBadRequest {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, resp)
+		return
+	}
+
+	// The password was right, but the email's failures are only cleared
+	// once any MFA challenge is answered too
+	h.signins.Release(ctx, req.Email, c.ClientIP())
+
+	if challenge != nil {
+		h.respondWithChallenge(c, challenge, req.Email)
+		return
+	}
+
+	h.signins.Success(ctx, req.Email)
+	h.recordAuth(c, auditSignIn, authResponse.User.ID, req.Email, "", nil)
+	h.respondWithSession(c, authResponse)
+}
+
+// RespondToChallenge answers a challenge returned by SignIn and finishes
+// with the normal AuthResponse, or the next challenge
+func (h *AuthHandler) RespondToChallenge(c *gin.Context) {
+	responder, ok := h.provider.(challengeResponder)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	var req cognito.ChallengeAnswer
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.ChallengeName == "" || req.Session == "" || req.Email == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Challenge name, session and email are required",
+		})
+		return
+	}
+
+	if req.NewPassword == "" && req.Code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "A new password or code is required",
+		})
+		return
+	}
+
+	required, err := h.unbindRequiredAttributes(&req)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "signin_failed",
-			Message: "Invalid email or password",
+			Error:   "challenge_failed",
+			Message: "Invalid or expired session",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, authResponse)
+	// Only attributes the challenge asked for, and that users may set
+	// themselves, can be supplied with a new password
+	if rejected := disallowedChallengeAttributes(req.Attributes, required); len(rejected) > 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "attribute_not_allowed",
+			Message: "These attributes cannot be set: " + strings.Join(rejected, ", "),
+		})
+		return
+	}
+
+	authResponse, challenge, err := responder.RespondToChallenge(c.Request.Context(), req)
+	details := map[string]string{"challenge": req.ChallengeName}
+	if err != nil {
+		_, reason := cognitoErrorResponse(err, "challenge_failed", "")
+		h.recordAuth(c, auditChallenge, "", req.Email, reason.Error, details)
+
+		if errors.Is(err, cognito.ErrUnsupportedChallenge) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "unsupported_challenge",
+				Message: "Unsupported challenge",
+			})
+			return
+		}
+		if errors.Is(err, cognito.ErrNotAuthorized) {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "challenge_failed",
+				Message: "Invalid or expired session",
+			})
+			return
+		}
+		status, resp := cognitoErrorResponse(err, "challenge_failed", "Failed to answer challenge")
+		c.JSON(status, resp)
+		return
+	}
+
+	if challenge != nil {
+		h.respondWithChallenge(c, challenge, req.Email)
+		return
+	}
+
+	h.signins.Success(c.Request.Context(), authResponse.User.Email)
+	h.recordAuth(c, auditChallenge, authResponse.User.ID, req.Email, "", details)
+	h.respondWithSession(c, authResponse)
 }
 
 func (h *AuthHandler) ConfirmSignUp(c *gin.Context) {
@@ -136,15 +444,16 @@ func (h *AuthHandler) ConfirmSignUp(c *gin.Context) {
 		return
 	}
 
-	err := h.cognitoClient.ConfirmSignUp(c.Request.Context(), req.Email, req.ConfirmationCode)
+	err := h.provider.ConfirmSignUp(c.Request.Context(), req.Email, req.ConfirmationCode)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "confirmation_failed",
-			Message: err.Error(),
-		})
+		status, resp := cognitoErrorResponse(err, "confirmation_failed", "Failed to confirm sign up")
+		h.recordAuth(c, auditConfirm, "", req.Email, resp.Error, nil)
+		c.JSON(status, resp)
 		return
 	}
 
+	h.recordAuth(c, auditConfirm, "", req.Email, "", nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Email confirmed successfully",
 	})
@@ -155,7 +464,8 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		RefreshToken string `json:"refreshToken"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// In cookie mode the body may be empty and the refresh cookie is used
+	if err := c.ShouldBindJSON(&req); err != nil && !(errors.Is(err, io.EOF) && h.sessions != nil) {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
 			Message: "Invalid request body",
@@ -163,8 +473,27 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	authResponse, err := h.cognitoClient.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if req.RefreshToken == "" {
+		req.RefreshToken = h.refreshTokenCookie(c)
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Refresh token is required",
+		})
+		return
+	}
+
+	authResponse, err := h.provider.RefreshToken(c.Request.Context(), req.RefreshToken)
 	if err != nil {
+		_, reason := cognitoErrorResponse(err, "refresh_failed", "")
+		h.recordAuth(c, auditRefresh, "", "", reason.Error, nil)
+
+		if errors.Is(err, cognito.ErrTooManyRequests) {
+			status, resp := cognitoErrorResponse(err, "refresh_failed", "Invalid refresh token")
+			c.JSON(status, resp)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "refresh_failed",
 			Message: "Invalid refresh token",
@@ -172,7 +501,8 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, authResponse)
+	h.recordAuth(c, auditRefresh, authResponse.User.ID, "", "", nil)
+	h.respondWithSession(c, authResponse)
 }
 
 func (h *AuthHandler) ValidateToken(c *gin.Context) {
@@ -187,10 +517,9 @@ func (h *AuthHandler) ValidateToken(c *gin.Context) {
 
 	claims, err := h.jwtValidator.ValidateToken(authHeader)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "invalid_token",
-			Message: err.Error(),
-		})
+		h.recordTokenRejected(c, err)
+		status, resp := tokenErrorResponse(err)
+		c.JSON(status, resp)
 		return
 	}
 
@@ -200,33 +529,188 @@ func (h *AuthHandler) ValidateToken(c *gin.Context) {
 			"id":       claims.Username,
 			"email":    claims.Email,
 			"clientId": claims.ClientID,
+			"pool":     claims.Pool,
 		},
 	})
 }
 
+// GetCurrentUser returns the signed-in user's profile. Access tokens are
+// resolved through the identity provider (cached); ID tokens carry the
+// attributes themselves.
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "missing_token",
-			Message: "Authorization header is required",
+	claims, ok := h.requireToken(c)
+	if !ok {
+		return
+	}
+
+	accessToken := strings.TrimPrefix(h.bearerToken(c), "Bearer ")
+
+	user := profileFromClaims(claims)
+	if claims.TokenUse == "access" {
+		var err error
+		user, err = h.currentUser(c.Request.Context(), claims, accessToken)
+		if err != nil {
+			status, resp := cognitoErrorResponse(err, "user_lookup_failed", "Failed to load user profile")
+			c.JSON(status, resp)
+			return
+		}
+	}
+
+	response := gin.H{
+		"id":            user.ID,
+		"email":         user.Email,
+		"emailVerified": user.EmailVerified,
+		"name":          user.Name,
+		"attributes":    user.Attributes,
+	}
+
+	// MFA status comes from Cognito, which only accepts access tokens
+	if mfa, ok := h.provider.(mfaManager); ok && claims.TokenUse == "access" {
+		if mfaStatus, err := mfa.GetMFAStatus(c.Request.Context(), accessToken); err == nil {
+			response["mfa"] = mfaStatus
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SignOut revokes the refresh token and the presented access token
+func (h *AuthHandler) SignOut(c *gin.Context) {
+	claims, ok := h.requireToken(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	// The body is optional; without a refresh token only the access token is revoked
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
 		})
 		return
 	}
 
-	claims, err := h.jwtValidator.ValidateToken(authHeader)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "invalid_token",
-			Message: err.Error(),
+	if req.RefreshToken == "" {
+		req.RefreshToken = h.refreshTokenCookie(c)
+	}
+
+	if revoker, ok := h.provider.(sessionRevoker); ok && req.RefreshToken != "" {
+		if err := revoker.RevokeToken(c.Request.Context(), req.RefreshToken); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "signout_failed",
+				Message: "Failed to revoke refresh token",
+			})
+			return
+		}
+	}
+
+	h.denylist.RevokeToken(claims.ID, time.Unix(claims.ExpTime, 0))
+	h.clearSession(c)
+	h.recordAuth(c, auditSignOut, claims.Subject, "", "", nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Signed out successfully",
+	})
+}
+
+// SignOutAll signs the user out of every session and revokes all of their
+// outstanding tokens
+func (h *AuthHandler) SignOutAll(c *gin.Context) {
+	revoker, ok := h.provider.(sessionRevoker)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	claims, accessToken, ok := h.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	if err := revoker.GlobalSignOut(c.Request.Context(), accessToken); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "signout_failed",
+			Message: "Failed to sign out of all sessions",
 		})
 		return
 	}
 
+	h.denylist.RevokeSubject(claims.Subject, time.Now())
+	h.clearSession(c)
+	h.recordAuth(c, auditSignOut, claims.Subject, "", "", map[string]string{"scope": "all"})
+
 	c.JSON(http.StatusOK, gin.H{
-		"id":            claims.Username,
-		"email":         claims.Email,
-		"emailVerified": claims.EmailVerified,
-		"attributes":    map[string]string{},
+		"message": "Signed out of all sessions",
 	})
-}
\ No newline at end of file
+}
+
+// revokeTokens rejects every token issued to subject so far and drops its
+// cached profile, so the next refresh reflects any change to the user
+func (h *AuthHandler) revokeTokens(subject string) {
+	h.denylist.RevokeSubject(subject, time.Now())
+	h.profiles.invalidate(subject)
+}
+
+// errMissingToken is returned by bearerClaims when the request has no token
+var errMissingToken = errors.New("missing token")
+
+// bearerClaims validates the request's bearer token against reqs
+func (h *AuthHandler) bearerClaims(c *gin.Context, reqs ...jwt.Requirement) (*jwt.Claims, error) {
+	token := h.bearerToken(c)
+	if token == "" {
+		return nil, errMissingToken
+	}
+
+	return h.jwtValidator.ValidateToken(token, reqs...)
+}
+
+// requireToken validates the bearer token, writing an error response on failure
+func (h *AuthHandler) requireToken(c *gin.Context, reqs ...jwt.Requirement) (*jwt.Claims, bool) {
+	claims, err := h.bearerClaims(c, reqs...)
+	if err != nil {
+		h.recordTokenRejected(c, err)
+		writeTokenError(c, err)
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// requireAccessToken is requireToken for routes that call Cognito on the
+// user's behalf, which only accepts access tokens
+func (h *AuthHandler) requireAccessToken(c *gin.Context, reqs ...jwt.Requirement) (*jwt.Claims, string, bool) {
+	claims, err := h.bearerClaims(c, append([]jwt.Requirement{jwt.TokenUses("access")}, reqs...)...)
+	if err != nil {
+		h.recordTokenRejected(c, err)
+	}
+	if errors.Is(err, jwt.ErrInvalidTokenUse) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "access_token_required",
+			Message: "An access token is required",
+		})
+		return nil, "", false
+	}
+	if err != nil {
+		writeTokenError(c, err)
+		return nil, "", false
+	}
+
+	return claims, strings.TrimPrefix(h.bearerToken(c), "Bearer "), true
+}
+
+func writeTokenError(c *gin.Context, err error) {
+	if errors.Is(err, errMissingToken) {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "missing_token",
+			Message: "Authorization header is required",
+		})
+		return
+	}
+
+	status, resp := tokenErrorResponse(err)
+	c.JSON(status, resp)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/gin-gonic/gin"
+)
+
+// boundSessionPrefix marks a challenge session that carries the attributes
+// its NEW_PASSWORD_REQUIRED challenge asked for
+const boundSessionPrefix = "ra1."
+
+var errInvalidChallengeSession = errors.New("invalid challenge session")
+
+// boundSession is the signed payload of a bound session
+type boundSession struct {
+	Session  string   `json:"s"`
+	Required []string `json:"r"`
+}
+
+// bindRequiredAttributes replaces a NEW_PASSWORD_REQUIRED challenge's
+// session with one that also carries, signed, the attributes Cognito asked
+// for, so the answer can be checked against them on any instance
+func (h *AuthHandler) bindRequiredAttributes(challenge *cognito.Challenge) error {
+	if challenge.ChallengeName != cognito.ChallengeNewPasswordRequired {
+		return nil
+	}
+
+	// requiredAttributes is a JSON array of names like "userAttributes.name"
+	var required []string
+	if value := challenge.Parameters["requiredAttributes"]; value != "" {
+		if err := json.Unmarshal([]byte(value), &required); err != nil {
+			return err
+		}
+	}
+	for i, name := range required {
+		required[i] = strings.TrimPrefix(name, "userAttributes.")
+	}
+
+	payload, err := json.Marshal(boundSession{Session: challenge.Session, Required: required})
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	challenge.Session = boundSessionPrefix + encoded + "." + h.sessionMAC(encoded)
+	return nil
+}
+
+// respondWithChallenge sends the client the next sign-in step
+func (h *AuthHandler) respondWithChallenge(c *gin.Context, challenge *cognito.Challenge, email string) {
+	if err := h.bindRequiredAttributes(challenge); err != nil {
+		log.Printf("Failed to bind challenge session: %v", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "signin_failed",
+			Message: "Failed to sign in",
+		})
+		return
+	}
+
+	h.recordAuth(c, auditChallengeIssued, "", email, "", map[string]string{"challenge": challenge.ChallengeName})
+	c.JSON(http.StatusOK, challenge)
+}
+
+// unbindRequiredAttributes restores the Cognito session of an answer and
+// returns the attributes its challenge required. Sessions that were never
+// bound require none.
+func (h *AuthHandler) unbindRequiredAttributes(answer *cognito.ChallengeAnswer) ([]string, error) {
+	if !strings.HasPrefix(answer.Session, boundSessionPrefix) {
+		return nil, nil
+	}
+
+	encoded, mac, found := strings.Cut(strings.TrimPrefix(answer.Session, boundSessionPrefix), ".")
+	if !found || !hmac.Equal([]byte(mac), []byte(h.sessionMAC(encoded))) {
+		return nil, errInvalidChallengeSession
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidChallengeSession
+	}
+	var bound boundSession
+	if err := json.Unmarshal(payload, &bound); err != nil {
+		return nil, errInvalidChallengeSession
+	}
+
+	answer.Session = bound.Session
+	return bound.Required, nil
+}
+
+func (h *AuthHandler) sessionMAC(encoded string) string {
+	mac := hmac.New(sha256.New, h.proofSecret)
+	mac.Write([]byte("challenge-session." + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// disallowedChallengeAttributes returns the answer's attributes that were
+// not required by the challenge or are not on the self-update allow-list,
+// sorted
+func disallowedChallengeAttributes(attributes map[string]string, required []string) []string {
+	allowed := updatableAttributes()
+	requested := make(map[string]bool, len(required))
+	for _, name := range required {
+		requested[name] = true
+	}
+
+	var rejected []string
+	for name := range attributes {
+		if !requested[name] || !allowed[name] {
+			rejected = append(rejected, name)
+		}
+	}
+	sort.Strings(rejected)
+	return rejected
+}
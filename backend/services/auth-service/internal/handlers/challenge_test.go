@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+)
+
+func TestChallengeSessionBinding(t *testing.T) {
+	h := &AuthHandler{proofSecret: []byte("test-secret")}
+
+	challenge := &cognito.Challenge{
+		ChallengeName: cognito.ChallengeNewPasswordRequired,
+		Session:       "cognito-session",
+		Parameters: map[string]string{
+			"requiredAttributes": `["userAttributes.name","userAttributes.custom:seller_id"]`,
+		},
+	}
+	if err := h.bindRequiredAttributes(challenge); err != nil {
+		t.Fatalf("bindRequiredAttributes: %v", err)
+	}
+	if !strings.HasPrefix(challenge.Session, boundSessionPrefix) {
+		t.Fatalf("Session = %q, want a bound session", challenge.Session)
+	}
+	bound := challenge.Session
+
+	// Flip one character of the signed payload
+	payload := []byte(strings.TrimPrefix(bound, boundSessionPrefix))
+	payload[0] ^= 1
+	tampered := boundSessionPrefix + string(payload)
+
+	otherKey := &AuthHandler{proofSecret: []byte("other-secret")}
+
+	tests := []struct {
+		name         string
+		handler      *AuthHandler
+		session      string
+		wantSession  string
+		wantRequired []string
+		wantErr      error
+	}{
+		{"bound session", h, bound, "cognito-session", []string{"name", "custom:seller_id"}, nil},
+		{"unbound session", h, "plain-session", "plain-session", nil, nil},
+		{"tampered", h, tampered, "", nil, errInvalidChallengeSession},
+		{"signed by another key", otherKey, bound, "", nil, errInvalidChallengeSession},
+		{"no signature", h, boundSessionPrefix + "payload", "", nil, errInvalidChallengeSession},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := cognito.ChallengeAnswer{Session: tt.session}
+			required, err := tt.handler.unbindRequiredAttributes(&answer)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unbindRequiredAttributes() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if answer.Session != tt.wantSession {
+				t.Errorf("Session = %q, want %q", answer.Session, tt.wantSession)
+			}
+			if strings.Join(required, ",") != strings.Join(tt.wantRequired, ",") {
+				t.Errorf("required = %v, want %v", required, tt.wantRequired)
+			}
+		})
+	}
+}
+
+func TestBindRequiredAttributesLeavesOtherChallenges(t *testing.T) {
+	h := &AuthHandler{proofSecret: []byte("test-secret")}
+
+	challenge := &cognito.Challenge{ChallengeName: "SOFTWARE_TOKEN_MFA", Session: "cognito-session"}
+	if err := h.bindRequiredAttributes(challenge); err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Session != "cognito-session" {
+		t.Errorf("Session = %q, want it unchanged", challenge.Session)
+	}
+}
+
+func TestDisallowedChallengeAttributes(t *testing.T) {
+	t.Setenv("PROFILE_CUSTOM_ATTRIBUTES", "custom:newsletter")
+
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		required   []string
+		want       []string
+	}{
+		{"none", nil, []string{"name"}, nil},
+		{"required and updatable", map[string]string{"name": "Alice", "custom:newsletter": "yes"}, []string{"name", "custom:newsletter"}, nil},
+		{"not required", map[string]string{"name": "Alice", "locale": "ja"}, []string{"name"}, []string{"locale"}},
+		{"required but protected", map[string]string{"custom:seller_id": "s-1"}, []string{"custom:seller_id"}, []string{"custom:seller_id"}},
+		{"required but not allow-listed", map[string]string{"custom:tier": "gold"}, []string{"custom:tier"}, []string{"custom:tier"}},
+		{"nothing required", map[string]string{"name": "Alice", "email": "a@example.com"}, nil, []string{"email", "name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := disallowedChallengeAttributes(tt.attributes, tt.required)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("disallowedChallengeAttributes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
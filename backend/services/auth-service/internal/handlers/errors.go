@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+)
+
+// cognitoErrorResponse maps typed Cognito errors to a stable error code and
+// HTTP status, falling back to the given code and message so raw SDK
+// messages never reach the client
+func cognitoErrorResponse(err error, fallbackCode, fallbackMessage string) (int, ErrorResponse) {
+	switch {
+	case errors.Is(err, cognito.ErrCodeExpired):
+		return http.StatusBadRequest, ErrorResponse{Error: "code_expired", Message: "The code has expired, request a new one"}
+	case errors.Is(err, cognito.ErrCodeMismatch):
+		return http.StatusBadRequest, ErrorResponse{Error: "code_mismatch", Message: "The code is incorrect"}
+	case errors.Is(err, cognito.ErrLimitExceeded):
+		return http.StatusTooManyRequests, ErrorResponse{Error: "limit_exceeded", Message: "Too many attempts, try again later"}
+	case errors.Is(err, cognito.ErrTooManyRequests):
+		return http.StatusTooManyRequests, ErrorResponse{Error: "too_many_requests", Message: "Too many requests, try again later"}
+	case errors.Is(err, cognito.ErrTooManyFailedAttempts):
+		return http.StatusTooManyRequests, ErrorResponse{Error: "too_many_failed_attempts", Message: "Too many failed attempts, try again later"}
+	case errors.Is(err, cognito.ErrInvalidPassword):
+		return http.StatusBadRequest, ErrorResponse{Error: "invalid_password", Message: "Password does not meet the password policy"}
+	case errors.Is(err, cognito.ErrNotAuthorized):
+		return http.StatusUnauthorized, ErrorResponse{Error: "not_authorized", Message: "The request is not authorized"}
+	case errors.Is(err, cognito.ErrUsernameExists):
+		return http.StatusConflict, ErrorResponse{Error: "username_exists", Message: "An account with this email already exists"}
+	case errors.Is(err, cognito.ErrAliasExists):
+		return http.StatusConflict, ErrorResponse{Error: "alias_exists", Message: "This email is already in use by another account"}
+	case errors.Is(err, cognito.ErrUserNotConfirmed):
+		return http.StatusForbidden, ErrorResponse{Error: "user_not_confirmed", Message: "Please confirm your email before signing in"}
+	case errors.Is(err, cognito.ErrPasswordResetRequired):
+		return http.StatusForbidden, ErrorResponse{Error: "password_reset_required", Message: "A password reset is required"}
+	case errors.Is(err, cognito.ErrUserNotFound):
+		return http.StatusNotFound, ErrorResponse{Error: "user_not_found", Message: "User not found"}
+	case errors.Is(err, cognito.ErrInvalidParameter):
+		return http.StatusBadRequest, ErrorResponse{Error: "invalid_parameter", Message: "One or more parameters are invalid"}
+	}
+
+	return http.StatusBadRequest, ErrorResponse{Error: fallbackCode, Message: fallbackMessage}
+}
+
+// tokenErrorResponse maps token validation failures to a stable error code.
+// Everything is a 401 except a missing scope, where the caller is known but
+// not allowed.
+func tokenErrorResponse(err error) (int, ErrorResponse) {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return http.StatusUnauthorized, ErrorResponse{Error: "token_expired", Message: "The token has expired"}
+	case errors.Is(err, jwt.ErrTokenRevoked):
+		return http.StatusUnauthorized, ErrorResponse{Error: "token_revoked", Message: "The token has been revoked"}
+	case errors.Is(err, jwt.ErrInvalidTokenUse):
+		return http.StatusUnauthorized, ErrorResponse{Error: "invalid_token_use", Message: "This kind of token is not accepted here"}
+	case errors.Is(err, jwt.ErrInvalidAudience):
+		return http.StatusUnauthorized, ErrorResponse{Error: "invalid_audience", Message: "The token was issued to another client"}
+	case errors.Is(err, jwt.ErrInvalidIssuer):
+		return http.StatusUnauthorized, ErrorResponse{Error: "invalid_issuer", Message: "The token was issued by an unknown issuer"}
+	case errors.Is(err, jwt.ErrAuthTooOld):
+		return http.StatusUnauthorized, ErrorResponse{Error: "reauthentication_required", Message: "Sign in again to continue"}
+	case errors.Is(err, jwt.ErrMissingScope):
+		return http.StatusForbidden, ErrorResponse{Error: "insufficient_scope", Message: "The token lacks a required scope"}
+	}
+	return http.StatusUnauthorized, ErrorResponse{Error: "invalid_token", Message: "The token is invalid"}
+}
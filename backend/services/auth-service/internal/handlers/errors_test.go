@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+)
+
+func TestCognitoErrorResponse(t *testing.T) {
+	tests := []struct {
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		{cognito.ErrCodeExpired, http.StatusBadRequest, "code_expired"},
+		{cognito.ErrCodeMismatch, http.StatusBadRequest, "code_mismatch"},
+		{cognito.ErrLimitExceeded, http.StatusTooManyRequests, "limit_exceeded"},
+		{cognito.ErrTooManyRequests, http.StatusTooManyRequests, "too_many_requests"},
+		{cognito.ErrTooManyFailedAttempts, http.StatusTooManyRequests, "too_many_failed_attempts"},
+		{cognito.ErrInvalidPassword, http.StatusBadRequest, "invalid_password"},
+		{fmt.Errorf("change password: %w", cognito.ErrNotAuthorized), http.StatusUnauthorized, "not_authorized"},
+		{cognito.ErrUsernameExists, http.StatusConflict, "username_exists"},
+		{cognito.ErrAliasExists, http.StatusConflict, "alias_exists"},
+		{cognito.ErrUserNotConfirmed, http.StatusForbidden, "user_not_confirmed"},
+		{cognito.ErrPasswordResetRequired, http.StatusForbidden, "password_reset_required"},
+		{cognito.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+		{cognito.ErrInvalidParameter, http.StatusBadRequest, "invalid_parameter"},
+		// Unrecognized errors never leak their message
+		{errors.New("operation error: connection reset"), http.StatusBadRequest, "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			code, resp := cognitoErrorResponse(tt.err, "fallback", "Fallback message")
+			if code != tt.wantCode || resp.Error != tt.wantErr {
+				t.Errorf("cognitoErrorResponse() = %d %q, want %d %q", code, resp.Error, tt.wantCode, tt.wantErr)
+			}
+			if resp.Message == tt.err.Error() {
+				t.Errorf("Message = %q, want it not to echo the error", resp.Message)
+			}
+			// Only ChangePassword knows a rejection means a wrong password
+			if resp.Message == "Incorrect password" {
+				t.Errorf("Message = %q outside the change-password path", resp.Message)
+			}
+		})
+	}
+}
+
+func TestTokenErrorResponse(t *testing.T) {
+	tests := []struct {
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		{jwt.ErrTokenExpired, http.StatusUnauthorized, "token_expired"},
+		{jwt.ErrTokenRevoked, http.StatusUnauthorized, "token_revoked"},
+		{fmt.Errorf("%w: refresh", jwt.ErrInvalidTokenUse), http.StatusUnauthorized, "invalid_token_use"},
+		{jwt.ErrInvalidAudience, http.StatusUnauthorized, "invalid_audience"},
+		{jwt.ErrInvalidIssuer, http.StatusUnauthorized, "invalid_issuer"},
+		{jwt.ErrAuthTooOld, http.StatusUnauthorized, "reauthentication_required"},
+		// The caller is known but lacks the scope
+		{jwt.ErrMissingScope, http.StatusForbidden, "insufficient_scope"},
+		{jwt.ErrInvalidSignature, http.StatusUnauthorized, "invalid_token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			code, resp := tokenErrorResponse(tt.err)
+			if code != tt.wantCode || resp.Error != tt.wantErr {
+				t.Errorf("tokenErrorResponse() = %d %q, want %d %q", code, resp.Error, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+}
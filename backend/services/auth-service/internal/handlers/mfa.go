@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMFADisableMaxAuthAge makes users sign in again shortly before
+// turning off a second factor, so a stolen token can't remove it
+const defaultMFADisableMaxAuthAge = 15 * time.Minute
+
+// StartTOTPEnrollment associates a new authenticator app and returns its
+// secret as an otpauth:// URI for QR codes
+func (h *AuthHandler) StartTOTPEnrollment(c *gin.Context) {
+	mfa, ok := h.provider.(mfaManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	claims, accessToken, ok := h.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	secret, err := mfa.AssociateSoftwareToken(c.Request.Context(), accessToken)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "mfa_setup_failed", "Failed to start TOTP enrollment")
+		c.JSON(status, resp)
+		return
+	}
+
+	account := claims.Email
+	if account == "" {
+		account = claims.Username
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secretCode": secret,
+		"otpauthUri": otpauthURI(totpIssuer(), account, secret),
+	})
+}
+
+// VerifyTOTPEnrollment checks the first code from the authenticator app and
+// enables TOTP as the preferred MFA method
+func (h *AuthHandler) VerifyTOTPEnrollment(c *gin.Context) {
+	mfa, ok := h.provider.(mfaManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	_, accessToken, ok := h.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Code       string `json:"code"`
+		DeviceName string `json:"deviceName"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.Code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Code is required",
+		})
+		return
+	}
+
+	err := mfa.VerifySoftwareToken(c.Request.Context(), accessToken, req.Code, req.DeviceName)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "mfa_verify_failed", "Failed to verify TOTP code")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "TOTP MFA enabled",
+	})
+}
+
+// GetMFAStatus lists the user's enabled MFA methods
+func (h *AuthHandler) GetMFAStatus(c *gin.Context) {
+	mfa, ok := h.provider.(mfaManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	_, accessToken, ok := h.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	mfaStatus, err := mfa.GetMFAStatus(c.Request.Context(), accessToken)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "mfa_status_failed", "Failed to get MFA status")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, mfaStatus)
+}
+
+// DisableMFAMethod disables an MFA method (totp or sms)
+func (h *AuthHandler) DisableMFAMethod(c *gin.Context) {
+	mfa, ok := h.provider.(mfaManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	_, accessToken, ok := h.requireAccessToken(c, jwt.MaxAuthAge(h.mfaMaxAuthAge))
+	if !ok {
+		return
+	}
+
+	method := c.Param("method")
+	if method != cognito.MFAMethodTOTP && method != cognito.MFAMethodSMS {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_mfa_method",
+			Message: "MFA method must be totp or sms",
+		})
+		return
+	}
+
+	err := mfa.SetMFAPreference(c.Request.Context(), accessToken, method, false)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "mfa_disable_failed", "Failed to disable MFA method")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "MFA method disabled",
+	})
+}
+
+func totpIssuer() string {
+	if issuer := os.Getenv("MFA_TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "ECレコメンド"
+}
+
+// otpauthURI builds a Key URI Format URI understood by authenticator apps
+func otpauthURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + query.Encode()
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/ec-recommend/auth-service/internal/local"
+	"github.com/gin-gonic/gin"
+)
+
+func TestOTPAuthURI(t *testing.T) {
+	got := otpauthURI("ECレコメンド", "alice@example.com", "SECRET234")
+
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("otpauthURI() = %q: %v", got, err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("otpauthURI() = %q, want otpauth://totp/...", got)
+	}
+	if label := strings.TrimPrefix(u.Path, "/"); label != "ECレコメンド:alice@example.com" {
+		t.Errorf("label = %q", label)
+	}
+	if q := u.Query(); q.Get("secret") != "SECRET234" || q.Get("issuer") != "ECレコメンド" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestTOTPIssuer(t *testing.T) {
+	t.Setenv("MFA_TOTP_ISSUER", "")
+	if got := totpIssuer(); got != "ECレコメンド" {
+		t.Errorf("default totpIssuer() = %q", got)
+	}
+
+	t.Setenv("MFA_TOTP_ISSUER", "Shop Staging")
+	if got := totpIssuer(); got != "Shop Staging" {
+		t.Errorf("totpIssuer() = %q, want MFA_TOTP_ISSUER", got)
+	}
+}
+
+// mfaProvider adds an in-memory TOTP enrollment to the local provider
+type mfaProvider struct {
+	*local.Provider
+	verified bool
+}
+
+func (p *mfaProvider) AssociateSoftwareToken(ctx context.Context, accessToken string) (string, error) {
+	if _, err := p.GetUser(ctx, accessToken); err != nil {
+		return "", err
+	}
+	return "SECRET234", nil
+}
+
+func (p *mfaProvider) VerifySoftwareToken(ctx context.Context, accessToken, code, deviceName string) error {
+	if code != "123456" {
+		return cognito.ErrCodeMismatch
+	}
+	p.verified = true
+	return nil
+}
+
+func (p *mfaProvider) SetMFAPreference(ctx context.Context, accessToken, method string, enabled bool) error {
+	p.verified = enabled
+	return nil
+}
+
+func (p *mfaProvider) GetMFAStatus(ctx context.Context, accessToken string) (*cognito.MFAStatus, error) {
+	return &cognito.MFAStatus{}, nil
+}
+
+func newMFAHandler(t *testing.T) (*AuthHandler, *cognito.AuthResponse) {
+	t.Helper()
+
+	h, p := newLocalHandler(t)
+	tokens := signedIn(t, p, "alice@example.com")
+	h.provider = &mfaProvider{Provider: p}
+	return h, tokens
+}
+
+func serveMFA(t *testing.T, h *AuthHandler, method, path, body, token string) (int, []byte) {
+	t.Helper()
+
+	r := gin.New()
+	r.POST("/mfa/totp", h.StartTOTPEnrollment)
+	r.POST("/mfa/totp/verify", h.VerifyTOTPEnrollment)
+	r.GET("/mfa", h.GetMFAStatus)
+	r.DELETE("/mfa/:method", h.DisableMFAMethod)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, w.Body.Bytes()
+}
+
+func TestMFARequestValidation(t *testing.T) {
+	h, tokens := newMFAHandler(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		token    string
+		wantCode int
+		wantErr  string
+	}{
+		{"enroll with id token", http.MethodPost, "/mfa/totp", `{}`, tokens.IdToken, http.StatusBadRequest, "access_token_required"},
+		{"verify without code", http.MethodPost, "/mfa/totp/verify", `{"deviceName":"phone"}`, tokens.AccessToken, http.StatusBadRequest, "missing_fields"},
+		{"verify malformed body", http.MethodPost, "/mfa/totp/verify", `{`, tokens.AccessToken, http.StatusBadRequest, "invalid_request"},
+		{"verify wrong code", http.MethodPost, "/mfa/totp/verify", `{"code":"000000"}`, tokens.AccessToken, http.StatusBadRequest, "code_mismatch"},
+		{"status with id token", http.MethodGet, "/mfa", "", tokens.IdToken, http.StatusBadRequest, "access_token_required"},
+		{"disable unknown method", http.MethodDelete, "/mfa/email", "", tokens.AccessToken, http.StatusBadRequest, "invalid_mfa_method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveMFA(t, h, tt.method, tt.path, tt.body, tt.token)
+
+			var resp ErrorResponse
+			json.Unmarshal(body, &resp)
+			if code != tt.wantCode || resp.Error != tt.wantErr {
+				t.Errorf("response = %d %q, want %d %q", code, resp.Error, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	h, tokens := newMFAHandler(t)
+	t.Setenv("MFA_TOTP_ISSUER", "")
+
+	code, body := serveMFA(t, h, http.MethodPost, "/mfa/totp", "", tokens.AccessToken)
+	if code != http.StatusOK {
+		t.Fatalf("StartTOTPEnrollment = %d %s", code, body)
+	}
+	var started struct {
+		SecretCode string `json:"secretCode"`
+		OTPAuthURI string `json:"otpauthUri"`
+	}
+	json.Unmarshal(body, &started)
+	// Access tokens carry no email, so the account is labelled by username
+	if want := otpauthURI("ECレコメンド", tokens.User.ID, "SECRET234"); started.SecretCode != "SECRET234" || started.OTPAuthURI != want {
+		t.Errorf("StartTOTPEnrollment = %+v, want the secret and %s", started, want)
+	}
+
+	if code, body := serveMFA(t, h, http.MethodPost, "/mfa/totp/verify", `{"code":"123456"}`, tokens.AccessToken); code != http.StatusOK {
+		t.Fatalf("VerifyTOTPEnrollment = %d %s", code, body)
+	}
+	if !h.provider.(*mfaProvider).verified {
+		t.Error("TOTP was not enabled")
+	}
+
+	if code, body := serveMFA(t, h, http.MethodDelete, "/mfa/totp", "", tokens.AccessToken); code != http.StatusOK {
+		t.Fatalf("DisableMFAMethod = %d %s", code, body)
+	}
+	if h.provider.(*mfaProvider).verified {
+		t.Error("TOTP was not disabled")
+	}
+}
+
+func TestMFANotSupported(t *testing.T) {
+	h, p := newLocalHandler(t)
+	tokens := signedIn(t, p, "alice@example.com")
+
+	code, _ := serveMFA(t, h, http.MethodGet, "/mfa", "", tokens.AccessToken)
+	if code != http.StatusNotImplemented {
+		t.Errorf("GetMFAStatus without MFA support = %d, want 501", code)
+	}
+}
+
+func TestDisableMFARequiresRecentSignIn(t *testing.T) {
+	h, _ := newMFAHandler(t)
+	h.mfaMaxAuthAge = 15 * time.Minute
+	h.provider.(*mfaProvider).verified = true
+
+	signer, err := jwt.NewTestSigner("ap-northeast-1_local", "ap-northeast-1", "local-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := jwt.NewValidator("ap-northeast-1_local", "ap-northeast-1", "local-client", jwt.WithKeySource(signer.JWKS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(v.Close)
+	h.jwtValidator = v
+
+	stale, _ := signer.Mint(jwt.Claims{AuthTime: time.Now().Add(-time.Hour).Unix()})
+	code, body := serveMFA(t, h, http.MethodDelete, "/mfa/totp", "", stale)
+	var resp ErrorResponse
+	json.Unmarshal(body, &resp)
+	if code != http.StatusUnauthorized || resp.Error != "reauthentication_required" {
+		t.Errorf("DisableMFAMethod with a stale sign-in = %d %q, want 401 reauthentication_required", code, resp.Error)
+	}
+	if !h.provider.(*mfaProvider).verified {
+		t.Fatal("TOTP was disabled with a stale sign-in")
+	}
+
+	recent, _ := signer.Mint(jwt.Claims{AuthTime: time.Now().Add(-time.Minute).Unix()})
+	if code, body := serveMFA(t, h, http.MethodDelete, "/mfa/totp", "", recent); code != http.StatusOK {
+		t.Errorf("DisableMFAMethod with a recent sign-in = %d %s", code, body)
+	}
+}
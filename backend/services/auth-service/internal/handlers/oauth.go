@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/oauth"
+	"github.com/ec-recommend/auth-service/internal/proof"
+	"github.com/gin-gonic/gin"
+)
+
+// oauthStateCookie binds a sign-in to the browser that started it, so a
+// callback URL with someone else's code can't sign the victim in. It is
+// a crossSiteCookie because Apple returns with a cross-site form POST.
+const oauthStateCookie = "ec_oauth_state"
+
+// federatedDirectory is implemented by providers that can look users up and
+// create them on behalf of a social login
+type federatedDirectory interface {
+	FindUserByEmail(ctx context.Context, email string) (*cognito.User, error)
+	AdminCreateUser(ctx context.Context, email, name string) (*cognito.User, error)
+}
+
+type OAuthHandler struct {
+	auth   *AuthHandler
+	client *oauth.Client
+	links  oauth.LinkStore
+	local  *oauth.LocalIssuer
+	// stateCookie holds the attributes of oauthStateCookie
+	stateCookie http.Cookie
+}
+
+// NewOAuthHandler configures social login when FEATURE_SOCIAL_LOGIN=true.
+// Each provider is enabled by its OAUTH_{GOOGLE,APPLE,LINE}_CLIENT_ID;
+// OAUTH_LOCAL_ISSUER=true adds the offline "local" provider.
+func NewOAuthHandler(auth *AuthHandler) (*OAuthHandler, error) {
+	h := &OAuthHandler{
+		auth:        auth,
+		stateCookie: auth.crossSiteCookie(oauthStateCookie, "/auth/oauth"),
+	}
+
+	if os.Getenv("FEATURE_SOCIAL_LOGIN") != "true" {
+		h.links = oauth.NewMemoryLinkStore()
+		h.client = oauth.NewClient("", oauth.NewMemoryStateStore())
+		return h, nil
+	}
+
+	// Links are kept in Postgres and pending sign-ins in Redis when
+	// configured, so a callback can land on any instance and returning users
+	// find their account after a restart
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		links, err := oauth.NewPostgresLinkStore(databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		h.links = links
+	} else {
+		log.Println("DATABASE_URL is not set; social login links are kept in memory")
+		h.links = oauth.NewMemoryLinkStore()
+	}
+
+	var states oauth.StateStore
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		store, err := oauth.NewRedisStateStore(redisURL)
+		if err != nil {
+			return nil, err
+		}
+		states = store
+	} else {
+		states = oauth.NewMemoryStateStore()
+	}
+
+	baseURL := os.Getenv("OAUTH_REDIRECT_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	var providers []oauth.Provider
+	for name, preset := range map[string]func(string, string) oauth.Provider{
+		"GOOGLE": oauth.Google,
+		"APPLE":  oauth.Apple,
+		"LINE":   oauth.LINE,
+	} {
+		if clientID := os.Getenv("OAUTH_" + name + "_CLIENT_ID"); clientID != "" {
+			providers = append(providers, preset(clientID, os.Getenv("OAUTH_"+name+"_CLIENT_SECRET")))
+		}
+	}
+
+	if os.Getenv("OAUTH_LOCAL_ISSUER") == "true" {
+		if os.Getenv("APP_ENV") == "production" {
+			return nil, fmt.Errorf("OAUTH_LOCAL_ISSUER cannot be enabled when APP_ENV=production")
+		}
+		local, err := oauth.NewLocalIssuer(strings.TrimSuffix(baseURL, "/")+"/oauth-local", "local-client")
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Social login offers the local OIDC issuer, which signs in any email")
+		h.local = local
+		providers = append(providers, local.Provider())
+	}
+
+	h.client = oauth.NewClient(baseURL, states, providers...)
+	return h, nil
+}
+
+// Start redirects the browser to the provider's sign-in page
+func (h *OAuthHandler) Start(c *gin.Context) {
+	if _, ok := h.auth.provider.(federatedDirectory); !ok {
+		notSupported(c)
+		return
+	}
+
+	authURL, state, err := h.client.AuthorizationURL(c.Request.Context(), c.Param("provider"), c.Query("login_hint"))
+	if errors.Is(err, oauth.ErrUnknownProvider) {
+		unknownOAuthProvider(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "oauth_failed",
+			Message: "Failed to start sign-in",
+		})
+		return
+	}
+
+	cookie := h.stateCookie
+	cookie.Value = state
+	cookie.MaxAge = 600
+	http.SetCookie(c.Writer, &cookie)
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback finishes the sign-in. It accepts GET and, for providers that use
+// response_mode=form_post, POST.
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	directory, ok := h.auth.provider.(federatedDirectory)
+	if !ok {
+		notSupported(c)
+		return
+	}
+	authenticator, ok := h.auth.provider.(customAuthenticator)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	name := c.Param("provider")
+	if _, ok := h.client.Provider(name); !ok {
+		unknownOAuthProvider(c)
+		return
+	}
+
+	if providerErr := c.Request.FormValue("error"); providerErr != "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "oauth_denied",
+			Message: "Sign-in was cancelled or denied by the provider",
+		})
+		return
+	}
+
+	state := c.Request.FormValue("state")
+	code := c.Request.FormValue("code")
+	bound, _ := c.Cookie(oauthStateCookie)
+	if state == "" || code == "" || bound != state {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_state",
+			Message: "Sign-in session is invalid or expired, start again",
+		})
+		return
+	}
+
+	cookie := h.stateCookie
+	cookie.MaxAge = -1
+	http.SetCookie(c.Writer, &cookie)
+
+	ctx := c.Request.Context()
+
+	details := map[string]string{"provider": name}
+
+	identity, err := h.client.Exchange(ctx, name, code, state)
+	if err != nil {
+		log.Printf("OAuth sign-in with %s failed: %v", name, err)
+		reason := "oauth_failed"
+		if errors.Is(err, oauth.ErrInvalidState) {
+			reason = "invalid_state"
+		}
+		h.auth.recordAuth(c, auditOAuthSignIn, "", "", reason, details)
+
+		if errors.Is(err, oauth.ErrInvalidState) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_state",
+				Message: "Sign-in session is invalid or expired, start again",
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "oauth_failed",
+			Message: "Sign-in with the provider failed",
+		})
+		return
+	}
+
+	user, status, resp := h.linkedUser(ctx, directory, identity)
+	if user == nil {
+		h.auth.recordAuth(c, auditOAuthSignIn, "", identity.Email, resp.Error, details)
+		c.JSON(status, resp)
+		return
+	}
+
+	evidence := []byte("oauth:" + identity.Provider + ":" + identity.Subject)
+	authResponse, err := authenticator.SignInWithCustomChallenge(ctx, user.ID, proof.Sign(h.auth.proofSecret, user.ID, evidence))
+	if err != nil {
+		log.Printf("OAuth sign-in for %s failed at the identity provider: %v", user.ID, err)
+		h.auth.recordAuth(c, auditOAuthSignIn, user.ID, identity.Email, "oauth_failed", details)
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "oauth_failed",
+			Message: "Sign-in with the provider failed",
+		})
+		return
+	}
+
+	h.auth.recordAuth(c, auditOAuthSignIn, user.ID, identity.Email, "", details)
+	h.auth.respondWithSession(c, authResponse)
+}
+
+// linkedUser finds the account for a provider identity. Identities are
+// linked to the account with the same verified email, which is created if
+// there is none.
+func (h *OAuthHandler) linkedUser(ctx context.Context, directory federatedDirectory, identity *oauth.Identity) (*cognito.User, int, ErrorResponse) {
+	if username, err := h.links.Find(ctx, identity.Provider, identity.Subject); err == nil {
+		return &cognito.User{ID: username}, 0, ErrorResponse{}
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, http.StatusForbidden, ErrorResponse{
+			Error:   "email_not_verified",
+			Message: "The provider did not share a verified email address",
+		}
+	}
+
+	user, err := directory.FindUserByEmail(ctx, identity.Email)
+	switch {
+	case errors.Is(err, cognito.ErrUserNotFound):
+		user, err = directory.AdminCreateUser(ctx, identity.Email, identity.Name)
+		if err != nil {
+			status, resp := cognitoErrorResponse(err, "oauth_failed", "Failed to create account")
+			return nil, status, resp
+		}
+	case err != nil:
+		status, resp := cognitoErrorResponse(err, "oauth_failed", "Failed to look up account")
+		return nil, status, resp
+	case !user.EmailVerified:
+		// Linking to an unverified account would hand it to whoever
+		// controls the provider account
+		return nil, http.StatusConflict, ErrorResponse{
+			Error:   "account_not_verified",
+			Message: "An account with this email exists but is not verified; sign in with your password first",
+		}
+	}
+
+	if err := h.links.Link(ctx, identity.Provider, identity.Subject, user.ID); err != nil {
+		return nil, http.StatusInternalServerError, ErrorResponse{
+			Error:   "oauth_failed",
+			Message: "Failed to link account",
+		}
+	}
+	log.Printf("Linked %s identity %s to user %s", identity.Provider, identity.Subject, user.ID)
+
+	return user, 0, ErrorResponse{}
+}
+
+func unknownOAuthProvider(c *gin.Context) {
+	c.JSON(http.StatusNotFound, ErrorResponse{
+		Error:   "unknown_provider",
+		Message: "Sign-in with this provider is not available",
+	})
+}
+
+// LocalAuthorize, LocalToken and LocalJWKS serve the offline OIDC issuer
+// when OAUTH_LOCAL_ISSUER=true
+func (h *OAuthHandler) LocalAuthorize(c *gin.Context) {
+	h.serveLocal(c, func(i *oauth.LocalIssuer) http.HandlerFunc { return i.Authorize })
+}
+
+func (h *OAuthHandler) LocalToken(c *gin.Context) {
+	h.serveLocal(c, func(i *oauth.LocalIssuer) http.HandlerFunc { return i.Token })
+}
+
+func (h *OAuthHandler) LocalJWKS(c *gin.Context) {
+	h.serveLocal(c, func(i *oauth.LocalIssuer) http.HandlerFunc { return i.JWKS })
+}
+
+func (h *OAuthHandler) serveLocal(c *gin.Context, handler func(*oauth.LocalIssuer) http.HandlerFunc) {
+	if h.local == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: "The local OIDC issuer is not enabled",
+		})
+		return
+	}
+	handler(h.local)(c.Writer, c.Request)
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/ec-recommend/auth-service/internal/passkey"
+	"github.com/gin-gonic/gin"
+)
+
+type PasskeyHandler struct {
+	auth    *AuthHandler
+	service *passkey.Service
+}
+
+func NewPasskeyHandler(auth *AuthHandler) (*PasskeyHandler, error) {
+	rpID := os.Getenv("PASSKEY_RP_ID")
+	if rpID == "" {
+		rpID = "localhost"
+	}
+
+	rpName := os.Getenv("PASSKEY_RP_NAME")
+	if rpName == "" {
+		rpName = "ECレコメンド"
+	}
+
+	origins := []string{"http://localhost:3000"}
+	if env := os.Getenv("PASSKEY_RP_ORIGINS"); env != "" {
+		origins = strings.Split(env, ",")
+	}
+
+	// Credentials are kept in Postgres and ceremony sessions in Redis when
+	// configured, so passkeys outlive restarts and ceremonies can span
+	// instances
+	var credentials passkey.CredentialStore
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		store, err := passkey.NewPostgresCredentialStore(databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		credentials = store
+	} else {
+		log.Println("DATABASE_URL is not set; passkeys are kept in memory")
+		credentials = passkey.NewMemoryCredentialStore()
+	}
+
+	var sessions passkey.SessionStore
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		store, err := passkey.NewRedisSessionStore(redisURL)
+		if err != nil {
+			return nil, err
+		}
+		sessions = store
+	} else {
+		sessions = passkey.NewMemorySessionStore()
+	}
+
+	service, err := passkey.NewService(passkey.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+		ProofSecret:   auth.proofSecret,
+	}, credentials, sessions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyHandler{
+		auth:    auth,
+		service: service,
+	}, nil
+}
+
+// requireUser checks for an access token naming the user passkeys are
+// registered to. Passkey sign-in later authenticates as that username, which
+// ID tokens don't carry in the username claim.
+func (h *PasskeyHandler) requireUser(c *gin.Context) (*jwt.Claims, bool) {
+	claims, _, ok := h.auth.requireAccessToken(c)
+	if !ok {
+		return nil, false
+	}
+
+	if claims.Username == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "invalid_token",
+			Message: "The token has no username",
+		})
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// RegisterBegin issues a registration challenge for the signed-in user
+func (h *PasskeyHandler) RegisterBegin(c *gin.Context) {
+	claims, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	userName := claims.Email
+	if userName == "" {
+		userName = claims.Username
+	}
+
+	creation, err := h.service.BeginRegistration(c.Request.Context(), claims.Username, userName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "passkey_registration_failed",
+			Message: "Failed to start passkey registration",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge": creation.Response.Challenge.String(),
+		"options":   creation,
+	})
+}
+
+// RegisterComplete verifies the attestation and stores the credential
+func (h *PasskeyHandler) RegisterComplete(c *gin.Context) {
+	claims, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID     string          `json:"userId"`
+		Credential json.RawMessage `json:"credential"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Credential) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.UserID != "" && req.UserID != claims.Username {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "user_mismatch",
+			Message: "Passkeys can only be registered for the signed-in user",
+		})
+		return
+	}
+
+	credential, err := h.service.FinishRegistration(c.Request.Context(), claims.Username, req.Credential)
+	if err != nil {
+		h.auth.recordAuth(c, auditPasskeyRegistered, claims.Subject, "", "passkey_registration_failed", nil)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "passkey_registration_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	h.auth.recordAuth(c, auditPasskeyRegistered, claims.Subject, "", "", map[string]string{"credentialId": credentialID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"credentialId": credentialID,
+	})
+}
+
+// AuthenticateBegin issues a challenge for a discoverable credential login
+func (h *PasskeyHandler) AuthenticateBegin(c *gin.Context) {
+	assertion, err := h.service.BeginLogin(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "passkey_authentication_failed",
+			Message: "Failed to start passkey authentication",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge": assertion.Response.Challenge.String(),
+		"options":   assertion,
+	})
+}
+
+// AuthenticateComplete verifies the assertion and exchanges it for tokens
+// from the identity provider
+func (h *PasskeyHandler) AuthenticateComplete(c *gin.Context) {
+	authenticator, ok := h.auth.provider.(customAuthenticator)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	var req struct {
+		Credential json.RawMessage `json:"credential"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Credential) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	userID, credential, err := h.service.FinishLogin(c.Request.Context(), req.Credential)
+	if err != nil {
+		reason := "passkey_authentication_failed"
+		if errors.Is(err, passkey.ErrCloneDetected) {
+			log.Printf("passkey clone warning for user %s", userID)
+			reason = "passkey_clone_detected"
+		}
+		h.auth.recordAuth(c, auditPasskeySignIn, userID, "", reason, nil)
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "passkey_authentication_failed",
+			Message: "Passkey authentication failed",
+		})
+		return
+	}
+
+	authResponse, err := authenticator.SignInWithCustomChallenge(c.Request.Context(), userID, h.service.Proof(userID, credential.ID))
+	if err != nil {
+		_, reason := cognitoErrorResponse(err, "passkey_authentication_failed", "")
+		h.auth.recordAuth(c, auditPasskeySignIn, userID, "", reason.Error, nil)
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "passkey_authentication_failed",
+			Message: "Passkey authentication failed",
+		})
+		return
+	}
+
+	h.auth.recordAuth(c, auditPasskeySignIn, authResponse.User.ID, "", "", nil)
+	h.auth.respondWithSession(c, authResponse)
+}
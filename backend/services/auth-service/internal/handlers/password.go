@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/gin-gonic/gin"
+)
+
+const minPasswordLength = 8
+
+// ForgotPassword emails a password reset code
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	passwords, ok := h.provider.(passwordManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if !validEmail(req.Email) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_email",
+			Message: "A valid email is required",
+		})
+		return
+	}
+
+	err := passwords.ForgotPassword(c.Request.Context(), req.Email)
+	// Unknown users get the same response so accounts can't be enumerated
+	if err != nil && !errors.Is(err, cognito.ErrUserNotFound) {
+		status, resp := cognitoErrorResponse(err, "password_reset_failed", "Failed to start password reset")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the account exists, a password reset code has been sent.",
+	})
+}
+
+// ResetPassword sets a new password using the emailed reset code
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	passwords, ok := h.provider.(passwordManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	var req struct {
+		Email            string `json:"email"`
+		ConfirmationCode string `json:"confirmationCode"`
+		NewPassword      string `json:"newPassword"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if !validEmail(req.Email) || req.ConfirmationCode == "" || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Email, confirmation code and new password are required",
+		})
+		return
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_password",
+			Message: "Password must be at least 8 characters",
+		})
+		return
+	}
+
+	err := passwords.ConfirmForgotPassword(c.Request.Context(), req.Email, req.ConfirmationCode, req.NewPassword)
+	// An unknown user looks like a wrong code, as in ForgotPassword
+	if errors.Is(err, cognito.ErrUserNotFound) {
+		err = cognito.ErrCodeMismatch
+	}
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "password_reset_failed", "Failed to reset password")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password reset successfully",
+	})
+}
+
+// ChangePassword changes the signed-in user's password
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	passwords, ok := h.provider.(passwordManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	_, accessToken, ok := h.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PreviousPassword string `json:"previousPassword"`
+		NewPassword      string `json:"newPassword"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.PreviousPassword == "" || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Previous and new password are required",
+		})
+		return
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_password",
+			Message: "Password must be at least 8 characters",
+		})
+		return
+	}
+
+	err := passwords.ChangePassword(c.Request.Context(), accessToken, req.PreviousPassword, req.NewPassword)
+	if errors.Is(err, cognito.ErrNotAuthorized) {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "not_authorized",
+			Message: "Incorrect password",
+		})
+		return
+	}
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "password_change_failed", "Failed to change password")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully",
+	})
+}
+
+// ResendConfirmationCode re-sends the sign-up verification code
+func (h *AuthHandler) ResendConfirmationCode(c *gin.Context) {
+	passwords, ok := h.provider.(passwordManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if !validEmail(req.Email) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_email",
+			Message: "A valid email is required",
+		})
+		return
+	}
+
+	err := passwords.ResendConfirmationCode(c.Request.Context(), req.Email)
+	if err != nil && !errors.Is(err, cognito.ErrUserNotFound) {
+		status, resp := cognitoErrorResponse(err, "resend_failed", "Failed to resend confirmation code")
+		c.JSON(status, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the account exists, a new confirmation code has been sent.",
+	})
+}
+
+func validEmail(email string) bool {
+	at := strings.Index(email, "@")
+	return at > 0 && at < len(email)-1
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ec-recommend/auth-service/internal/audit"
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/ec-recommend/auth-service/internal/local"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+const testPassword = "correct-horse"
+
+// newLocalHandler returns a handler backed by the local provider, validating
+// tokens against the provider's own keys
+func newLocalHandler(t *testing.T) (*AuthHandler, *local.Provider) {
+	t.Helper()
+
+	p, err := local.NewProvider(local.Config{UserPoolID: "ap-northeast-1_local", Region: "ap-northeast-1", ClientID: "local-client", AutoConfirm: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := jwt.NewValidator("ap-northeast-1_local", "ap-northeast-1", "local-client", jwt.WithKeySource(p.JWKS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(v.Close)
+
+	return &AuthHandler{provider: p, jwtValidator: v, profiles: newProfileCache(0), audit: audit.NewRecorder(nil)}, p
+}
+
+// signedIn signs a new user up and in
+func signedIn(t *testing.T, p IdentityProvider, email string) *cognito.AuthResponse {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := p.SignUp(ctx, cognito.SignUpRequest{Email: email, Password: testPassword}); err != nil {
+		t.Fatalf("SignUp(%s): %v", email, err)
+	}
+	resp, _, err := p.SignIn(ctx, cognito.SignInRequest{Email: email, Password: testPassword})
+	if err != nil {
+		t.Fatalf("SignIn(%s): %v", email, err)
+	}
+	return resp
+}
+
+func bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+// serve runs handler for a single JSON request and decodes its error response
+func serve(t *testing.T, handler gin.HandlerFunc, body string, headers map[string]string) (int, ErrorResponse) {
+	t.Helper()
+
+	r := gin.New()
+	r.POST("/", handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestPasswordRequestValidation(t *testing.T) {
+	h, p := newLocalHandler(t)
+	tokens := signedIn(t, p, "alice@example.com")
+	access := bearer(tokens.AccessToken)
+
+	tests := []struct {
+		name     string
+		handler  gin.HandlerFunc
+		body     string
+		headers  map[string]string
+		wantCode int
+		wantErr  string
+	}{
+		{"forgot: malformed body", h.ForgotPassword, `{`, nil, http.StatusBadRequest, "invalid_request"},
+		{"forgot: invalid email", h.ForgotPassword, `{"email":"alice"}`, nil, http.StatusBadRequest, "invalid_email"},
+		{"reset: missing code", h.ResetPassword, `{"email":"alice@example.com","newPassword":"password123"}`, nil, http.StatusBadRequest, "missing_fields"},
+		{"reset: short password", h.ResetPassword, `{"email":"alice@example.com","confirmationCode":"123456","newPassword":"short"}`, nil, http.StatusBadRequest, "invalid_password"},
+		{"change: missing token", h.ChangePassword, `{}`, nil, http.StatusUnauthorized, "missing_token"},
+		{"change: invalid token", h.ChangePassword, `{}`, bearer("not-a-jwt"), http.StatusUnauthorized, "invalid_token"},
+		{"change: id token", h.ChangePassword, `{"previousPassword":"password123","newPassword":"password456"}`, bearer(tokens.IdToken), http.StatusBadRequest, "access_token_required"},
+		{"change: missing previous password", h.ChangePassword, `{"newPassword":"password456"}`, access, http.StatusBadRequest, "missing_fields"},
+		{"change: short password", h.ChangePassword, `{"previousPassword":"password123","newPassword":"short"}`, access, http.StatusBadRequest, "invalid_password"},
+		{"resend: invalid email", h.ResendConfirmationCode, `{"email":"@example.com"}`, nil, http.StatusBadRequest, "invalid_email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := serve(t, tt.handler, tt.body, tt.headers)
+			if code != tt.wantCode || resp.Error != tt.wantErr {
+				t.Errorf("response = %d %q, want %d %q", code, resp.Error, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForgotPasswordHidesUnknownUsers(t *testing.T) {
+	h, p := newLocalHandler(t)
+	signedIn(t, p, "alice@example.com")
+
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		if code, resp := serve(t, h.ForgotPassword, `{"email":"`+email+`"}`, nil); code != http.StatusOK {
+			t.Errorf("ForgotPassword(%s) = %d %q, want 200", email, code, resp.Error)
+		}
+	}
+}
+
+func TestResetPasswordHidesUnknownUsers(t *testing.T) {
+	h, p := newLocalHandler(t)
+	signedIn(t, p, "alice@example.com")
+	serve(t, h.ForgotPassword, `{"email":"alice@example.com"}`, nil)
+
+	// A wrong code for a known user and any code for an unknown one answer
+	// the same
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		body := `{"email":"` + email + `","confirmationCode":"000000","newPassword":"new-password"}`
+		if code, resp := serve(t, h.ResetPassword, body, nil); code != http.StatusBadRequest || resp.Error != "code_mismatch" {
+			t.Errorf("ResetPassword(%s) = %d %q, want 400 code_mismatch", email, code, resp.Error)
+		}
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	h, p := newLocalHandler(t)
+	access := bearer(signedIn(t, p, "alice@example.com").AccessToken)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantErr     string
+		wantMessage string
+	}{
+		{"wrong previous password", `{"previousPassword":"wrong-password","newPassword":"new-password"}`, http.StatusUnauthorized, "not_authorized", "Incorrect password"},
+		{"valid", `{"previousPassword":"` + testPassword + `","newPassword":"new-password"}`, http.StatusOK, "", "Password changed successfully"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := serve(t, h.ChangePassword, tt.body, access)
+			if code != tt.wantCode || resp.Error != tt.wantErr || resp.Message != tt.wantMessage {
+				t.Errorf("response = %d %q %q, want %d %q %q", code, resp.Error, resp.Message, tt.wantCode, tt.wantErr, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestValidEmail(t *testing.T) {
+	tests := map[string]bool{
+		"alice@example.com": true,
+		"a@b":               true,
+		"alice":             false,
+		"@example.com":      false,
+		"alice@":            false,
+		"":                  false,
+	}
+
+	for email, want := range tests {
+		if got := validEmail(email); got != want {
+			t.Errorf("validEmail(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
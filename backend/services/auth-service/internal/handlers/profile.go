@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultProfileCacheTTL = 5 * time.Minute
+
+// maxProfileCacheEntries bounds the profiles kept between sweeps
+const maxProfileCacheEntries = 10000
+
+// standardProfileAttributes are the attributes users may change on their own
+// profile. Privileged attributes such as custom:seller_id are admin-only.
+var standardProfileAttributes = []string{
+	"name", "given_name", "family_name", "nickname", "preferred_username",
+	"email", "phone_number", "locale", "zoneinfo", "picture", "birthdate",
+	"gender", "address",
+}
+
+// protectedAttributes can never be self-updated, even if configured
+var protectedAttributes = map[string]bool{
+	"custom:seller_id": true,
+}
+
+// updatableAttributes returns the allow-list: the standard attributes plus
+// custom ones listed in PROFILE_CUSTOM_ATTRIBUTES
+func updatableAttributes() map[string]bool {
+	allowed := make(map[string]bool)
+	for _, name := range standardProfileAttributes {
+		allowed[name] = true
+	}
+	for _, name := range strings.Split(os.Getenv("PROFILE_CUSTOM_ATTRIBUTES"), ",") {
+		name = strings.TrimSpace(name)
+		if strings.HasPrefix(name, "custom:") && !protectedAttributes[name] {
+			allowed[name] = true
+		}
+	}
+	return allowed
+}
+
+// profileCache keeps recently fetched profiles so GET /auth/user doesn't
+// call the identity provider on every request. get drops the expired
+// entries it finds and put sweeps the rest at most once per TTL; a full
+// cache evicts an arbitrary entry.
+type profileCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]profileEntry
+	swept   time.Time
+}
+
+type profileEntry struct {
+	user      *cognito.User
+	expiresAt time.Time
+}
+
+func newProfileCache(ttl time.Duration) *profileCache {
+	if ttl <= 0 {
+		ttl = defaultProfileCacheTTL
+	}
+	return &profileCache{
+		ttl:        ttl,
+		maxEntries: maxProfileCacheEntries,
+		entries:    make(map[string]profileEntry),
+		swept:      time.Now(),
+	}
+}
+
+func (p *profileCache) get(subject string) (*cognito.User, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, found := p.entries[subject]
+	if !found || time.Now().After(entry.expiresAt) {
+		delete(p.entries, subject)
+		return nil, false
+	}
+	return entry.user, true
+}
+
+func (p *profileCache) put(subject string, user *cognito.User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.swept) >= p.ttl {
+		for key, entry := range p.entries {
+			if now.After(entry.expiresAt) {
+				delete(p.entries, key)
+			}
+		}
+		p.swept = now
+	}
+
+	if _, found := p.entries[subject]; !found && len(p.entries) >= p.maxEntries {
+		for key := range p.entries {
+			delete(p.entries, key)
+			break
+		}
+	}
+	p.entries[subject] = profileEntry{user: user, expiresAt: now.Add(p.ttl)}
+}
+
+func (p *profileCache) invalidate(subject string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.entries, subject)
+}
+
+// currentUser returns the access token's user, from the cache when fresh
+func (h *AuthHandler) currentUser(ctx context.Context, claims *jwt.Claims, accessToken string) (*cognito.User, error) {
+	if user, found := h.profiles.get(claims.Subject); found {
+		return user, nil
+	}
+
+	user, err := h.provider.GetUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	h.profiles.put(claims.Subject, user)
+	return user, nil
+}
+
+// profileFromClaims builds a profile from an ID token, which carries the
+// user's attributes itself
+func profileFromClaims(claims *jwt.Claims) *cognito.User {
+	user := &cognito.User{
+		ID:            claims.Username,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Attributes:    make(map[string]string),
+	}
+
+	if username, ok := claims.Claim("cognito:username"); ok {
+		user.ID, _ = username.(string)
+	}
+	if name, ok := claims.Claim("name"); ok {
+		user.Name, _ = name.(string)
+	}
+
+	// Report what could be read through GetUser: the profile attributes and
+	// the seller ID, which is readable but not writable
+	readable := updatableAttributes()
+	readable["custom:seller_id"] = true
+	delete(readable, "name")
+	delete(readable, "email")
+
+	for attribute := range readable {
+		if value, ok := claims.Claim(attribute); ok {
+			if s, ok := value.(string); ok {
+				user.Attributes[attribute] = s
+			}
+		}
+	}
+
+	return user
+}
+
+// UpdateCurrentUser changes the signed-in user's allow-listed attributes.
+// Changing email or phone_number sends a code to verify the new value.
+func (h *AuthHandler) UpdateCurrentUser(c *gin.Context) {
+	attributes, ok := h.provider.(attributeManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	claims, accessToken, ok := h.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Attributes map[string]string `json:"attributes"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if len(req.Attributes) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "At least one attribute is required",
+		})
+		return
+	}
+
+	allowed := updatableAttributes()
+	var rejected []string
+	for name := range req.Attributes {
+		if !allowed[name] {
+			rejected = append(rejected, name)
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "attribute_not_allowed",
+			Message: "These attributes cannot be changed: " + strings.Join(rejected, ", "),
+		})
+		return
+	}
+
+	if email, changed := req.Attributes["email"]; changed && !validEmail(email) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_email",
+			Message: "A valid email is required",
+		})
+		return
+	}
+
+	pending, err := attributes.UpdateUserAttributes(c.Request.Context(), accessToken, req.Attributes)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "update_failed", "Failed to update profile")
+		c.JSON(status, resp)
+		return
+	}
+
+	h.profiles.invalidate(claims.Subject)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Profile updated",
+		"pendingVerification": pending,
+	})
+}
+
+// VerifyUserAttribute confirms a changed email or phone number with the code
+// that was sent to it
+func (h *AuthHandler) VerifyUserAttribute(c *gin.Context) {
+	attributes, ok := h.provider.(attributeManager)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	claims, accessToken, ok := h.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Attribute string `json:"attribute"`
+		Code      string `json:"code"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.Attribute != "email" && req.Attribute != "phone_number" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_attribute",
+			Message: "Attribute must be email or phone_number",
+		})
+		return
+	}
+
+	if req.Code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Code is required",
+		})
+		return
+	}
+
+	err := attributes.VerifyUserAttribute(c.Request.Context(), accessToken, req.Attribute, req.Code)
+	if err != nil {
+		status, resp := cognitoErrorResponse(err, "verify_failed", "Failed to verify attribute")
+		c.JSON(status, resp)
+		return
+	}
+
+	h.profiles.invalidate(claims.Subject)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Attribute verified",
+	})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdatableAttributes(t *testing.T) {
+	t.Setenv("PROFILE_CUSTOM_ATTRIBUTES", "custom:shoe_size, custom:seller_id,role,,custom:newsletter")
+	allowed := updatableAttributes()
+
+	tests := map[string]bool{
+		"name":              true,
+		"email":             true,
+		"custom:shoe_size":  true,
+		"custom:newsletter": true,
+		// Protected even when configured
+		"custom:seller_id": false,
+		// Only custom attributes can be added
+		"role":            false,
+		"custom:unlisted": false,
+		"cognito:groups":  false,
+		"email_verified":  false,
+	}
+
+	for name, want := range tests {
+		if allowed[name] != want {
+			t.Errorf("updatableAttributes()[%q] = %v, want %v", name, allowed[name], want)
+		}
+	}
+}
+
+func TestProfileCache(t *testing.T) {
+	cache := newProfileCache(time.Hour)
+	alice := &cognito.User{ID: "alice"}
+
+	if _, found := cache.get("alice"); found {
+		t.Fatal("get() found a profile in an empty cache")
+	}
+
+	cache.put("alice", alice)
+	if got, found := cache.get("alice"); !found || got != alice {
+		t.Fatalf("get() = %v, %v, want the cached profile", got, found)
+	}
+
+	cache.invalidate("alice")
+	if _, found := cache.get("alice"); found {
+		t.Error("get() found an invalidated profile")
+	}
+
+	cache.put("bob", &cognito.User{ID: "bob"})
+	cache.entries["bob"] = profileEntry{user: cache.entries["bob"].user, expiresAt: time.Now().Add(-time.Second)}
+	if _, found := cache.get("bob"); found {
+		t.Error("get() returned an expired profile")
+	}
+}
+
+func TestProfileCacheEviction(t *testing.T) {
+	cache := newProfileCache(time.Hour)
+	cache.maxEntries = 2
+
+	for _, subject := range []string{"alice", "bob", "carol"} {
+		cache.put(subject, &cognito.User{ID: subject})
+	}
+	if len(cache.entries) != 2 {
+		t.Errorf("%d cached profiles, want at most 2", len(cache.entries))
+	}
+	if _, found := cache.get("carol"); !found {
+		t.Error("get() lost the profile just put")
+	}
+
+	// Replacing a cached profile evicts nothing
+	cache.put("carol", &cognito.User{ID: "carol"})
+	if len(cache.entries) != 2 {
+		t.Errorf("%d cached profiles after a replacement, want 2", len(cache.entries))
+	}
+
+	// Expired profiles are swept once a TTL has passed since the last sweep
+	for subject, entry := range cache.entries {
+		entry.expiresAt = time.Now().Add(-time.Second)
+		cache.entries[subject] = entry
+	}
+	cache.put("dave", &cognito.User{ID: "dave"})
+	if len(cache.entries) != 2 {
+		t.Errorf("%d cached profiles before the sweep is due, want 2", len(cache.entries))
+	}
+	cache.swept = time.Now().Add(-time.Hour)
+	cache.put("erin", &cognito.User{ID: "erin"})
+	if len(cache.entries) != 2 {
+		t.Errorf("%d cached profiles after the sweep, want dave and erin", len(cache.entries))
+	}
+	if _, found := cache.get("dave"); !found {
+		t.Error("sweep dropped an unexpired profile")
+	}
+}
+
+// serveProfile sends an authenticated request through the profile routes
+func serveProfile(t *testing.T, h *AuthHandler, method, path, body, token string) (int, []byte) {
+	t.Helper()
+
+	r := gin.New()
+	r.GET("/auth/user", h.GetCurrentUser)
+	r.PUT("/auth/user", h.UpdateCurrentUser)
+	r.POST("/auth/user/verify", h.VerifyUserAttribute)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, w.Body.Bytes()
+}
+
+func TestUpdateCurrentUserValidation(t *testing.T) {
+	t.Setenv("PROFILE_CUSTOM_ATTRIBUTES", "custom:shoe_size,custom:seller_id")
+	h, p := newLocalHandler(t)
+	tokens := signedIn(t, p, "alice@example.com")
+
+	tests := []struct {
+		name     string
+		body     string
+		token    string
+		wantCode int
+		wantErr  string
+	}{
+		{"malformed body", `{`, tokens.AccessToken, http.StatusBadRequest, "invalid_request"},
+		{"no attributes", `{"attributes":{}}`, tokens.AccessToken, http.StatusBadRequest, "missing_fields"},
+		{"protected attribute", `{"attributes":{"custom:seller_id":"s-1"}}`, tokens.AccessToken, http.StatusBadRequest, "attribute_not_allowed"},
+		{"unlisted attribute", `{"attributes":{"name":"Alice","custom:role":"admin"}}`, tokens.AccessToken, http.StatusBadRequest, "attribute_not_allowed"},
+		{"invalid email", `{"attributes":{"email":"alice"}}`, tokens.AccessToken, http.StatusBadRequest, "invalid_email"},
+		{"id token", `{"attributes":{"name":"Alice"}}`, tokens.IdToken, http.StatusBadRequest, "access_token_required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveProfile(t, h, http.MethodPut, "/auth/user", tt.body, tt.token)
+
+			var resp ErrorResponse
+			json.Unmarshal(body, &resp)
+			if code != tt.wantCode || resp.Error != tt.wantErr {
+				t.Errorf("response = %d %q, want %d %q", code, resp.Error, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateCurrentUserInvalidatesProfile(t *testing.T) {
+	t.Setenv("PROFILE_CUSTOM_ATTRIBUTES", "custom:shoe_size")
+	h, p := newLocalHandler(t)
+	token := signedIn(t, p, "alice@example.com").AccessToken
+
+	type profile struct {
+		Name       string            `json:"name"`
+		Attributes map[string]string `json:"attributes"`
+	}
+	get := func() profile {
+		t.Helper()
+		code, body := serveProfile(t, h, http.MethodGet, "/auth/user", "", token)
+		if code != http.StatusOK {
+			t.Fatalf("GetCurrentUser = %d %s", code, body)
+		}
+		var got profile
+		json.Unmarshal(body, &got)
+		return got
+	}
+
+	// Cache the profile before changing it
+	get()
+
+	code, body := serveProfile(t, h, http.MethodPut, "/auth/user", `{"attributes":{"name":"Alice","custom:shoe_size":"24"}}`, token)
+	if code != http.StatusOK {
+		t.Fatalf("UpdateCurrentUser = %d %s", code, body)
+	}
+
+	if got := get(); got.Name != "Alice" || got.Attributes["custom:shoe_size"] != "24" {
+		t.Errorf("profile after update = %+v, want the new name and shoe size", got)
+	}
+}
+
+func TestVerifyUserAttributeValidation(t *testing.T) {
+	h, p := newLocalHandler(t)
+	token := signedIn(t, p, "alice@example.com").AccessToken
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{"unverifiable attribute", `{"attribute":"name","code":"123456"}`, http.StatusBadRequest, "invalid_attribute"},
+		{"missing code", `{"attribute":"email"}`, http.StatusBadRequest, "missing_fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveProfile(t, h, http.MethodPost, "/auth/user/verify", tt.body, token)
+
+			var resp ErrorResponse
+			json.Unmarshal(body, &resp)
+			if code != tt.wantCode || resp.Error != tt.wantErr {
+				t.Errorf("response = %d %q, want %d %q", code, resp.Error, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+}
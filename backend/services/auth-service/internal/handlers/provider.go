@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// IdentityProvider is the user directory behind the auth routes. Both
+// *cognito.Client and *local.Provider implement it; features beyond the core
+// flows are detected through the optional interfaces below.
+type IdentityProvider interface {
+	SignUp(ctx context.Context, req cognito.SignUpRequest) error
+	SignIn(ctx context.Context, req cognito.SignInRequest) (*cognito.AuthResponse, *cognito.Challenge, error)
+	ConfirmSignUp(ctx context.Context, email, confirmationCode string) error
+	RefreshToken(ctx context.Context, refreshToken string) (*cognito.AuthResponse, error)
+	GetUser(ctx context.Context, accessToken string) (*cognito.User, error)
+}
+
+type challengeResponder interface {
+	RespondToChallenge(ctx context.Context, answer cognito.ChallengeAnswer) (*cognito.AuthResponse, *cognito.Challenge, error)
+}
+
+type sessionRevoker interface {
+	RevokeToken(ctx context.Context, refreshToken string) error
+	GlobalSignOut(ctx context.Context, accessToken string) error
+}
+
+type passwordManager interface {
+	ForgotPassword(ctx context.Context, email string) error
+	ConfirmForgotPassword(ctx context.Context, email, confirmationCode, newPassword string) error
+	ChangePassword(ctx context.Context, accessToken, previousPassword, proposedPassword string) error
+	ResendConfirmationCode(ctx context.Context, email string) error
+}
+
+type mfaManager interface {
+	AssociateSoftwareToken(ctx context.Context, accessToken string) (string, error)
+	VerifySoftwareToken(ctx context.Context, accessToken, code, deviceName string) error
+	SetMFAPreference(ctx context.Context, accessToken, method string, enabled bool) error
+	GetMFAStatus(ctx context.Context, accessToken string) (*cognito.MFAStatus, error)
+}
+
+type attributeManager interface {
+	UpdateUserAttributes(ctx context.Context, accessToken string, attributes map[string]string) ([]string, error)
+	VerifyUserAttribute(ctx context.Context, accessToken, attribute, code string) error
+}
+
+type customAuthenticator interface {
+	SignInWithCustomChallenge(ctx context.Context, username, answer string) (*cognito.AuthResponse, error)
+}
+
+// userDeleter removes users without their access token, for deletions
+// carried out after a grace period
+type userDeleter interface {
+	AdminDeleteUser(ctx context.Context, username string) error
+}
+
+// userAdministrator manages other users on an administrator's behalf
+type userAdministrator interface {
+	ListUsers(ctx context.Context, filter cognito.UserFilter) (*cognito.UserPage, error)
+	AdminGetUser(ctx context.Context, username string) (*cognito.UserRecord, error)
+	AdminSetUserEnabled(ctx context.Context, username string, enabled bool) error
+	AdminAddUserToGroup(ctx context.Context, username, group string) error
+	AdminRemoveUserFromGroup(ctx context.Context, username, group string) error
+	AdminResetUserPassword(ctx context.Context, username string) error
+	AdminUserGlobalSignOut(ctx context.Context, username string) error
+}
+
+// attributeAdministrator sets attributes users can't set themselves
+type attributeAdministrator interface {
+	AdminUpdateUserAttributes(ctx context.Context, username string, attributes map[string]string) error
+	AdminDeleteUserAttributes(ctx context.Context, username string, names []string) error
+}
+
+// keySetProvider is implemented by providers that sign their own tokens
+type keySetProvider interface {
+	JWKS(ctx context.Context) (jwk.Set, error)
+}
+
+// JWKS serves the provider's public signing keys when it issues its own
+// tokens, so other services can verify them
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	p, ok := h.provider.(keySetProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: "Signing keys are published by the identity provider",
+		})
+		return
+	}
+
+	set, err := p.JWKS(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "jwks_failed",
+			Message: "Failed to load signing keys",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, set)
+}
+
+// notSupported responds for features the configured provider lacks
+func notSupported(c *gin.Context) {
+	c.JSON(http.StatusNotImplemented, ErrorResponse{
+		Error:   "not_supported",
+		Message: "This operation is not supported by the identity provider",
+	})
+}
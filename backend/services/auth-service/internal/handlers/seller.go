@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ec-recommend/auth-service/internal/audit"
+	"github.com/ec-recommend/auth-service/internal/seller"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// sellerGroup is the Cognito group of approved sellers
+	sellerGroup = "seller"
+	// sellerIDAttribute links a user to their sellers row; the shared auth
+	// middleware reports it as AuthInfo.SellerID
+	sellerIDAttribute = "custom:seller_id"
+)
+
+// Seller audit event types
+const (
+	auditSellerApplied   = "seller.applied"
+	auditSellerApproved  = "admin.seller_approved"
+	auditSellerRejected  = "admin.seller_rejected"
+	auditSellerSuspended = "admin.seller_suspended"
+)
+
+type SellerHandler struct {
+	auth  *AuthHandler
+	admin *AdminHandler
+	store seller.Store
+}
+
+// NewSellerHandler serves seller applications and their review. Sellers are
+// kept in the sellers table at DATABASE_URL, or in memory without it.
+func NewSellerHandler(auth *AuthHandler, admin *AdminHandler) (*SellerHandler, error) {
+	h := &SellerHandler{
+		auth:  auth,
+		admin: admin,
+	}
+
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		store, err := seller.NewPostgresStore(databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		h.store = store
+	} else {
+		log.Println("DATABASE_URL is not set; seller applications are kept in memory")
+		h.store = seller.NewMemoryStore()
+	}
+
+	return h, nil
+}
+
+// sellerApplication is the body of Apply. Email defaults to the account's.
+type sellerApplication struct {
+	CompanyName           string `json:"companyName"`
+	CompanyNameKana       string `json:"companyNameKana"`
+	Email                 string `json:"email"`
+	PhoneNumber           string `json:"phoneNumber"`
+	PostalCode            string `json:"postalCode"`
+	Address               string `json:"address"`
+	BusinessLicenseNumber string `json:"businessLicenseNumber"`
+}
+
+// Apply creates a pending seller for the signed-in user. An admin approves
+// it before the user gets seller access.
+func (h *SellerHandler) Apply(c *gin.Context) {
+	claims, accessToken, ok := h.auth.requireAccessToken(c)
+	if !ok {
+		return
+	}
+
+	if claims.Pool != "" && claims.Pool != buyerPool {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "application_not_allowed",
+			Message: "Apply from a buyer account",
+		})
+		return
+	}
+
+	var req sellerApplication
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.Email == "" {
+		profile, err := h.auth.currentUser(c.Request.Context(), claims, accessToken)
+		if err != nil {
+			status, resp := cognitoErrorResponse(err, "application_failed", "Failed to submit application")
+			c.JSON(status, resp)
+			return
+		}
+		req.Email = profile.Email
+	}
+
+	application := seller.Seller{
+		UserID:                claims.Subject,
+		CompanyName:           strings.TrimSpace(req.CompanyName),
+		CompanyNameKana:       strings.TrimSpace(req.CompanyNameKana),
+		Email:                 strings.TrimSpace(req.Email),
+		PhoneNumber:           strings.TrimSpace(req.PhoneNumber),
+		PostalCode:            strings.TrimSpace(req.PostalCode),
+		Address:               strings.TrimSpace(req.Address),
+		BusinessLicenseNumber: strings.TrimSpace(req.BusinessLicenseNumber),
+	}
+	if application.CompanyName == "" || application.Email == "" || application.PhoneNumber == "" ||
+		application.PostalCode == "" || application.Address == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Company name, email, phone number, postal code and address are required",
+		})
+		return
+	}
+
+	created, err := h.store.Apply(c.Request.Context(), application)
+	event := audit.Event{
+		Type:    auditSellerApplied,
+		UserID:  claims.Subject,
+		Outcome: audit.OutcomeSuccess,
+	}
+	if err != nil {
+		event.Outcome, event.Reason = audit.OutcomeFailure, err.Error()
+	} else {
+		event.Details = map[string]string{"sellerId": created.ID}
+	}
+	h.auth.audit.Record(c.Request.Context(), event)
+
+	switch {
+	case errors.Is(err, seller.ErrApplicationExists):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "application_exists",
+			Message: "You have already applied to become a seller",
+		})
+		return
+	case errors.Is(err, seller.ErrDuplicateSeller):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "seller_exists",
+			Message: "A seller with this email or license number already exists",
+		})
+		return
+	case err != nil:
+		log.Printf("Seller application for %s failed: %v", claims.Subject, err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "application_failed",
+			Message: "Failed to submit application",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Application submitted for review",
+		"seller":  created,
+	})
+}
+
+// GetApplication returns the signed-in user's seller application
+func (h *SellerHandler) GetApplication(c *gin.Context) {
+	claims, ok := h.auth.requireToken(c)
+	if !ok {
+		return
+	}
+
+	s, err := h.store.FindByUser(c.Request.Context(), claims.Subject)
+	if err != nil {
+		sellerErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"seller": s,
+	})
+}
+
+// ListSellers lists sellers a page at a time, optionally by status
+func (h *SellerHandler) ListSellers(c *gin.Context) {
+	if _, ok := h.admin.caller(c); !ok {
+		return
+	}
+
+	page, ok := parsePageRequest(c, "createdAt")
+	switch page.SortBy {
+	case "createdAt", "updatedAt", "companyName":
+	default:
+		ok = false
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_parameter",
+			Message: "page, page_size (1-100), sort_by (createdAt, updatedAt, companyName) or descending is invalid",
+		})
+		return
+	}
+
+	sellers, total, err := h.store.List(c.Request.Context(), seller.ListOptions{
+		Status:     seller.Status(c.Query("status")),
+		SortBy:     page.SortBy,
+		Descending: page.Descending,
+		Offset:     page.offset(),
+		Limit:      page.PageSize,
+	})
+	if err != nil {
+		log.Printf("Listing sellers failed: %v", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "list_sellers_failed",
+			Message: "Failed to list sellers",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sellers":    sellers,
+		"pagination": page.response(total),
+	})
+}
+
+// GetSeller returns one seller
+func (h *SellerHandler) GetSeller(c *gin.Context) {
+	if _, ok := h.admin.caller(c); !ok {
+		return
+	}
+
+	sellerID := c.Param("seller_id")
+	if _, err := uuid.Parse(sellerID); err != nil {
+		sellerErrorResponse(c, seller.ErrSellerNotFound)
+		return
+	}
+
+	s, err := h.store.Get(c.Request.Context(), sellerID)
+	if err != nil {
+		sellerErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"seller": s,
+	})
+}
+
+// statusRequest is the optional body of the status changes
+type statusRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Approve activates a seller: the user gets custom:seller_id and the seller
+// group, and their tokens are revoked so the next refresh picks both up.
+func (h *SellerHandler) Approve(c *gin.Context) {
+	h.setStatus(c, seller.StatusActive, auditSellerApproved, "Seller approved")
+}
+
+// Reject turns down a pending application
+func (h *SellerHandler) Reject(c *gin.Context) {
+	h.setStatus(c, seller.StatusRejected, auditSellerRejected, "Seller application rejected")
+}
+
+// Suspend takes an active seller's access away until they are approved again
+func (h *SellerHandler) Suspend(c *gin.Context) {
+	h.setStatus(c, seller.StatusSuspended, auditSellerSuspended, "Seller suspended")
+}
+
+// setStatus records the new status, then brings the user's identity in line
+// with it. If the identity update fails the status stays changed, and
+// repeating the request retries the update.
+func (h *SellerHandler) setStatus(c *gin.Context, status seller.Status, eventType, message string) {
+	caller, ok := h.admin.caller(c)
+	if !ok {
+		return
+	}
+
+	// The status is only changed when the user's identity can follow it
+	admin, ok := h.auth.provider.(userAdministrator)
+	if !ok {
+		notSupported(c)
+		return
+	}
+	attributes, ok := h.auth.provider.(attributeAdministrator)
+	if !ok {
+		notSupported(c)
+		return
+	}
+
+	var req statusRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Invalid request body",
+			})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	sellerID := c.Param("seller_id")
+	if _, err := uuid.Parse(sellerID); err != nil {
+		sellerErrorResponse(c, seller.ErrSellerNotFound)
+		return
+	}
+
+	s, err := h.store.SetStatus(ctx, sellerID, status, strings.TrimSpace(req.Reason), caller.UserID)
+	if err == nil {
+		if s.HasAccess() {
+			err = h.grantAccess(ctx, admin, attributes, s)
+		} else {
+			err = h.revokeAccess(ctx, admin, attributes, s)
+		}
+	}
+
+	userID := ""
+	if s != nil {
+		userID = s.UserID
+	}
+	h.admin.record(ctx, eventType, caller, userID, err, map[string]string{
+		"sellerId": sellerID,
+		"reason":   req.Reason,
+	})
+
+	if err != nil {
+		sellerErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"seller":  s,
+	})
+}
+
+// grantAccess links the user to their seller and adds them to the seller
+// group. Tokens only change on refresh, so current ones are revoked.
+func (h *SellerHandler) grantAccess(ctx context.Context, admin userAdministrator, attributes attributeAdministrator, s *seller.Seller) error {
+	if err := attributes.AdminUpdateUserAttributes(ctx, s.UserID, map[string]string{sellerIDAttribute: s.ID}); err != nil {
+		return err
+	}
+	if err := admin.AdminAddUserToGroup(ctx, s.UserID, sellerGroup); err != nil {
+		return err
+	}
+
+	h.auth.revokeTokens(s.UserID)
+	return nil
+}
+
+// revokeAccess removes the seller group and custom:seller_id, and revokes
+// the user's tokens. With a shared revocation list (REDIS_URL) every
+// service using the shared auth middleware rejects them.
+func (h *SellerHandler) revokeAccess(ctx context.Context, admin userAdministrator, attributes attributeAdministrator, s *seller.Seller) error {
+	if err := admin.AdminRemoveUserFromGroup(ctx, s.UserID, sellerGroup); err != nil {
+		return err
+	}
+	if err := attributes.AdminDeleteUserAttributes(ctx, s.UserID, []string{sellerIDAttribute}); err != nil {
+		return err
+	}
+
+	h.auth.revokeTokens(s.UserID)
+	return nil
+}
+
+func sellerErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, seller.ErrSellerNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "seller_not_found",
+			Message: "Seller not found",
+		})
+	case errors.Is(err, seller.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "invalid_transition",
+			Message: "The seller's status does not allow this change",
+		})
+	default:
+		log.Printf("Seller update failed: %v", err)
+		status, resp := cognitoErrorResponse(err, "seller_update_failed", "Failed to update seller")
+		c.JSON(status, resp)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ec-recommend/auth-service/internal/seller"
+	"github.com/gin-gonic/gin"
+)
+
+// newSellerHandler returns a seller handler over newAdminHandler's provider
+// with sellers kept in memory
+func newSellerHandler(t *testing.T) (*SellerHandler, *AdminHandler) {
+	t.Helper()
+
+	admin, _, _ := newAdminHandler(t)
+	return &SellerHandler{auth: admin.auth, admin: admin, store: seller.NewMemoryStore()}, admin
+}
+
+// serveSellerStatus posts a status change for sellerID and returns the
+// status code and the seller's status, or the error code
+func serveSellerStatus(t *testing.T, h *SellerHandler, action, sellerID, token string) (int, string) {
+	t.Helper()
+
+	r := gin.New()
+	sellers := r.Group("/admin/sellers", h.admin.Middleware()...)
+	sellers.POST("/:seller_id/approve", h.Approve)
+	sellers.POST("/:seller_id/reject", h.Reject)
+	sellers.POST("/:seller_id/suspend", h.Suspend)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/sellers/"+sellerID+"/"+action, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		return w.Code, errorCode(w.Body.Bytes())
+	}
+	var resp struct {
+		Seller seller.Seller `json:"seller"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, string(resp.Seller.Status)
+}
+
+// apply files a pending application for the user
+func apply(t *testing.T, h *SellerHandler, userID, email string) *seller.Seller {
+	t.Helper()
+
+	s, err := h.store.Apply(context.Background(), seller.Seller{UserID: userID, CompanyName: "Example", Email: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSellerStatusTransitions(t *testing.T) {
+	h, admin := newSellerHandler(t)
+	p := admin.auth.provider
+	root := signedIn(t, p, "root@example.com")
+	alice := signedIn(t, p, "alice@example.com")
+	bob := signedIn(t, p, "bob@example.com")
+
+	aliceSeller := apply(t, h, alice.User.ID, "alice@example.com")
+	bobSeller := apply(t, h, bob.User.ID, "bob@example.com")
+
+	// Steps run in order, each from the state the previous left
+	tests := []struct {
+		name       string
+		action     string
+		sellerID   string
+		token      string
+		wantCode   int
+		wantResult string
+		wantAccess bool
+	}{
+		{"non-admin", "approve", aliceSeller.ID, alice.AccessToken, http.StatusForbidden, "insufficient_permissions", false},
+		{"suspend pending", "suspend", aliceSeller.ID, root.AccessToken, http.StatusConflict, "invalid_transition", false},
+		{"approve pending", "approve", aliceSeller.ID, root.AccessToken, http.StatusOK, "active", true},
+		{"approve again", "approve", aliceSeller.ID, root.AccessToken, http.StatusOK, "active", true},
+		{"reject active", "reject", aliceSeller.ID, root.AccessToken, http.StatusConflict, "invalid_transition", true},
+		{"suspend active", "suspend", aliceSeller.ID, root.AccessToken, http.StatusOK, "suspended", false},
+		{"reject suspended", "reject", aliceSeller.ID, root.AccessToken, http.StatusConflict, "invalid_transition", false},
+		{"approve suspended", "approve", aliceSeller.ID, root.AccessToken, http.StatusOK, "active", true},
+		{"reject pending", "reject", bobSeller.ID, root.AccessToken, http.StatusOK, "rejected", false},
+		{"approve rejected", "approve", bobSeller.ID, root.AccessToken, http.StatusConflict, "invalid_transition", false},
+		{"unknown seller", "approve", "00000000-0000-0000-0000-000000000000", root.AccessToken, http.StatusNotFound, "seller_not_found", false},
+		{"invalid seller ID", "approve", "not-a-uuid", root.AccessToken, http.StatusNotFound, "seller_not_found", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, result := serveSellerStatus(t, h, tt.action, tt.sellerID, tt.token)
+			if code != tt.wantCode || result != tt.wantResult {
+				t.Fatalf("%s = %d %q, want %d %q", tt.action, code, result, tt.wantCode, tt.wantResult)
+			}
+
+			s, err := h.store.Get(context.Background(), tt.sellerID)
+			if err != nil {
+				return
+			}
+			user, err := admin.auth.provider.(userAdministrator).AdminGetUser(context.Background(), s.UserID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inGroup := equalStrings(user.Groups, []string{sellerGroup})
+			linked := user.Attributes[sellerIDAttribute] == s.ID
+			if inGroup != tt.wantAccess || linked != tt.wantAccess {
+				t.Errorf("user groups %v, %s %q; want seller access %v", user.Groups, sellerIDAttribute, user.Attributes[sellerIDAttribute], tt.wantAccess)
+			}
+		})
+	}
+}
+
+func TestSellerStatusNotSupported(t *testing.T) {
+	h, admin := newSellerHandler(t)
+	root := signedIn(t, admin.auth.provider, "root@example.com")
+	alice := signedIn(t, admin.auth.provider, "alice@example.com")
+	s := apply(t, h, alice.User.ID, "alice@example.com")
+
+	// A provider without user administration can't follow the status, so
+	// the status is left alone
+	admin.auth.provider = struct{ IdentityProvider }{admin.auth.provider}
+
+	if code, result := serveSellerStatus(t, h, "approve", s.ID, root.AccessToken); code != http.StatusNotImplemented || result != "not_supported" {
+		t.Errorf("approve = %d %q, want %d not_supported", code, result, http.StatusNotImplemented)
+	}
+	if got, _ := h.store.Get(context.Background(), s.ID); got.Status != seller.StatusPending {
+		t.Errorf("status = %s, want %s", got.Status, seller.StatusPending)
+	}
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/gin-gonic/gin"
+)
+
+// Cookies set in cookie session mode. The CSRF cookie is readable by
+// JavaScript so the app can echo it in the X-CSRF-Token header.
+const (
+	sessionCookie = "ec_session"
+	refreshCookie = "ec_refresh"
+	csrfCookie    = "ec_csrf"
+	csrfHeader    = "X-CSRF-Token"
+
+	defaultSessionTTL = time.Hour
+	defaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// sessionCookies configures cookie session mode (SESSION_MODE=cookie). In
+// that mode tokens never reach JavaScript: the access token travels in an
+// HttpOnly session cookie and the refresh token in one scoped to /auth.
+type sessionCookies struct {
+	domain     string
+	secure     bool
+	sameSite   http.SameSite
+	sessionTTL time.Duration
+	refreshTTL time.Duration
+}
+
+// newSessionCookies reads the SESSION_* settings, returning nil in the
+// default token mode
+func newSessionCookies() (*sessionCookies, error) {
+	switch mode := os.Getenv("SESSION_MODE"); mode {
+	case "", "token":
+		return nil, nil
+	case "cookie":
+	default:
+		return nil, fmt.Errorf("unknown SESSION_MODE %q", mode)
+	}
+
+	s := &sessionCookies{
+		domain: os.Getenv("SESSION_COOKIE_DOMAIN"),
+		secure: secureCookies(),
+	}
+
+	switch sameSite := strings.ToLower(os.Getenv("SESSION_COOKIE_SAMESITE")); sameSite {
+	case "", "lax":
+		s.sameSite = http.SameSiteLaxMode
+	case "strict":
+		s.sameSite = http.SameSiteStrictMode
+	case "none":
+		if !s.secure {
+			return nil, fmt.Errorf("SESSION_COOKIE_SAMESITE=none requires secure cookies")
+		}
+		s.sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unknown SESSION_COOKIE_SAMESITE %q", sameSite)
+	}
+
+	var err error
+	if s.sessionTTL, err = durationEnv("SESSION_COOKIE_TTL", defaultSessionTTL); err != nil {
+		return nil, err
+	}
+	if s.refreshTTL, err = durationEnv("SESSION_REFRESH_TTL", defaultRefreshTTL); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// secureCookies reads SESSION_COOKIE_SECURE. Browsers drop Secure cookies
+// on plain http, so local development over http://localhost needs it false.
+func secureCookies() bool {
+	return os.Getenv("SESSION_COOKIE_SECURE") != "false"
+}
+
+// crossSiteCookie returns the attributes of a cookie that must come back on
+// a cross-site request, like the OAuth state on Apple's form POST. It
+// follows the session cookies' domain and Secure setting; SameSite=None
+// requires Secure, so an insecure one is Lax.
+func (h *AuthHandler) crossSiteCookie(name, path string) http.Cookie {
+	cookie := http.Cookie{
+		Name:     name,
+		Path:     path,
+		Secure:   secureCookies(),
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	}
+	if h.sessions != nil {
+		cookie.Domain, cookie.Secure = h.sessions.domain, h.sessions.secure
+	}
+	if !cookie.Secure {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	return cookie
+}
+
+func (s *sessionCookies) set(c *gin.Context, name, value, path string, ttl time.Duration, httpOnly bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.domain,
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   s.secure,
+		HttpOnly: httpOnly,
+		SameSite: s.sameSite,
+	})
+}
+
+func (s *sessionCookies) clear(c *gin.Context) {
+	s.set(c, sessionCookie, "", "/", -1, true)
+	s.set(c, refreshCookie, "", "/auth", -1, true)
+	s.set(c, csrfCookie, "", "/", -1, false)
+}
+
+// sessionResponse is the sign-in response in cookie mode. The ID token is
+// kept so the app can read profile claims; it grants no access by itself.
+type sessionResponse struct {
+	IdToken   string       `json:"idToken"`
+	User      cognito.User `json:"user"`
+	CSRFToken string       `json:"csrfToken"`
+}
+
+// respondWithSession finishes a sign-in or refresh. In token mode the tokens
+// are returned as JSON; in cookie mode they are set as cookies instead.
+func (h *AuthHandler) respondWithSession(c *gin.Context, authResponse *cognito.AuthResponse) {
+	if h.sessions == nil {
+		c.JSON(http.StatusOK, authResponse)
+		return
+	}
+
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "session_failed",
+			Message: "Failed to start session",
+		})
+		return
+	}
+
+	h.sessions.set(c, sessionCookie, authResponse.AccessToken, "/", h.sessions.sessionTTL, true)
+	if authResponse.RefreshToken != "" {
+		h.sessions.set(c, refreshCookie, authResponse.RefreshToken, "/auth", h.sessions.refreshTTL, true)
+	}
+	h.sessions.set(c, csrfCookie, csrfToken, "/", h.sessions.refreshTTL, false)
+
+	c.JSON(http.StatusOK, sessionResponse{
+		IdToken:   authResponse.IdToken,
+		User:      authResponse.User,
+		CSRFToken: csrfToken,
+	})
+}
+
+// clearSession removes the session cookies on sign-out
+func (h *AuthHandler) clearSession(c *gin.Context) {
+	if h.sessions != nil {
+		h.sessions.clear(c)
+	}
+}
+
+// bearerToken returns the request's access token from the Authorization
+// header, or in cookie mode from the session cookie
+func (h *AuthHandler) bearerToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		return header
+	}
+	if h.sessions != nil {
+		if token, err := c.Cookie(sessionCookie); err == nil {
+			return token
+		}
+	}
+	return ""
+}
+
+// sessionAuthorization passes the session cookie on as a bearer token, for
+// middleware that only reads the Authorization header
+func (h *AuthHandler) sessionAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := h.bearerToken(c); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
+// refreshTokenCookie returns the refresh cookie in cookie mode
+func (h *AuthHandler) refreshTokenCookie(c *gin.Context) string {
+	if h.sessions == nil {
+		return ""
+	}
+	token, _ := c.Cookie(refreshCookie)
+	return token
+}
+
+// CSRFProtect rejects state-changing requests authenticated by cookie unless
+// they echo the CSRF cookie in the X-CSRF-Token header (double submit).
+// Requests with an Authorization header carry no ambient credentials and
+// pass through, as does everything in token mode.
+func (h *AuthHandler) CSRFProtect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.sessions == nil || !unsafeMethod(c.Request.Method) || c.GetHeader("Authorization") != "" {
+			c.Next()
+			return
+		}
+
+		_, sessionErr := c.Cookie(sessionCookie)
+		_, refreshErr := c.Cookie(refreshCookie)
+		if sessionErr != nil && refreshErr != nil {
+			c.Next()
+			return
+		}
+
+		expected, _ := c.Cookie(csrfCookie)
+		got := c.GetHeader(csrfHeader)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(got)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Error:   "csrf_failed",
+				Message: "Missing or invalid CSRF token",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func unsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRFProtect(t *testing.T) {
+	cookieMode := &AuthHandler{sessions: &sessionCookies{}}
+	tokenMode := &AuthHandler{}
+
+	tests := []struct {
+		name    string
+		handler *AuthHandler
+		method  string
+		cookies map[string]string
+		headers map[string]string
+		want    int
+	}{
+		{"token mode", tokenMode, http.MethodPost, map[string]string{sessionCookie: "access"}, nil, http.StatusOK},
+		{"safe method", cookieMode, http.MethodGet, map[string]string{sessionCookie: "access"}, nil, http.StatusOK},
+		{"no session cookies", cookieMode, http.MethodPost, nil, nil, http.StatusOK},
+		{"authorization header", cookieMode, http.MethodPost, map[string]string{sessionCookie: "access"}, map[string]string{"Authorization": "Bearer access"}, http.StatusOK},
+		{"matching token", cookieMode, http.MethodPost, map[string]string{sessionCookie: "access", csrfCookie: "csrf"}, map[string]string{csrfHeader: "csrf"}, http.StatusOK},
+		{"refresh cookie with matching token", cookieMode, http.MethodPost, map[string]string{refreshCookie: "refresh", csrfCookie: "csrf"}, map[string]string{csrfHeader: "csrf"}, http.StatusOK},
+		{"missing header", cookieMode, http.MethodPost, map[string]string{sessionCookie: "access", csrfCookie: "csrf"}, nil, http.StatusForbidden},
+		{"wrong header", cookieMode, http.MethodDelete, map[string]string{sessionCookie: "access", csrfCookie: "csrf"}, map[string]string{csrfHeader: "other"}, http.StatusForbidden},
+		{"missing cookie", cookieMode, http.MethodPut, map[string]string{sessionCookie: "access"}, map[string]string{csrfHeader: ""}, http.StatusForbidden},
+		{"refresh cookie without token", cookieMode, http.MethodPost, map[string]string{refreshCookie: "refresh"}, nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(tt.handler.CSRFProtect())
+			r.Handle(tt.method, "/auth/signout", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, "/auth/signout", nil)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestNewSessionCookies(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantNil      bool
+		wantErr      bool
+		wantSecure   bool
+		wantSameSite http.SameSite
+	}{
+		{"token mode", map[string]string{"SESSION_MODE": ""}, true, false, false, 0},
+		{"cookie mode defaults", map[string]string{"SESSION_MODE": "cookie"}, false, false, true, http.SameSiteLaxMode},
+		{"insecure for localhost", map[string]string{"SESSION_MODE": "cookie", "SESSION_COOKIE_SECURE": "false"}, false, false, false, http.SameSiteLaxMode},
+		{"strict", map[string]string{"SESSION_MODE": "cookie", "SESSION_COOKIE_SAMESITE": "Strict"}, false, false, true, http.SameSiteStrictMode},
+		{"none", map[string]string{"SESSION_MODE": "cookie", "SESSION_COOKIE_SAMESITE": "none"}, false, false, true, http.SameSiteNoneMode},
+		{"none needs secure", map[string]string{"SESSION_MODE": "cookie", "SESSION_COOKIE_SAMESITE": "none", "SESSION_COOKIE_SECURE": "false"}, false, true, false, 0},
+		{"unknown samesite", map[string]string{"SESSION_MODE": "cookie", "SESSION_COOKIE_SAMESITE": "sometimes"}, false, true, false, 0},
+		{"unknown mode", map[string]string{"SESSION_MODE": "jwt"}, false, true, false, 0},
+		{"invalid ttl", map[string]string{"SESSION_MODE": "cookie", "SESSION_COOKIE_TTL": "soon"}, false, true, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"SESSION_MODE", "SESSION_COOKIE_SECURE", "SESSION_COOKIE_SAMESITE", "SESSION_COOKIE_TTL", "SESSION_REFRESH_TTL"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			s, err := newSessionCookies()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSessionCookies() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (s == nil) != tt.wantNil {
+				t.Fatalf("newSessionCookies() = %+v, want nil %v", s, tt.wantNil)
+			}
+			if s != nil && (s.secure != tt.wantSecure || s.sameSite != tt.wantSameSite) {
+				t.Errorf("secure = %v, sameSite = %v; want %v, %v", s.secure, s.sameSite, tt.wantSecure, tt.wantSameSite)
+			}
+		})
+	}
+}
+
+func TestCrossSiteCookie(t *testing.T) {
+	tests := []struct {
+		name         string
+		sessions     *sessionCookies
+		secureEnv    string
+		wantDomain   string
+		wantSecure   bool
+		wantSameSite http.SameSite
+	}{
+		{"token mode", nil, "", "", true, http.SameSiteNoneMode},
+		{"token mode over http", nil, "false", "", false, http.SameSiteLaxMode},
+		{"cookie mode", &sessionCookies{domain: "example.com", secure: true, sameSite: http.SameSiteStrictMode}, "", "example.com", true, http.SameSiteNoneMode},
+		{"cookie mode over http", &sessionCookies{secure: false, sameSite: http.SameSiteLaxMode}, "false", "", false, http.SameSiteLaxMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SESSION_COOKIE_SECURE", tt.secureEnv)
+
+			h := &AuthHandler{sessions: tt.sessions}
+			cookie := h.crossSiteCookie(oauthStateCookie, "/auth/oauth")
+			if cookie.Domain != tt.wantDomain || cookie.Secure != tt.wantSecure || cookie.SameSite != tt.wantSameSite || !cookie.HttpOnly {
+				t.Errorf("cookie = %+v, want domain %q, secure %v, sameSite %v", cookie, tt.wantDomain, tt.wantSecure, tt.wantSameSite)
+			}
+		})
+	}
+}
+
+func TestRespondWithSessionCookies(t *testing.T) {
+	h := &AuthHandler{sessions: &sessionCookies{
+		secure:     true,
+		sameSite:   http.SameSiteLaxMode,
+		sessionTTL: time.Hour,
+		refreshTTL: 24 * time.Hour,
+	}}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	h.respondWithSession(c, &cognito.AuthResponse{
+		AccessToken:  "access",
+		IdToken:      "id",
+		RefreshToken: "refresh",
+	})
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := body["accessToken"]; found {
+		t.Error("access token was returned to JavaScript in cookie mode")
+	}
+	if _, found := body["refreshToken"]; found {
+		t.Error("refresh token was returned to JavaScript in cookie mode")
+	}
+
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		path     string
+		httpOnly bool
+	}{
+		{sessionCookie, "access", "/", true},
+		{refreshCookie, "refresh", "/auth", true},
+		{csrfCookie, body["csrfToken"].(string), "/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookie, found := cookies[tt.name]
+			if !found {
+				t.Fatalf("cookie %s was not set", tt.name)
+			}
+			if cookie.Value != tt.value || cookie.Path != tt.path || cookie.HttpOnly != tt.httpOnly || !cookie.Secure {
+				t.Errorf("cookie = %+v, want value %q, path %q, HttpOnly %v and Secure", cookie, tt.value, tt.path, tt.httpOnly)
+			}
+		})
+	}
+}
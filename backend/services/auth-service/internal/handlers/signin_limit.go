@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/audit"
+	"github.com/ec-recommend/auth-service/internal/captcha"
+	"github.com/ec-recommend/auth-service/internal/lockout"
+	"github.com/gin-gonic/gin"
+)
+
+// signInErrorResponse is a sign-in failure with the throttling details the
+// client needs to decide whether to show a CAPTCHA or wait
+type signInErrorResponse struct {
+	ErrorResponse
+	// RetryAfter is in seconds and also sent as the Retry-After header
+	RetryAfter      int  `json:"retryAfter,omitempty"`
+	CaptchaRequired bool `json:"captchaRequired"`
+}
+
+// newSignInLimiter builds the sign-in throttle from SIGNIN_* settings,
+// keeping failure counts in Redis when REDIS_URL is set. Lockouts go to the
+// audit trail. The CAPTCHA step is only asked for when CAPTCHA_SECRET is set
+// to verify the answers, and the returned verifier is nil otherwise.
+func newSignInLimiter(recorder *audit.Recorder) (*lockout.Limiter, *captcha.Verifier, error) {
+	var cfg lockout.Config
+	var err error
+
+	for name, target := range map[string]*int{
+		"SIGNIN_MAX_FAILURES":    &cfg.MaxFailures,
+		"SIGNIN_IP_MAX_FAILURES": &cfg.IPMaxFailures,
+		"SIGNIN_CAPTCHA_AFTER":   &cfg.CaptchaAfter,
+	} {
+		if value := os.Getenv(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return nil, nil, fmt.Errorf("invalid %s %q", name, value)
+			}
+		}
+	}
+
+	for name, target := range map[string]*time.Duration{
+		"SIGNIN_BACKOFF_BASE":     &cfg.BaseDelay,
+		"SIGNIN_BACKOFF_MAX":      &cfg.MaxDelay,
+		"SIGNIN_LOCKOUT_DURATION": &cfg.LockoutDuration,
+		"SIGNIN_FAILURE_WINDOW":   &cfg.Window,
+	} {
+		if *target, err = durationEnv(name, 0); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var verifier *captcha.Verifier
+	if secret := os.Getenv("CAPTCHA_SECRET"); secret != "" {
+		verifyURL := os.Getenv("CAPTCHA_VERIFY_URL")
+		if verifyURL == "" {
+			verifyURL = captcha.DefaultVerifyURL
+		}
+		verifier = captcha.NewVerifier(verifyURL, secret)
+	} else if cfg.CaptchaAfter >= 0 {
+		log.Println("CAPTCHA_SECRET is not set; sign-in does not ask for a CAPTCHA")
+		cfg.CaptchaAfter = -1
+	}
+
+	var store lockout.Store
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		if store, err = lockout.NewRedisStore(redisURL); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		log.Println("REDIS_URL is not set; sign-in failure counts are kept per process")
+		store = lockout.NewMemoryStore()
+	}
+
+	return lockout.NewLimiter(cfg, store, auditLockout(recorder)), verifier, nil
+}
+
+// auditLockout records lock events, under the email when an email was
+// locked so they show up in its audit search
+func auditLockout(recorder *audit.Recorder) lockout.Notifier {
+	return func(ctx context.Context, e lockout.Event) {
+		event := audit.Event{
+			Email:   e.Email,
+			Details: map[string]string{"subject": "email"},
+		}
+		if e.Email == "" {
+			event.Details = map[string]string{"subject": "ip", "lockedIp": e.IP}
+		}
+
+		switch e.Type {
+		case lockout.EventLocked:
+			event.Type = auditSignInLocked
+			event.Outcome = audit.OutcomeFailure
+			event.Reason = "too_many_failures"
+			event.Details["failures"] = strconv.Itoa(e.Failures)
+			event.Details["lockedUntil"] = e.Until.UTC().Format(time.RFC3339)
+		case lockout.EventUnlocked:
+			event.Type = auditSignInUnlocked
+			event.Outcome = audit.OutcomeSuccess
+			event.Details["reason"] = "expired"
+		}
+
+		recorder.Record(ctx, event)
+	}
+}
+
+// signInThrottled refuses a sign-in that arrived during backoff or lockout
+func signInThrottled(c *gin.Context, decision lockout.Decision) {
+	retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+
+	resp := signInErrorResponse{
+		ErrorResponse: ErrorResponse{
+			Error:   "too_many_attempts",
+			Message: "Too many failed sign-in attempts, try again later",
+		},
+		RetryAfter:      retryAfter,
+		CaptchaRequired: decision.CaptchaRequired,
+	}
+	if decision.Locked {
+		resp.Error = "account_locked"
+		resp.Message = "Sign-in is temporarily locked after too many failed attempts"
+	}
+
+	c.JSON(http.StatusTooManyRequests, resp)
+}
+
+// signInCaptchaFailed refuses a sign-in that needed a CAPTCHA and came
+// without a valid one. It doesn't count as a failure, since the password
+// was never checked.
+func signInCaptchaFailed(c *gin.Context) {
+	c.JSON(http.StatusUnauthorized, signInErrorResponse{
+		ErrorResponse: ErrorResponse{
+			Error:   "captcha_required",
+			Message: "Complete the CAPTCHA to sign in",
+		},
+		CaptchaRequired: true,
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/local"
+	"github.com/ec-recommend/auth-service/internal/lockout"
+)
+
+// challengeProvider asks for an MFA code after every correct password
+type challengeProvider struct {
+	*local.Provider
+	pending *cognito.AuthResponse
+}
+
+const testMFACode = "123456"
+
+func (p *challengeProvider) SignIn(ctx context.Context, req cognito.SignInRequest) (*cognito.AuthResponse, *cognito.Challenge, error) {
+	resp, _, err := p.Provider.SignIn(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.pending = resp
+	return nil, &cognito.Challenge{ChallengeName: "SOFTWARE_TOKEN_MFA", Session: "mfa-session"}, nil
+}
+
+func (p *challengeProvider) RespondToChallenge(ctx context.Context, answer cognito.ChallengeAnswer) (*cognito.AuthResponse, *cognito.Challenge, error) {
+	if p.pending == nil || answer.Code != testMFACode {
+		return nil, nil, cognito.ErrNotAuthorized
+	}
+	return p.pending, nil, nil
+}
+
+func TestSignInFailuresClearAfterMFA(t *testing.T) {
+	h, p := newLocalHandler(t)
+	signedIn(t, p, "alice@example.com")
+
+	store := lockout.NewMemoryStore()
+	h.signins = lockout.NewLimiter(lockout.Config{BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond}, store, nil)
+	h.provider = &challengeProvider{Provider: p}
+
+	failures := func() int {
+		state, err := store.Get(context.Background(), "email:alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state.Failures
+	}
+
+	if code, resp := serve(t, h.SignIn, `{"email":"alice@example.com","password":"wrong-password"}`, nil); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: SignIn() = %d %q, want 401", code, resp.Error)
+	}
+	if n := failures(); n != 1 {
+		t.Fatalf("after a wrong password: %d failures, want 1", n)
+	}
+
+	// The right password only gets as far as the MFA challenge, so the
+	// failure stays until the code is answered
+	if code, resp := serve(t, h.SignIn, `{"email":"alice@example.com","password":"`+testPassword+`"}`, nil); code != http.StatusOK {
+		t.Fatalf("right password: SignIn() = %d %q, want 200", code, resp.Error)
+	}
+	if n := failures(); n != 1 {
+		t.Fatalf("after the password: %d failures, want 1", n)
+	}
+
+	body := `{"challengeName":"SOFTWARE_TOKEN_MFA","session":"mfa-session","email":"alice@example.com","code":"` + testMFACode + `"}`
+	if code, resp := serve(t, h.RespondToChallenge, body, nil); code != http.StatusOK {
+		t.Fatalf("RespondToChallenge() = %d %q, want 200", code, resp.Error)
+	}
+	if n := failures(); n != 0 {
+		t.Errorf("after the MFA code: %d failures, want none", n)
+	}
+}
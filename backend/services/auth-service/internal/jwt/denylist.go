@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// Denylist records revoked tokens so they stop validating before they expire
+type Denylist interface {
+	// RevokeToken denies the token with the given jti until it expires
+	RevokeToken(jti string, expiresAt time.Time)
+	// RevokeSubject denies every token for subject issued before revokedAt.
+	// Token times are whole seconds, so tokens issued in revokedAt's second
+	// stay valid; otherwise a sign-in right after the revocation would be
+	// rejected.
+	RevokeSubject(subject string, revokedAt time.Time)
+	IsRevoked(claims *Claims) bool
+}
+
+// maxTokenLifetime bounds how long a subject-wide revocation must be kept.
+// Cognito access and ID tokens are valid for at most one day.
+const maxTokenLifetime = 24 * time.Hour
+
+// MemoryDenylist is an in-process Denylist
+type MemoryDenylist struct {
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	subjects map[string]time.Time
+}
+
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{
+		tokens:   make(map[string]time.Time),
+		subjects: make(map[string]time.Time),
+	}
+}
+
+func (d *MemoryDenylist) RevokeToken(jti string, expiresAt time.Time) {
+	if jti == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.purgeLocked()
+	d.tokens[jti] = expiresAt
+}
+
+func (d *MemoryDenylist) RevokeSubject(subject string, revokedAt time.Time) {
+	if subject == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.purgeLocked()
+	d.subjects[subject] = revokedAt
+}
+
+func (d *MemoryDenylist) IsRevoked(claims *Claims) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if _, found := d.tokens[claims.ID]; found && claims.ID != "" {
+		return true
+	}
+
+	if revokedAt, found := d.subjects[claims.Subject]; found && claims.IssuedAt < revokedAt.Unix() {
+		return true
+	}
+
+	return false
+}
+
+// purgeLocked drops entries for tokens that have expired anyway
+func (d *MemoryDenylist) purgeLocked() {
+	now := time.Now()
+	for jti, expiresAt := range d.tokens {
+		if expiresAt.Before(now) {
+			delete(d.tokens, jti)
+		}
+	}
+	for subject, revokedAt := range d.subjects {
+		if now.Sub(revokedAt) > maxTokenLifetime {
+			delete(d.subjects, subject)
+		}
+	}
+}
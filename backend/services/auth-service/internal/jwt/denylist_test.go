@@ -0,0 +1,147 @@
+package jwt
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestRedisDenylist(t *testing.T) (*RedisDenylist, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	d, err := NewRedisDenylist("redis://" + server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisDenylist: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d, server
+}
+
+func tokenID(jti, subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{ID: jti, Subject: subject}
+}
+
+func formatUnix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func TestDenylist(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		revoke func(Denylist)
+		claims Claims
+		want   bool
+	}{
+		{"nothing revoked", func(Denylist) {}, Claims{RegisteredClaims: tokenID("jti-1", "alice"), IssuedAt: now.Unix()}, false},
+		{"revoked token", func(d Denylist) { d.RevokeToken("jti-1", now.Add(time.Hour)) }, Claims{RegisteredClaims: tokenID("jti-1", "alice"), IssuedAt: now.Unix()}, true},
+		{"other token", func(d Denylist) { d.RevokeToken("jti-1", now.Add(time.Hour)) }, Claims{RegisteredClaims: tokenID("jti-2", "alice"), IssuedAt: now.Unix()}, false},
+		{"empty jti is never revoked", func(d Denylist) { d.RevokeToken("", now.Add(time.Hour)) }, Claims{RegisteredClaims: tokenID("", "alice"), IssuedAt: now.Unix()}, false},
+		{"subject revoked after issue", func(d Denylist) { d.RevokeSubject("alice", now) }, Claims{RegisteredClaims: tokenID("jti-1", "alice"), IssuedAt: now.Add(-time.Minute).Unix()}, true},
+		{"subject revoked the second after issue", func(d Denylist) { d.RevokeSubject("alice", now) }, Claims{RegisteredClaims: tokenID("jti-1", "alice"), IssuedAt: now.Unix() - 1}, true},
+		// A sign-in right after signing out everywhere gets a working token
+		{"issued in the second of subject revocation", func(d Denylist) { d.RevokeSubject("alice", now) }, Claims{RegisteredClaims: tokenID("jti-1", "alice"), IssuedAt: now.Unix()}, false},
+		{"issued after subject revocation", func(d Denylist) { d.RevokeSubject("alice", now.Add(-time.Minute)) }, Claims{RegisteredClaims: tokenID("jti-1", "alice"), IssuedAt: now.Unix()}, false},
+		{"other subject", func(d Denylist) { d.RevokeSubject("alice", now) }, Claims{RegisteredClaims: tokenID("jti-1", "bob"), IssuedAt: now.Add(-time.Minute).Unix()}, false},
+	}
+
+	backends := map[string]func(t *testing.T) Denylist{
+		"memory": func(t *testing.T) Denylist { return NewMemoryDenylist() },
+		"redis": func(t *testing.T) Denylist {
+			d, _ := newTestRedisDenylist(t)
+			return d
+		},
+	}
+
+	for backend, newDenylist := range backends {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				d := newDenylist(t)
+				tt.revoke(d)
+				if got := d.IsRevoked(&tt.claims); got != tt.want {
+					t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMemoryDenylistPurgesExpiredTokens(t *testing.T) {
+	d := NewMemoryDenylist()
+	d.RevokeToken("expired", time.Now().Add(-time.Second))
+	d.RevokeSubject("old", time.Now().Add(-2*maxTokenLifetime))
+
+	// Purging happens on the next write
+	d.RevokeToken("current", time.Now().Add(time.Hour))
+
+	if _, found := d.tokens["expired"]; found {
+		t.Error("expired token revocation was kept")
+	}
+	if _, found := d.subjects["old"]; found {
+		t.Error("subject revocation older than any token was kept")
+	}
+	if _, found := d.tokens["current"]; !found {
+		t.Error("current token revocation was dropped")
+	}
+}
+
+func TestRedisDenylistKeys(t *testing.T) {
+	d, server := newTestRedisDenylist(t)
+
+	d.RevokeToken("jti-1", time.Now().Add(time.Hour))
+	d.RevokeToken("jti-expired", time.Now().Add(-time.Second))
+	revokedAt := time.Now()
+	d.RevokeSubject("alice", revokedAt)
+
+	// The shared auth middleware reads these keys, so their layout is fixed
+	if ttl := server.TTL(redisTokenPrefix + "jti-1"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("token revocation TTL = %v, want up to the token's expiry", ttl)
+	}
+	if server.Exists(redisTokenPrefix + "jti-expired") {
+		t.Error("revocation of an expired token was stored")
+	}
+	if got, _ := server.Get(redisSubjectPrefix + "alice"); got != formatUnix(revokedAt) {
+		t.Errorf("subject revocation = %q, want %q", got, formatUnix(revokedAt))
+	}
+	if ttl := server.TTL(redisSubjectPrefix + "alice"); ttl != maxTokenLifetime {
+		t.Errorf("subject revocation TTL = %v, want %v", ttl, maxTokenLifetime)
+	}
+}
+
+func TestRedisDenylistFailsOpen(t *testing.T) {
+	d, server := newTestRedisDenylist(t)
+	d.RevokeToken("jti-1", time.Now().Add(time.Hour))
+	server.Close()
+
+	claims := Claims{RegisteredClaims: tokenID("jti-1", "alice")}
+	if d.IsRevoked(&claims) {
+		t.Error("IsRevoked() = true with Redis down, want the outage to let tokens through")
+	}
+}
+
+func TestValidateTokenRejectsRevokedTokens(t *testing.T) {
+	denylist := NewMemoryDenylist()
+	v, signer := newTestValidator(t, WithDenylist(denylist))
+
+	revoked := mint(t, signer, Claims{})
+	kept := mint(t, signer, Claims{})
+
+	claims, err := v.ValidateToken(revoked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	denylist.RevokeToken(claims.ID, time.Unix(claims.ExpTime, 0))
+
+	if _, err := v.ValidateToken(revoked); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("revoked token: error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := v.ValidateToken(kept); err != nil {
+		t.Errorf("other token: error = %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Validation failures. Errors returned by ValidateToken wrap one of these,
+// so callers can tell an expired token from a forged one with errors.Is.
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrUnknownKey       = errors.New("signing key not found in JWKS")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenNotYetValid = errors.New("token not valid yet")
+	ErrInvalidIssuer    = errors.New("invalid issuer")
+	ErrInvalidAudience  = errors.New("invalid audience")
+	ErrInvalidTokenUse  = errors.New("invalid token use")
+	ErrMissingScope     = errors.New("missing required scope")
+	ErrMissingClaim     = errors.New("missing required claim")
+	ErrAuthTooOld       = errors.New("authentication is too old")
+	ErrTokenRevoked     = errors.New("token revoked")
+)
+
+// Policy is what a token must satisfy beyond a valid signature
+type Policy struct {
+	// TokenUses lists the accepted token_use values; empty accepts access and id
+	TokenUses []string
+	// ClientIDs lists the accepted app clients, matched against aud for ID
+	// tokens and client_id for access tokens; empty accepts any client
+	ClientIDs []string
+	// Scopes must all be present in the token's scope claim
+	Scopes []string
+	// Leeway tolerates clock skew on exp, nbf and iat
+	Leeway time.Duration
+	// MaxAuthAge rejects tokens whose auth_time is older; zero disables it
+	MaxAuthAge time.Duration
+	// RequiredClaims must be present in the token
+	RequiredClaims []string
+}
+
+// Requirement adjusts a Policy. Passed to ValidateToken it applies to that
+// call only, on top of the validator's default policy: TokenUses, ClientIDs,
+// Leeway and MaxAuthAge replace the defaults, Scopes and RequiredClaims add
+// to them.
+type Requirement func(*Policy)
+
+// TokenUses accepts only the given token_use values ("access", "id")
+func TokenUses(uses ...string) Requirement {
+	return func(p *Policy) {
+		p.TokenUses = uses
+	}
+}
+
+// ClientIDs accepts only tokens issued to the given app clients
+func ClientIDs(ids ...string) Requirement {
+	return func(p *Policy) {
+		p.ClientIDs = ids
+	}
+}
+
+// Scopes requires every given scope
+func Scopes(scopes ...string) Requirement {
+	return func(p *Policy) {
+		p.Scopes = append(p.Scopes[:len(p.Scopes):len(p.Scopes)], scopes...)
+	}
+}
+
+// Leeway tolerates clock skew of up to d
+func Leeway(d time.Duration) Requirement {
+	return func(p *Policy) {
+		p.Leeway = d
+	}
+}
+
+// MaxAuthAge requires the user to have authenticated within d
+func MaxAuthAge(d time.Duration) Requirement {
+	return func(p *Policy) {
+		p.MaxAuthAge = d
+	}
+}
+
+// RequiredClaims requires every given claim to be present
+func RequiredClaims(names ...string) Requirement {
+	return func(p *Policy) {
+		p.RequiredClaims = append(p.RequiredClaims[:len(p.RequiredClaims):len(p.RequiredClaims)], names...)
+	}
+}
+
+// with returns a copy of p with reqs applied
+func (p Policy) with(reqs []Requirement) Policy {
+	for _, req := range reqs {
+		req(&p)
+	}
+	return p
+}
+
+// check validates everything but the signature and issuer
+func (p Policy) check(claims *Claims, now time.Time) error {
+	if claims.ExpTime == 0 {
+		return fmt.Errorf("%w: exp", ErrMissingClaim)
+	}
+	if now.Unix() > claims.ExpTime+int64(p.Leeway.Seconds()) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != nil && now.Add(p.Leeway).Before(claims.NotBefore.Time) {
+		return ErrTokenNotYetValid
+	}
+	if claims.IssuedAt > now.Add(p.Leeway).Unix() {
+		return ErrTokenNotYetValid
+	}
+
+	uses := p.TokenUses
+	if len(uses) == 0 {
+		uses = []string{"access", "id"}
+	}
+	if !contains(uses, claims.TokenUse) {
+		return fmt.Errorf("%w: %s", ErrInvalidTokenUse, claims.TokenUse)
+	}
+
+	if len(p.ClientIDs) > 0 {
+		switch claims.TokenUse {
+		case "id":
+			matched := false
+			for _, aud := range claims.Audience {
+				matched = matched || contains(p.ClientIDs, aud)
+			}
+			if !matched {
+				return fmt.Errorf("%w: %v", ErrInvalidAudience, []string(claims.Audience))
+			}
+		default:
+			if !contains(p.ClientIDs, claims.ClientID) {
+				return fmt.Errorf("%w: %s", ErrInvalidAudience, claims.ClientID)
+			}
+		}
+	}
+
+	if len(p.Scopes) > 0 {
+		granted := strings.Fields(claims.Scope)
+		for _, scope := range p.Scopes {
+			if !contains(granted, scope) {
+				return fmt.Errorf("%w: %s", ErrMissingScope, scope)
+			}
+		}
+	}
+
+	if p.MaxAuthAge > 0 {
+		if claims.AuthTime == 0 {
+			return fmt.Errorf("%w: auth_time", ErrMissingClaim)
+		}
+		if now.Sub(time.Unix(claims.AuthTime, 0)) > p.MaxAuthAge+p.Leeway {
+			return ErrAuthTooOld
+		}
+	}
+
+	for _, name := range p.RequiredClaims {
+		if _, found := claims.raw[name]; !found {
+			return fmt.Errorf("%w: %s", ErrMissingClaim, name)
+		}
+	}
+
+	return nil
+}
+
+// claimsFromMap decodes parsed claims into Claims, keeping the raw map for
+// Claim. NumericDate claims may carry a fraction of a second, which is
+// truncated.
+func claimsFromMap(m jwt.MapClaims) (*Claims, error) {
+	claims := &Claims{}
+	dates := map[string]*int64{
+		"exp":       &claims.ExpTime,
+		"iat":       &claims.IssuedAt,
+		"auth_time": &claims.AuthTime,
+	}
+
+	rest := make(jwt.MapClaims, len(m))
+	for name, value := range m {
+		if _, isDate := dates[name]; !isDate {
+			rest[name] = value
+		}
+	}
+
+	data, err := json.Marshal(rest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if err := json.Unmarshal(data, claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+
+	for name, field := range dates {
+		value, found := m[name]
+		if !found {
+			continue
+		}
+		seconds, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not a number", ErrMalformedToken, name)
+		}
+		*field = int64(seconds)
+	}
+	claims.raw = m
+
+	return claims, nil
+}
+
+// parseError turns golang-jwt parse errors into this package's errors
+func parseError(err error) error {
+	switch {
+	case errors.Is(err, ErrUnknownKey):
+		return ErrUnknownKey
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
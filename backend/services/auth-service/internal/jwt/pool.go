@@ -0,0 +1,63 @@
+package jwt
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ec-recommend/backend/shared/go/middleware"
+)
+
+// Pool is a Cognito user pool whose tokens a Validator trusts
+type Pool struct {
+	// Name is the realm reported in Claims.Pool (e.g. "buyer", "seller");
+	// it defaults to the user pool ID
+	Name       string
+	UserPoolID string
+	Region     string
+	// ClientIDs are the pool's app clients whose tokens are accepted
+	ClientIDs []string
+	// KeySource overrides fetching the pool's JWKS endpoint
+	KeySource KeySource
+}
+
+func (p Pool) issuer() string {
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", p.Region, p.UserPoolID)
+}
+
+// jwksURL is the pool's JWKS endpoint, or the mock's when COGNITO_ENDPOINT
+// is set
+func (p Pool) jwksURL() string {
+	if cognitoEndpoint := os.Getenv("COGNITO_ENDPOINT"); cognitoEndpoint != "" {
+		return fmt.Sprintf("%s/%s/.well-known/jwks.json", cognitoEndpoint, p.UserPoolID)
+	}
+	return p.issuer() + "/.well-known/jwks.json"
+}
+
+// poolKeys is a trusted pool with its signing keys. The keys are held in
+// the shared middleware's JWKS cache, so this service and the others that
+// verify the pool's tokens refresh and rotate keys the same way.
+type poolKeys struct {
+	pool Pool
+	keys *middleware.JWKSCache
+}
+
+// newPoolKeys loads the pool's keys and starts refreshing them in the
+// background
+func newPoolKeys(pool Pool, refreshInterval, minRefetchInterval, staleKeyGrace time.Duration) (*poolKeys, error) {
+	if pool.Name == "" {
+		pool.Name = pool.UserPoolID
+	}
+
+	opts := []middleware.JWKSOption{middleware.WithStaleKeyGrace(staleKeyGrace)}
+	if pool.KeySource != nil {
+		opts = append(opts, middleware.WithKeySetLoader(middleware.KeySetLoader(pool.KeySource)))
+	}
+
+	keys, err := middleware.NewJWKSCache(pool.jwksURL(), refreshInterval, minRefetchInterval, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("pool %s: %w", pool.Name, err)
+	}
+
+	return &poolKeys{pool: pool, keys: keys}, nil
+}
@@ -0,0 +1,187 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// rotatingKeys is a KeySource publishing whichever signers are current
+type rotatingKeys struct {
+	mu      sync.Mutex
+	signers []*TestSigner
+	fail    bool
+	fetches int
+}
+
+func (r *rotatingKeys) publish(signers ...*TestSigner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.signers = signers
+}
+
+func (r *rotatingKeys) setFailing(fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = fail
+}
+
+func (r *rotatingKeys) fetchCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fetches
+}
+
+func (r *rotatingKeys) JWKS(ctx context.Context) (jwk.Set, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fetches++
+	if r.fail {
+		return nil, errors.New("JWKS endpoint unavailable")
+	}
+
+	set := jwk.NewSet()
+	for _, signer := range r.signers {
+		keys, err := signer.JWKS(ctx)
+		if err != nil {
+			return nil, err
+		}
+		key, _ := keys.Key(0)
+		set.AddKey(key)
+	}
+	return set, nil
+}
+
+func newTestSigners(t *testing.T, n int) []*TestSigner {
+	t.Helper()
+
+	signers := make([]*TestSigner, n)
+	for i := range signers {
+		signer, err := NewTestSigner(testPoolID, testRegion, testClientID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers[i] = signer
+	}
+	return signers
+}
+
+func newRotatingValidator(t *testing.T, keys *rotatingKeys, opts ...Option) *Validator {
+	t.Helper()
+
+	v, err := NewValidator(testPoolID, testRegion, testClientID, append([]Option{WithKeySource(keys.JWKS)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewValidator: %v", err)
+	}
+	t.Cleanup(v.Close)
+	return v
+}
+
+func TestUnknownKidRefetch(t *testing.T) {
+	tests := []struct {
+		name        string
+		minRefetch  time.Duration
+		wantErr     error
+		wantFetches int
+	}{
+		// The new key is picked up by refetching on its unknown kid
+		{"refetches", time.Nanosecond, nil, 2},
+		// A refetch too soon after the last fetch is skipped
+		{"rate limited", time.Hour, ErrUnknownKey, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signers := newTestSigners(t, 2)
+			keys := &rotatingKeys{}
+			keys.publish(signers[0])
+			v := newRotatingValidator(t, keys, WithMinRefetchInterval(tt.minRefetch))
+
+			keys.publish(signers[0], signers[1])
+			time.Sleep(time.Millisecond)
+
+			_, err := v.ValidateToken(mint(t, signers[1], Claims{}))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := keys.fetchCount(); got != tt.wantFetches {
+				t.Errorf("fetches = %d, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestRetiredKeyGrace(t *testing.T) {
+	const grace = 100 * time.Millisecond
+
+	signers := newTestSigners(t, 2)
+	keys := &rotatingKeys{}
+	keys.publish(signers[0])
+	v := newRotatingValidator(t, keys, WithStaleKeyGrace(grace), WithMinRefetchInterval(time.Hour))
+
+	oldToken := mint(t, signers[0], Claims{})
+
+	// Rotate the old key out
+	keys.publish(signers[1])
+	if err := v.RefreshJWKS(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.ValidateToken(oldToken); err != nil {
+		t.Fatalf("token signed just before the rotation: %v", err)
+	}
+	if _, err := v.ValidateToken(mint(t, signers[1], Claims{})); err != nil {
+		t.Fatalf("token signed with the new key: %v", err)
+	}
+
+	// Let the retired key age past the grace window
+	time.Sleep(2 * grace)
+
+	if _, err := v.ValidateToken(oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("token signed with a key retired past its grace: error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestRefreshFailureKeepsKeys(t *testing.T) {
+	signers := newTestSigners(t, 1)
+	keys := &rotatingKeys{}
+	keys.publish(signers[0])
+	v := newRotatingValidator(t, keys)
+
+	keys.setFailing(true)
+	if err := v.RefreshJWKS(); err == nil {
+		t.Fatal("RefreshJWKS() = nil, want the source's error")
+	}
+
+	if _, err := v.ValidateToken(mint(t, signers[0], Claims{})); err != nil {
+		t.Fatalf("ValidateToken() after a failed refresh: %v", err)
+	}
+}
+
+func TestNewValidatorFailsWithoutKeys(t *testing.T) {
+	keys := &rotatingKeys{fail: true}
+
+	if _, err := NewValidator(testPoolID, testRegion, testClientID, WithKeySource(keys.JWKS)); err == nil {
+		t.Fatal("NewValidator() = nil error with an unavailable JWKS")
+	}
+}
+
+func TestBackgroundRefresh(t *testing.T) {
+	signers := newTestSigners(t, 1)
+	keys := &rotatingKeys{}
+	keys.publish(signers[0])
+	newRotatingValidator(t, keys, WithRefreshInterval(5*time.Millisecond))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for keys.fetchCount() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("fetches = %d after 2s, want the JWKS refreshed in the background", keys.fetchCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,105 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys of the shared revocation list. Other services read them
+// through the shared auth middleware, so the layout is part of its contract:
+// a revoked jti maps to "1" until the token expires, a revoked subject to
+// the Unix time before which its tokens are rejected.
+const (
+	redisTokenPrefix   = "auth:revoked:jti:"
+	redisSubjectPrefix = "auth:revoked:sub:"
+	redisTimeout       = 2 * time.Second
+)
+
+// RedisDenylist keeps revocations in Redis so every service instance, and
+// every service using the shared auth middleware, rejects the same tokens.
+// Redis errors are logged and treated as not revoked rather than failing
+// every request.
+type RedisDenylist struct {
+	client *redis.Client
+}
+
+// NewRedisDenylist connects to the Redis server at url (redis://...)
+func NewRedisDenylist(url string) (*RedisDenylist, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %v", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return &RedisDenylist{client: client}, nil
+}
+
+func (d *RedisDenylist) RevokeToken(jti string, expiresAt time.Time) {
+	if jti == "" || !expiresAt.After(time.Now()) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := d.client.Set(ctx, redisTokenPrefix+jti, "1", time.Until(expiresAt)).Err(); err != nil {
+		log.Printf("Failed to revoke token %s: %v", jti, err)
+	}
+}
+
+func (d *RedisDenylist) RevokeSubject(subject string, revokedAt time.Time) {
+	if subject == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	err := d.client.Set(ctx, redisSubjectPrefix+subject, revokedAt.Unix(), maxTokenLifetime).Err()
+	if err != nil {
+		log.Printf("Failed to revoke tokens of %s: %v", subject, err)
+	}
+}
+
+func (d *RedisDenylist) IsRevoked(claims *Claims) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	values, err := d.client.MGet(ctx, redisTokenPrefix+claims.ID, redisSubjectPrefix+claims.Subject).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("Failed to check token revocation: %v", err)
+		return false
+	}
+
+	if claims.ID != "" && values[0] != nil {
+		return true
+	}
+
+	if s, ok := values[1].(string); ok {
+		revokedAt, err := strconv.ParseInt(s, 10, 64)
+		if err == nil && claims.IssuedAt < revokedAt {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *RedisDenylist) Close() error {
+	return d.client.Close()
+}
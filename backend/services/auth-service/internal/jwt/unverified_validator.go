@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// UnverifiedValidator parses tokens WITHOUT checking their signature. It only
+// exists for mock environments whose tokens can't be verified (e.g. an
+// emulator with unpublished keys); prefer Validator with a TestSigner.
+type UnverifiedValidator struct {
+	userPoolID string
+	region     string
+	clientID   string
+	denylist   Denylist
+}
+
+// NewUnverifiedValidator refuses to run when APP_ENV is production and logs
+// a warning, so the mode can't be enabled by accident
+func NewUnverifiedValidator(userPoolID, region, clientID string, denylist Denylist) (*UnverifiedValidator, error) {
+	if os.Getenv("APP_ENV") == "production" {
+		return nil, fmt.Errorf("unverified token validation is not allowed in production")
+	}
+
+	log.Println("**************************************************************")
+	log.Println("WARNING: JWT signatures are NOT verified. Any well-formed token")
+	log.Println("is accepted. Never use JWT_VALIDATION_MODE=unverified outside")
+	log.Println("local mock environments.")
+	log.Println("**************************************************************")
+
+	return &UnverifiedValidator{
+		userPoolID: userPoolID,
+		region:     region,
+		clientID:   clientID,
+		denylist:   denylist,
+	}, nil
+}
+
+// ValidateToken applies the same policy checks as Validator, except that the
+// signature, issuer and client ID are not checked
+func (v *UnverifiedValidator) ValidateToken(tokenString string, reqs ...Requirement) (*Claims, error) {
+	// Remove "Bearer " prefix if present
+	if strings.HasPrefix(tokenString, "Bearer ") {
+		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	}
+
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	claims, err := claimsFromMap(token.Claims.(jwt.MapClaims))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := (Policy{}).with(reqs).check(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if v.denylist != nil && v.denylist.IsRevoked(claims) {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+func (v *UnverifiedValidator) RefreshJWKS() error {
+	// No keys to refresh
+	return nil
+}
@@ -2,9 +2,8 @@ package jwt
 
 import (
 	"context"
-	"crypto/rsa"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -12,128 +11,245 @@ import (
 	"github.com/lestrrat-go/jwx/v2/jwk"
 )
 
+const (
+	defaultRefreshInterval    = time.Hour
+	defaultMinRefetchInterval = time.Minute
+	defaultStaleKeyGrace      = 15 * time.Minute
+)
+
+// Validator verifies Cognito tokens from one or more user pools. The pool
+// passed to NewValidator is the primary one; WithPool trusts more, each with
+// its own JWKS cache and app clients.
 type Validator struct {
-	userPoolID string
-	region     string
-	clientID   string
-	jwkSet     jwk.Set
+	primary Pool
+	extra   []Pool
+
+	refreshInterval    time.Duration
+	minRefetchInterval time.Duration
+	staleKeyGrace      time.Duration
+	denylist           Denylist
+	policy             Policy
+
+	// pools maps each trusted issuer to its pool and keys
+	pools map[string]*poolKeys
+}
+
+// Option configures a Validator
+type Option func(*Validator)
+
+// KeySource loads a JWKS from somewhere other than the user pool's endpoint
+type KeySource func(ctx context.Context) (jwk.Set, error)
+
+// WithRefreshInterval sets how often the JWKS is refreshed in the background
+func WithRefreshInterval(d time.Duration) Option {
+	return func(v *Validator) {
+		v.refreshInterval = d
+	}
+}
+
+// WithMinRefetchInterval limits how often an unknown kid may trigger a refetch
+func WithMinRefetchInterval(d time.Duration) Option {
+	return func(v *Validator) {
+		v.minRefetchInterval = d
+	}
+}
+
+// WithDenylist rejects tokens revoked through the denylist
+func WithDenylist(d Denylist) Option {
+	return func(v *Validator) {
+		v.denylist = d
+	}
+}
+
+// WithPolicy adjusts the default policy applied to every token. Unless it
+// sets ClientIDs, tokens must come from one of their pool's app clients.
+func WithPolicy(reqs ...Requirement) Option {
+	return func(v *Validator) {
+		v.policy = v.policy.with(reqs)
+	}
+}
+
+// WithKeySource loads the primary pool's keys from source instead of
+// fetching its JWKS
+func WithKeySource(source KeySource) Option {
+	return func(v *Validator) {
+		v.primary.KeySource = source
+	}
+}
+
+// WithClientIDs accepts tokens from more of the primary pool's app clients
+func WithClientIDs(ids ...string) Option {
+	return func(v *Validator) {
+		v.primary.ClientIDs = append(v.primary.ClientIDs, ids...)
+	}
+}
+
+// WithPoolName sets the realm name reported for the primary pool's tokens
+func WithPoolName(name string) Option {
+	return func(v *Validator) {
+		v.primary.Name = name
+	}
+}
+
+// WithPool trusts tokens from another user pool
+func WithPool(pool Pool) Option {
+	return func(v *Validator) {
+		v.extra = append(v.extra, pool)
+	}
+}
+
+// WithStaleKeyGrace sets how long keys removed from the JWKS stay valid
+func WithStaleKeyGrace(d time.Duration) Option {
+	return func(v *Validator) {
+		v.staleKeyGrace = d
+	}
 }
 
 type Claims struct {
 	jwt.RegisteredClaims
-	TokenUse  string `json:"token_use"`
-	Scope     string `json:"scope"`
-	AuthTime  int64  `json:"auth_time"`
-	ClientID  string `json:"client_id"`
-	Username  string `json:"username"`
-	ExpTime   int64  `json:"exp"`
-	IssuedAt  int64  `json:"iat"`
-	Email     string `json:"email"`
-	EmailVerified bool `json:"email_verified"`
-}
-
-func NewValidator(userPoolID, region, clientID string) (*Validator, error) {
+	TokenUse      string   `json:"token_use"`
+	Scope         string   `json:"scope"`
+	AuthTime      int64    `json:"auth_time"`
+	ClientID      string   `json:"client_id"`
+	Username      string   `json:"username"`
+	ExpTime       int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Groups        []string `json:"cognito:groups"`
+
+	// Pool is the name of the user pool that issued the token
+	Pool string `json:"-"`
+
+	raw map[string]interface{}
+}
+
+// Claim returns a claim by name, including ones Claims has no field for
+func (c *Claims) Claim(name string) (interface{}, bool) {
+	value, found := c.raw[name]
+	return value, found
+}
+
+func NewValidator(userPoolID, region, clientID string, opts ...Option) (*Validator, error) {
 	v := &Validator{
-		userPoolID: userPoolID,
-		region:     region,
-		clientID:   clientID,
+		primary: Pool{
+			UserPoolID: userPoolID,
+			Region:     region,
+			ClientIDs:  []string{clientID},
+		},
+		refreshInterval:    defaultRefreshInterval,
+		minRefetchInterval: defaultMinRefetchInterval,
+		staleKeyGrace:      defaultStaleKeyGrace,
+		pools:              make(map[string]*poolKeys),
 	}
 
-	if err := v.loadJWKS(); err != nil {
-		return nil, fmt.Errorf("failed to load JWKS: %v", err)
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.refreshInterval <= 0 {
+		v.refreshInterval = defaultRefreshInterval
 	}
 
-	return v, nil
-}
+	for _, pool := range append([]Pool{v.primary}, v.extra...) {
+		if _, dup := v.pools[pool.issuer()]; dup {
+			v.Close()
+			return nil, fmt.Errorf("user pool %s is configured twice", pool.UserPoolID)
+		}
 
-func (v *Validator) loadJWKS() error {
-	var jwksURL string
-	
-	// Check for Cognito endpoint environment variable (for mocks)
-	cognitoEndpoint := os.Getenv("COGNITO_ENDPOINT")
-	if cognitoEndpoint != "" {
-		jwksURL = fmt.Sprintf("%s/%s/.well-known/jwks.json", cognitoEndpoint, v.userPoolID)
-	} else {
-		jwksURL = fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json", v.region, v.userPoolID)
+		keys, err := newPoolKeys(pool, v.refreshInterval, v.minRefetchInterval, v.staleKeyGrace)
+		if err != nil {
+			v.Close()
+			return nil, fmt.Errorf("failed to load JWKS: %v", err)
+		}
+		v.pools[pool.issuer()] = keys
 	}
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
 
-	jwkSet, err := jwk.Fetch(ctx, jwksURL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch JWKS: %v", err)
-	}
+	return v, nil
+}
 
-	v.jwkSet = jwkSet
-	return nil
+// Close stops the background JWKS refreshers
+func (v *Validator) Close() {
+	for _, pool := range v.pools {
+		pool.keys.Close()
+	}
 }
 
-func (v *Validator) ValidateToken(tokenString string) (*Claims, error) {
+// ValidateToken verifies the token's signature and issuer, then checks it
+// against the validator's policy adjusted by reqs. Errors wrap the package's
+// Err* values.
+func (v *Validator) ValidateToken(tokenString string, reqs ...Requirement) (*Claims, error) {
 	// Remove "Bearer " prefix if present
 	if strings.HasPrefix(tokenString, "Bearer ") {
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	// The issuer picks the pool whose keys verify the token
+	var trusted *poolKeys
+	var issuerErr error
+
+	// Time-based claims are checked by the policy so leeway applies uniformly
+	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		issuer, _ := token.Claims.GetIssuer()
+		var found bool
+		if trusted, found = v.pools[issuer]; !found {
+			issuerErr = fmt.Errorf("%w: %s", ErrInvalidIssuer, issuer)
+			return nil, issuerErr
 		}
 
 		// Get key ID from token header
 		kid, ok := token.Header["kid"].(string)
 		if !ok {
-			return nil, fmt.Errorf("missing kid in token header")
+			return nil, fmt.Errorf("%w: missing kid in token header", ErrUnknownKey)
 		}
 
-		// Find the key in JWKS
-		key, found := v.jwkSet.LookupKeyID(kid)
-		if !found {
-			return nil, fmt.Errorf("key not found in JWKS")
+		// An unknown kid is refetched once, rate limited (key rotation)
+		key, err := trusted.keys.PublicKey(context.Background(), kid)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownKey, err)
 		}
 
-		var rsaKey rsa.PublicKey
-		if err := key.Raw(&rsaKey); err != nil {
-			return nil, fmt.Errorf("failed to parse RSA key: %v", err)
-		}
-
-		return &rsaKey, nil
-	})
-
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithoutClaimsValidation(),
+	)
+	if issuerErr != nil {
+		return nil, issuerErr
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %v", err)
+		return nil, parseError(err)
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+	claims, err := claimsFromMap(token.Claims.(jwt.MapClaims))
+	if err != nil {
+		return nil, err
 	}
 
-	// Validate token use (should be "access" for access tokens, "id" for ID tokens)
-	if claims.TokenUse != "access" && claims.TokenUse != "id" {
-		return nil, fmt.Errorf("invalid token use: %s", claims.TokenUse)
-	}
+	claims.Pool = trusted.pool.Name
 
-	// Validate issuer
-	expectedIssuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", v.region, v.userPoolID)
-	if claims.Issuer != expectedIssuer {
-		return nil, fmt.Errorf("invalid issuer: %s", claims.Issuer)
+	policy := v.policy.with(reqs)
+	if len(policy.ClientIDs) == 0 {
+		policy.ClientIDs = trusted.pool.ClientIDs
 	}
-
-	// Validate client ID for ID tokens
-	if claims.TokenUse == "id" && claims.Audience[0] != v.clientID {
-		return nil, fmt.Errorf("invalid audience: %s", claims.Audience)
+	if err := policy.check(claims, time.Now()); err != nil {
+		return nil, err
 	}
 
-	// Validate expiration
-	if time.Now().Unix() > claims.ExpTime {
-		return nil, fmt.Errorf("token expired")
+	if v.denylist != nil && v.denylist.IsRevoked(claims) {
+		return nil, ErrTokenRevoked
 	}
 
 	return claims, nil
 }
 
+// RefreshJWKS reloads every pool's JWKS
 func (v *Validator) RefreshJWKS() error {
-	return v.loadJWKS()
-}
\ No newline at end of file
+	var errs []error
+	for _, trusted := range v.pools {
+		if err := trusted.keys.Refresh(context.Background()); err != nil {
+			errs = append(errs, fmt.Errorf("pool %s: %w", trusted.pool.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,379 @@
+package jwt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testPoolID   = "ap-northeast-1_test"
+	testRegion   = "ap-northeast-1"
+	testClientID = "test-client"
+)
+
+func newTestValidator(t *testing.T, opts ...Option) (*Validator, *TestSigner) {
+	t.Helper()
+
+	signer, err := NewTestSigner(testPoolID, testRegion, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewValidator(testPoolID, testRegion, testClientID, append([]Option{WithKeySource(signer.JWKS)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewValidator: %v", err)
+	}
+	t.Cleanup(v.Close)
+
+	return v, signer
+}
+
+func mint(t *testing.T, signer *TestSigner, claims Claims) string {
+	t.Helper()
+
+	token, err := signer.Mint(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidateToken(t *testing.T) {
+	v, signer := newTestValidator(t)
+	otherSigner, err := NewTestSigner(testPoolID, testRegion, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another key claiming to be the published one
+	otherSigner.kid = signer.kid
+	forged := mint(t, otherSigner, Claims{})
+
+	now := time.Now()
+	valid := mint(t, signer, Claims{Username: "alice"})
+
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": signer.issuer, "token_use": "access", "client_id": testClientID, "exp": now.Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token signed by the right key but naming a kid that isn't published
+	unknownKid := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": signer.issuer, "token_use": "access", "client_id": testClientID, "exp": now.Add(time.Hour).Unix(),
+	})
+	unknownKid.Header["kid"] = "unpublished"
+	unknownKidToken, err := unknownKid.SignedString(signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		reqs    []Requirement
+		wantErr error
+	}{
+		{"valid access token", valid, nil, nil},
+		{"bearer prefix", "Bearer " + valid, nil, nil},
+		{"valid id token", mint(t, signer, Claims{TokenUse: "id"}), nil, nil},
+		{"malformed", "not.a.token", nil, ErrMalformedToken},
+		{"empty", "", nil, ErrMalformedToken},
+		{"signed by another key", forged, nil, ErrInvalidSignature},
+		{"HS256", hs256, nil, ErrInvalidSignature},
+		{"unknown kid", unknownKidToken, nil, ErrUnknownKey},
+		{"untrusted issuer", mint(t, signer, Claims{RegisteredClaims: jwt.RegisteredClaims{Issuer: "https://evil.example.com"}}), nil, ErrInvalidIssuer},
+		{"expired", mint(t, signer, Claims{ExpTime: now.Add(-time.Minute).Unix()}), nil, ErrTokenExpired},
+		{"expired within leeway", mint(t, signer, Claims{ExpTime: now.Add(-time.Minute).Unix()}), []Requirement{Leeway(2 * time.Minute)}, nil},
+		{"not before in the future", mint(t, signer, Claims{RegisteredClaims: jwt.RegisteredClaims{NotBefore: jwt.NewNumericDate(now.Add(time.Hour))}}), nil, ErrTokenNotYetValid},
+		{"issued in the future", mint(t, signer, Claims{IssuedAt: now.Add(time.Hour).Unix()}), nil, ErrTokenNotYetValid},
+		{"issued just ahead within leeway", mint(t, signer, Claims{IssuedAt: now.Add(10 * time.Second).Unix()}), []Requirement{Leeway(time.Minute)}, nil},
+		{"unknown token_use", mint(t, signer, Claims{TokenUse: "refresh"}), nil, ErrInvalidTokenUse},
+		{"id token where access is required", mint(t, signer, Claims{TokenUse: "id"}), []Requirement{TokenUses("access")}, ErrInvalidTokenUse},
+		{"access token for another client", mint(t, signer, Claims{ClientID: "other-client"}), nil, ErrInvalidAudience},
+		{"id token for another client", mint(t, signer, Claims{TokenUse: "id", RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"other-client"}}}), nil, ErrInvalidAudience},
+		{"client allowed per call", mint(t, signer, Claims{ClientID: "other-client"}), []Requirement{ClientIDs("other-client")}, nil},
+		{"granted scope", mint(t, signer, Claims{Scope: "openid profile"}), []Requirement{Scopes("profile")}, nil},
+		{"missing scope", mint(t, signer, Claims{Scope: "openid"}), []Requirement{Scopes("openid", "profile")}, ErrMissingScope},
+		{"recent sign-in", mint(t, signer, Claims{AuthTime: now.Add(-time.Minute).Unix()}), []Requirement{MaxAuthAge(15 * time.Minute)}, nil},
+		{"stale sign-in", mint(t, signer, Claims{AuthTime: now.Add(-time.Hour).Unix()}), []Requirement{MaxAuthAge(15 * time.Minute)}, ErrAuthTooOld},
+		{"required claim present", valid, []Requirement{RequiredClaims("username")}, nil},
+		{"required claim missing", valid, []Requirement{RequiredClaims("custom:tenant")}, ErrMissingClaim},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.ValidateToken(tt.token, tt.reqs...)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("ValidateToken() error = %v", err)
+				}
+				if claims.Pool != testPoolID {
+					t.Errorf("Pool = %q, want %q", claims.Pool, testPoolID)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTokenMissingExpiry(t *testing.T) {
+	v, signer := newTestValidator(t)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": signer.issuer, "token_use": "access", "client_id": testClientID,
+	})
+	token.Header["kid"] = signer.kid
+	signed, err := token.SignedString(signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.ValidateToken(signed); !errors.Is(err, ErrMissingClaim) || !strings.Contains(err.Error(), "exp") {
+		t.Fatalf("ValidateToken() error = %v, want a missing exp claim", err)
+	}
+}
+
+func TestValidatorDefaultPolicy(t *testing.T) {
+	v, signer := newTestValidator(t,
+		WithPolicy(TokenUses("access"), Scopes("openid")),
+		WithClientIDs("second-client"),
+	)
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		reqs    []Requirement
+		wantErr error
+	}{
+		{"meets the default policy", Claims{Scope: "openid"}, nil, nil},
+		{"second app client", Claims{ClientID: "second-client", Scope: "openid"}, nil, nil},
+		{"id token rejected by default", Claims{TokenUse: "id", Scope: "openid"}, nil, ErrInvalidTokenUse},
+		{"id token allowed per call", Claims{TokenUse: "id", Scope: "openid"}, []Requirement{TokenUses("id")}, nil},
+		{"default scope missing", Claims{Scope: "profile"}, nil, ErrMissingScope},
+		// Per-call scopes add to the default ones
+		{"per-call scope on top", Claims{Scope: "profile"}, []Requirement{Scopes("profile")}, ErrMissingScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateToken(mint(t, signer, tt.claims), tt.reqs...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClaimsExposeRawClaims(t *testing.T) {
+	v, signer := newTestValidator(t)
+
+	token := mint(t, signer, Claims{Username: "alice", Groups: []string{"seller"}})
+	claims, err := v.ValidateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Username != "alice" || len(claims.Groups) != 1 || claims.Groups[0] != "seller" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if value, found := claims.Claim("username"); !found || value != "alice" {
+		t.Errorf("Claim(username) = %v, %v", value, found)
+	}
+	if _, found := claims.Claim("custom:missing"); found {
+		t.Error("Claim(custom:missing) found a claim the token doesn't have")
+	}
+}
+
+func TestClaimsFromMapDates(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		want    [3]int64
+		wantErr error
+	}{
+		{"whole seconds", jwt.MapClaims{"exp": 1700000000.0, "iat": 1699996400.0, "auth_time": 1699996300.0}, [3]int64{1700000000, 1699996400, 1699996300}, nil},
+		{"fractional seconds", jwt.MapClaims{"exp": 1700000000.75, "iat": 1699996400.5, "auth_time": 1699996300.25}, [3]int64{1700000000, 1699996400, 1699996300}, nil},
+		{"missing", jwt.MapClaims{"sub": "alice"}, [3]int64{}, nil},
+		{"not a number", jwt.MapClaims{"exp": "tomorrow"}, [3]int64{}, ErrMalformedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := claimsFromMap(tt.claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("claimsFromMap() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := [3]int64{claims.ExpTime, claims.IssuedAt, claims.AuthTime}; got != tt.want {
+				t.Errorf("exp, iat, auth_time = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTestSigner(t *testing.T) {
+	signer, err := NewTestSigner(testPoolID, testRegion, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "test-key.pem")
+	if err := os.WriteFile(path, signer.EncodePrivateKey(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A minter sharing the key file signs tokens the service accepts
+	loaded, err := LoadTestSigner(path, testPoolID, testRegion, testClientID)
+	if err != nil {
+		t.Fatalf("LoadTestSigner: %v", err)
+	}
+	if loaded.kid != signer.kid {
+		t.Errorf("kid = %q, want %q derived from the same key", loaded.kid, signer.kid)
+	}
+
+	v, err := NewValidator(testPoolID, testRegion, testClientID, WithKeySource(signer.JWKS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(v.Close)
+	if _, err := v.ValidateToken(mint(t, loaded, Claims{})); err != nil {
+		t.Errorf("token minted by the loaded signer: %v", err)
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "not-pem")
+	os.WriteFile(notPEM, []byte("not a key"), 0o600)
+	if _, err := LoadTestSigner(notPEM, testPoolID, testRegion, testClientID); err == nil {
+		t.Error("LoadTestSigner() accepted a file that isn't PEM")
+	}
+}
+
+func TestUnverifiedValidator(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	if _, err := NewUnverifiedValidator(testPoolID, testRegion, testClientID, nil); err == nil {
+		t.Fatal("NewUnverifiedValidator() succeeded in production")
+	}
+
+	t.Setenv("APP_ENV", "development")
+	denylist := NewMemoryDenylist()
+	v, err := NewUnverifiedValidator(testPoolID, testRegion, testClientID, denylist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Any key is accepted, which is exactly why this mode is opt-in
+	signer, err := NewTestSigner(testPoolID, testRegion, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := mint(t, signer, Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "jti-revoked"}})
+	denylist.RevokeToken("jti-revoked", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		token   string
+		reqs    []Requirement
+		wantErr error
+	}{
+		{"unverified signature", mint(t, signer, Claims{}), nil, nil},
+		{"malformed", "not-a-jwt", nil, ErrMalformedToken},
+		{"expired", mint(t, signer, Claims{ExpTime: time.Now().Add(-time.Minute).Unix()}), nil, ErrTokenExpired},
+		{"revoked", revoked, nil, ErrTokenRevoked},
+		// The policy still applies
+		{"wrong token use", mint(t, signer, Claims{TokenUse: "id"}), []Requirement{TokenUses("access")}, ErrInvalidTokenUse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.ValidateToken(tt.token, tt.reqs...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatorPools(t *testing.T) {
+	buyer, err := NewTestSigner(testPoolID, testRegion, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seller, err := NewTestSigner("ap-northeast-1_seller", testRegion, "seller-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrusted, err := NewTestSigner("ap-northeast-1_other", testRegion, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewValidator(testPoolID, testRegion, testClientID,
+		WithKeySource(buyer.JWKS),
+		WithPoolName("buyer"),
+		WithPool(Pool{
+			Name:       "seller",
+			UserPoolID: "ap-northeast-1_seller",
+			Region:     testRegion,
+			ClientIDs:  []string{"seller-client"},
+			KeySource:  seller.JWKS,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewValidator: %v", err)
+	}
+	t.Cleanup(v.Close)
+
+	// The seller signer, but claiming the buyer pool's issuer
+	sellerAsBuyer := mint(t, seller, Claims{RegisteredClaims: jwt.RegisteredClaims{Issuer: buyer.issuer}, ClientID: testClientID})
+
+	tests := []struct {
+		name     string
+		token    string
+		wantPool string
+		wantErr  error
+	}{
+		{"primary pool", mint(t, buyer, Claims{}), "buyer", nil},
+		{"trusted pool", mint(t, seller, Claims{}), "seller", nil},
+		{"pool's own client only", mint(t, seller, Claims{ClientID: testClientID}), "", ErrInvalidAudience},
+		{"other pool's client", mint(t, buyer, Claims{ClientID: "seller-client"}), "", ErrInvalidAudience},
+		{"other pool's key", sellerAsBuyer, "", ErrUnknownKey},
+		{"untrusted pool", mint(t, untrusted, Claims{}), "", ErrInvalidIssuer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.ValidateToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.Pool != tt.wantPool {
+				t.Errorf("Pool = %q, want %q", claims.Pool, tt.wantPool)
+			}
+		})
+	}
+}
+
+func TestValidatorRejectsDuplicatePools(t *testing.T) {
+	signer, err := NewTestSigner(testPoolID, testRegion, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewValidator(testPoolID, testRegion, testClientID,
+		WithKeySource(signer.JWKS),
+		WithPool(Pool{UserPoolID: testPoolID, Region: testRegion, KeySource: signer.JWKS}),
+	)
+	if err == nil || !strings.Contains(err.Error(), "configured twice") {
+		t.Fatalf("NewValidator() error = %v, want a duplicate pool error", err)
+	}
+}
@@ -0,0 +1,191 @@
+package local
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+)
+
+// ListUsers returns a page of users matching filter in email order. The
+// NextToken is the last email of the page.
+func (p *Provider) ListUsers(ctx context.Context, filter cognito.UserFilter) (*cognito.UserPage, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	search := normalizeEmail(filter.Search)
+
+	var emails []string
+	for email, u := range p.users {
+		if !strings.HasPrefix(email, search) || email <= filter.NextToken {
+			continue
+		}
+		if filter.Group != "" && !contains(u.groups, filter.Group) {
+			continue
+		}
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	limit := filter.Limit
+	if limit < 1 || limit > cognito.MaxListUsersLimit {
+		limit = cognito.MaxListUsersLimit
+	}
+
+	page := &cognito.UserPage{Users: []cognito.UserRecord{}}
+	if len(emails) > limit {
+		emails = emails[:limit]
+		page.NextToken = emails[limit-1]
+	}
+	for _, email := range emails {
+		record := p.users[email].record()
+		record.Groups = nil
+		page.Users = append(page.Users, record)
+	}
+
+	return page, nil
+}
+
+// AdminGetUser returns a user and the groups they belong to
+func (p *Provider) AdminGetUser(ctx context.Context, username string) (*cognito.UserRecord, error) {
+	u, found := p.userByID(username)
+	if !found {
+		return nil, cognito.ErrUserNotFound
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	record := u.record()
+	return &record, nil
+}
+
+// AdminSetUserEnabled enables or disables a user. Disabled users can't sign
+// in, refresh or use their access tokens.
+func (p *Provider) AdminSetUserEnabled(ctx context.Context, username string, enabled bool) error {
+	return p.updateUser(username, func(u *user) {
+		u.disabled = !enabled
+	})
+}
+
+// AdminAddUserToGroup adds a user to a group, which shows up in
+// cognito:groups from their next token
+func (p *Provider) AdminAddUserToGroup(ctx context.Context, username, group string) error {
+	return p.updateUser(username, func(u *user) {
+		if !contains(u.groups, group) {
+			u.groups = append(u.groups, group)
+		}
+	})
+}
+
+// AdminRemoveUserFromGroup removes a user from a group
+func (p *Provider) AdminRemoveUserFromGroup(ctx context.Context, username, group string) error {
+	return p.updateUser(username, func(u *user) {
+		groups := u.groups[:0]
+		for _, g := range u.groups {
+			if g != group {
+				groups = append(groups, g)
+			}
+		}
+		u.groups = groups
+	})
+}
+
+// AdminResetUserPassword invalidates a user's password and logs a reset
+// code. Sign-in fails with ErrPasswordResetRequired until it is used.
+func (p *Provider) AdminResetUserPassword(ctx context.Context, username string) error {
+	return p.updateUser(username, func(u *user) {
+		u.passwordHash = nil
+		u.resetRequired = true
+		u.resetCode, u.resetExpires = newCode(), time.Now().Add(resetCodeTTL)
+		log.Printf("local identity provider: password reset code for %s is %s", u.email, u.resetCode)
+	})
+}
+
+// AdminUserGlobalSignOut revokes a user's refresh tokens and every token
+// issued so far
+func (p *Provider) AdminUserGlobalSignOut(ctx context.Context, username string) error {
+	return p.updateUser(username, func(u *user) {
+		u.signedOutAt = time.Now()
+		for token, session := range p.refreshes {
+			if session.userID == u.id {
+				delete(p.refreshes, token)
+			}
+		}
+	})
+}
+
+// AdminUpdateUserAttributes sets attributes on a user without a
+// verification code. Users are keyed by email, so it can't change email.
+func (p *Provider) AdminUpdateUserAttributes(ctx context.Context, username string, attributes map[string]string) error {
+	if _, found := attributes["email"]; found {
+		return cognito.ErrInvalidParameter
+	}
+
+	return p.updateUser(username, func(u *user) {
+		for name, value := range attributes {
+			if name == "name" {
+				u.name = value
+				continue
+			}
+			u.attributes[name] = value
+		}
+	})
+}
+
+// AdminDeleteUserAttributes removes attributes from a user
+func (p *Provider) AdminDeleteUserAttributes(ctx context.Context, username string, names []string) error {
+	return p.updateUser(username, func(u *user) {
+		for _, name := range names {
+			delete(u.attributes, name)
+		}
+	})
+}
+
+// updateUser applies change to the user with the given ID under the lock
+func (p *Provider) updateUser(username string, change func(*user)) error {
+	u, found := p.userByID(username)
+	if !found {
+		return cognito.ErrUserNotFound
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	change(u)
+	u.updatedAt = time.Now()
+	return nil
+}
+
+// record describes u for administrators; p.mu must be held
+func (u *user) record() cognito.UserRecord {
+	status := "CONFIRMED"
+	switch {
+	case u.resetRequired:
+		status = "RESET_REQUIRED"
+	case !u.confirmed:
+		status = "UNCONFIRMED"
+	}
+
+	return cognito.UserRecord{
+		User:      u.profile(),
+		Subject:   u.id,
+		Enabled:   !u.disabled,
+		Status:    status,
+		CreatedAt: u.createdAt,
+		UpdatedAt: u.updatedAt,
+		Groups:    append([]string(nil), u.groups...),
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,670 @@
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/proof"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultTokenTTL   = time.Hour
+	refreshTokenTTL   = 30 * 24 * time.Hour
+	confirmCodeTTL    = 24 * time.Hour
+	resetCodeTTL      = time.Hour
+	minPasswordLength = 8
+)
+
+// dummyPasswordHash is compared against on sign-in for unknown emails, so
+// they take as long to turn away as a wrong password and response times
+// don't reveal which emails have accounts. It has bcrypt.DefaultCost, like
+// real password hashes.
+var dummyPasswordHash = []byte("$2a$10$V3tpKlP447liPU1dnhasdezZPNdAqJNs8GmXGWp7Zxle0pPPxcnsG")
+
+// Config configures a local Provider. UserPoolID and Region only shape the
+// issuer so tokens look like the pool's own.
+type Config struct {
+	UserPoolID string
+	Region     string
+	ClientID   string
+	TokenTTL   time.Duration
+	// AutoConfirm skips the emailed confirmation code on sign-up
+	AutoConfirm bool
+	// AdminEmails are put in the admin group when they sign up, since
+	// there is no console to do it from
+	AdminEmails []string
+	// ProofSecret verifies custom challenge answers, as the pool's verify
+	// auth challenge trigger would
+	ProofSecret []byte
+}
+
+// Provider is a self-contained, in-memory identity provider for running the
+// service without AWS. It issues RS256 tokens with Cognito's claim layout,
+// so anything that validates Cognito tokens accepts them given its JWKS.
+// Codes that Cognito would email are written to the log instead.
+type Provider struct {
+	cfg    Config
+	issuer string
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu        sync.RWMutex
+	users     map[string]*user // by lower-cased email
+	refreshes map[string]refreshSession
+}
+
+type user struct {
+	id           string
+	email        string
+	name         string
+	passwordHash []byte
+	confirmed    bool
+	// emailVerified turns false while a changed email awaits its code
+	emailVerified bool
+	attributes    map[string]string
+	// attributeCodes holds codes for changed email and phone_number values
+	attributeCodes map[string]pendingCode
+
+	code          string
+	codeExpiresAt time.Time
+	resetCode     string
+	resetExpires  time.Time
+
+	// signedOutAt invalidates every token issued before it (GlobalSignOut)
+	signedOutAt time.Time
+
+	disabled bool
+	// resetRequired blocks sign-in until the password is reset
+	resetRequired bool
+	groups        []string
+	createdAt     time.Time
+	updatedAt     time.Time
+}
+
+type pendingCode struct {
+	code      string
+	expiresAt time.Time
+}
+
+type refreshSession struct {
+	userID    string
+	authTime  time.Time
+	expiresAt time.Time
+}
+
+func NewProvider(cfg Config) (*Provider, error) {
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = defaultTokenTTL
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %v", err)
+	}
+
+	return &Provider{
+		cfg:       cfg,
+		issuer:    fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", cfg.Region, cfg.UserPoolID),
+		key:       key,
+		kid:       uuid.NewString(),
+		users:     make(map[string]*user),
+		refreshes: make(map[string]refreshSession),
+	}, nil
+}
+
+func (p *Provider) SignUp(ctx context.Context, req cognito.SignUpRequest) error {
+	if len(req.Password) < minPasswordLength {
+		return cognito.ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	email := normalizeEmail(req.Email)
+	if _, exists := p.users[email]; exists {
+		return cognito.ErrUsernameExists
+	}
+
+	u := &user{
+		id:           uuid.NewString(),
+		email:        req.Email,
+		name:         req.Name,
+		passwordHash: hash,
+		confirmed:    p.cfg.AutoConfirm,
+		attributes:   make(map[string]string),
+
+		emailVerified:  p.cfg.AutoConfirm,
+		attributeCodes: make(map[string]pendingCode),
+		groups:         p.initialGroups(email),
+		createdAt:      time.Now(),
+		updatedAt:      time.Now(),
+	}
+	if !u.confirmed {
+		u.code, u.codeExpiresAt = newCode(), time.Now().Add(confirmCodeTTL)
+		log.Printf("local identity provider: confirmation code for %s is %s", req.Email, u.code)
+	}
+
+	p.users[email] = u
+	return nil
+}
+
+func (p *Provider) ConfirmSignUp(ctx context.Context, email, confirmationCode string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u, found := p.users[normalizeEmail(email)]
+	if !found {
+		return cognito.ErrUserNotFound
+	}
+	if u.confirmed {
+		return nil
+	}
+	if err := checkCode(u.code, u.codeExpiresAt, confirmationCode); err != nil {
+		return err
+	}
+
+	u.confirmed = true
+	u.emailVerified = true
+	u.code = ""
+	return nil
+}
+
+func (p *Provider) ResendConfirmationCode(ctx context.Context, email string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u, found := p.users[normalizeEmail(email)]
+	if !found {
+		return cognito.ErrUserNotFound
+	}
+	if u.confirmed {
+		return cognito.ErrInvalidParameter
+	}
+
+	u.code, u.codeExpiresAt = newCode(), time.Now().Add(confirmCodeTTL)
+	log.Printf("local identity provider: confirmation code for %s is %s", u.email, u.code)
+	return nil
+}
+
+// SignIn checks the password and issues tokens. The local provider never
+// raises challenges.
+func (p *Provider) SignIn(ctx context.Context, req cognito.SignInRequest) (*cognito.AuthResponse, *cognito.Challenge, error) {
+	p.mu.RLock()
+	u, found := p.users[normalizeEmail(req.Email)]
+	var passwordHash []byte
+	var confirmed, disabled, resetRequired bool
+	if found {
+		passwordHash, confirmed = u.passwordHash, u.confirmed
+		disabled, resetRequired = u.disabled, u.resetRequired
+	}
+	p.mu.RUnlock()
+
+	if !found {
+		passwordHash = dummyPasswordHash
+	}
+	passwordOK := bcrypt.CompareHashAndPassword(passwordHash, []byte(req.Password)) == nil
+
+	if !found {
+		return nil, nil, cognito.ErrUserNotFound
+	}
+	if disabled {
+		return nil, nil, cognito.ErrNotAuthorized
+	}
+	if resetRequired {
+		return nil, nil, cognito.ErrPasswordResetRequired
+	}
+	if !passwordOK {
+		return nil, nil, cognito.ErrNotAuthorized
+	}
+	if !confirmed {
+		return nil, nil, cognito.ErrUserNotConfirmed
+	}
+
+	authResponse, err := p.startSession(u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return authResponse, nil, nil
+}
+
+// SignInWithCustomChallenge issues tokens for username once answer verifies
+// as a recent proof for them, standing in for the pool's verify auth
+// challenge trigger.
+func (p *Provider) SignInWithCustomChallenge(ctx context.Context, username, answer string) (*cognito.AuthResponse, error) {
+	u, found := p.userByID(username)
+	if !found {
+		return nil, cognito.ErrUserNotFound
+	}
+	p.mu.RLock()
+	confirmed := u.confirmed && !u.disabled
+	p.mu.RUnlock()
+
+	// Without a secret anyone could sign a proof
+	if !confirmed || len(p.cfg.ProofSecret) == 0 {
+		return nil, cognito.ErrNotAuthorized
+	}
+	if _, err := proof.Verify(p.cfg.ProofSecret, u.id, answer, proof.MaxAge); err != nil {
+		return nil, cognito.ErrNotAuthorized
+	}
+
+	return p.startSession(u)
+}
+
+func (p *Provider) RefreshToken(ctx context.Context, refreshToken string) (*cognito.AuthResponse, error) {
+	p.mu.RLock()
+	session, found := p.refreshes[refreshToken]
+	p.mu.RUnlock()
+
+	if !found || time.Now().After(session.expiresAt) {
+		return nil, cognito.ErrNotAuthorized
+	}
+
+	u, found := p.userByID(session.userID)
+	if !found {
+		return nil, cognito.ErrNotAuthorized
+	}
+
+	p.mu.RLock()
+	disabled := u.disabled
+	groups := append([]string(nil), u.groups...)
+	p.mu.RUnlock()
+	if disabled {
+		return nil, cognito.ErrNotAuthorized
+	}
+
+	profile := p.profile(u)
+	accessToken, idToken, err := p.issueTokens(profile, groups, session.authTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cognito.AuthResponse{
+		AccessToken:  accessToken,
+		IdToken:      idToken,
+		RefreshToken: refreshToken,
+		User:         profile,
+	}, nil
+}
+
+func (p *Provider) GetUser(ctx context.Context, accessToken string) (*cognito.User, error) {
+	u, err := p.authenticate(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := p.profile(u)
+	return &profile, nil
+}
+
+// RevokeToken revokes a refresh token
+func (p *Provider) RevokeToken(ctx context.Context, refreshToken string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.refreshes, refreshToken)
+	return nil
+}
+
+// GlobalSignOut revokes the user's refresh tokens and every token issued so far
+func (p *Provider) GlobalSignOut(ctx context.Context, accessToken string) error {
+	u, err := p.authenticate(accessToken)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u.signedOutAt = time.Now()
+	for token, session := range p.refreshes {
+		if session.userID == u.id {
+			delete(p.refreshes, token)
+		}
+	}
+
+	return nil
+}
+
+// FindUserByEmail returns the profile of the user with the email
+func (p *Provider) FindUserByEmail(ctx context.Context, email string) (*cognito.User, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	u, found := p.users[normalizeEmail(email)]
+	if !found {
+		return nil, cognito.ErrUserNotFound
+	}
+
+	profile := u.profile()
+	return &profile, nil
+}
+
+// AdminCreateUser creates a confirmed user with a verified email and no
+// password, who can only sign in through SignInWithCustomChallenge
+func (p *Provider) AdminCreateUser(ctx context.Context, email, name string) (*cognito.User, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := normalizeEmail(email)
+	if _, exists := p.users[key]; exists {
+		return nil, cognito.ErrUsernameExists
+	}
+
+	u := &user{
+		id:             uuid.NewString(),
+		email:          email,
+		name:           name,
+		confirmed:      true,
+		emailVerified:  true,
+		attributes:     make(map[string]string),
+		attributeCodes: make(map[string]pendingCode),
+		groups:         p.initialGroups(key),
+		createdAt:      time.Now(),
+		updatedAt:      time.Now(),
+	}
+	p.users[key] = u
+
+	profile := u.profile()
+	return &profile, nil
+}
+
+// AdminDeleteUser removes the user with the given ID and their sessions
+func (p *Provider) AdminDeleteUser(ctx context.Context, username string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for email, u := range p.users {
+		if u.id != username {
+			continue
+		}
+
+		delete(p.users, email)
+		for token, session := range p.refreshes {
+			if session.userID == u.id {
+				delete(p.refreshes, token)
+			}
+		}
+		return nil
+	}
+
+	return cognito.ErrUserNotFound
+}
+
+func (p *Provider) ForgotPassword(ctx context.Context, email string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u, found := p.users[normalizeEmail(email)]
+	if !found {
+		return cognito.ErrUserNotFound
+	}
+
+	u.resetCode, u.resetExpires = newCode(), time.Now().Add(resetCodeTTL)
+	log.Printf("local identity provider: password reset code for %s is %s", u.email, u.resetCode)
+	return nil
+}
+
+func (p *Provider) ConfirmForgotPassword(ctx context.Context, email, confirmationCode, newPassword string) error {
+	if len(newPassword) < minPasswordLength {
+		return cognito.ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u, found := p.users[normalizeEmail(email)]
+	if !found {
+		return cognito.ErrUserNotFound
+	}
+	if err := checkCode(u.resetCode, u.resetExpires, confirmationCode); err != nil {
+		return err
+	}
+
+	u.passwordHash = hash
+	u.resetCode = ""
+	u.resetRequired = false
+	u.updatedAt = time.Now()
+	// Resetting by email proves ownership of the address
+	u.confirmed = true
+	u.emailVerified = true
+	return nil
+}
+
+func (p *Provider) ChangePassword(ctx context.Context, accessToken, previousPassword, proposedPassword string) error {
+	u, err := p.authenticate(accessToken)
+	if err != nil {
+		return err
+	}
+
+	p.mu.RLock()
+	passwordHash := u.passwordHash
+	p.mu.RUnlock()
+
+	if bcrypt.CompareHashAndPassword(passwordHash, []byte(previousPassword)) != nil {
+		return cognito.ErrNotAuthorized
+	}
+	if len(proposedPassword) < minPasswordLength {
+		return cognito.ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(proposedPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u.passwordHash = hash
+	return nil
+}
+
+// UpdateUserAttributes changes the user's attributes. A new email or phone
+// number is unverified until VerifyUserAttribute gets the logged code.
+func (p *Provider) UpdateUserAttributes(ctx context.Context, accessToken string, attributes map[string]string) ([]string, error) {
+	u, err := p.authenticate(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if email, changed := attributes["email"]; changed && normalizeEmail(email) != normalizeEmail(u.email) {
+		if _, taken := p.users[normalizeEmail(email)]; taken {
+			return nil, cognito.ErrAliasExists
+		}
+	}
+
+	pending := []string{}
+	for name, value := range attributes {
+		switch name {
+		case "name":
+			u.name = value
+		case "email":
+			if normalizeEmail(value) == normalizeEmail(u.email) {
+				continue
+			}
+			delete(p.users, normalizeEmail(u.email))
+			u.email = value
+			u.emailVerified = false
+			p.users[normalizeEmail(value)] = u
+			pending = append(pending, p.sendAttributeCode(u, name))
+		case "phone_number":
+			u.attributes[name] = value
+			u.attributes["phone_number_verified"] = "false"
+			pending = append(pending, p.sendAttributeCode(u, name))
+		default:
+			u.attributes[name] = value
+		}
+	}
+
+	return pending, nil
+}
+
+// VerifyUserAttribute marks a changed email or phone number verified
+func (p *Provider) VerifyUserAttribute(ctx context.Context, accessToken, attribute, code string) error {
+	u, err := p.authenticate(accessToken)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pending := u.attributeCodes[attribute]
+	if err := checkCode(pending.code, pending.expiresAt, code); err != nil {
+		return err
+	}
+	delete(u.attributeCodes, attribute)
+
+	switch attribute {
+	case "email":
+		u.emailVerified = true
+	case "phone_number":
+		u.attributes["phone_number_verified"] = "true"
+	}
+
+	return nil
+}
+
+// sendAttributeCode logs a verification code for attribute; p.mu must be held
+func (p *Provider) sendAttributeCode(u *user, attribute string) string {
+	code := newCode()
+	u.attributeCodes[attribute] = pendingCode{code: code, expiresAt: time.Now().Add(confirmCodeTTL)}
+	log.Printf("local identity provider: %s verification code for %s is %s", attribute, u.id, code)
+	return attribute
+}
+
+// startSession issues tokens and a new refresh token for u
+func (p *Provider) startSession(u *user) (*cognito.AuthResponse, error) {
+	authTime := time.Now()
+	profile := p.profile(u)
+
+	p.mu.RLock()
+	groups := append([]string(nil), u.groups...)
+	p.mu.RUnlock()
+
+	accessToken, idToken, err := p.issueTokens(profile, groups, authTime)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.refreshes[refreshToken] = refreshSession{
+		userID:    u.id,
+		authTime:  authTime,
+		expiresAt: authTime.Add(refreshTokenTTL),
+	}
+	p.mu.Unlock()
+
+	return &cognito.AuthResponse{
+		AccessToken:  accessToken,
+		IdToken:      idToken,
+		RefreshToken: refreshToken,
+		User:         profile,
+	}, nil
+}
+
+// profile snapshots u under the lock
+func (p *Provider) profile(u *user) cognito.User {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return u.profile()
+}
+
+func (p *Provider) userByID(id string) (*user, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, u := range p.users {
+		if u.id == id {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+func (u *user) profile() cognito.User {
+	attributes := make(map[string]string, len(u.attributes))
+	for k, v := range u.attributes {
+		attributes[k] = v
+	}
+
+	return cognito.User{
+		ID:            u.id,
+		Email:         u.email,
+		EmailVerified: u.emailVerified,
+		Name:          u.name,
+		Attributes:    attributes,
+	}
+}
+
+// initialGroups returns the groups a new user with email starts in
+func (p *Provider) initialGroups(email string) []string {
+	for _, admin := range p.cfg.AdminEmails {
+		if normalizeEmail(admin) == normalizeEmail(email) {
+			return []string{"admin"}
+		}
+	}
+	return nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func checkCode(want string, expiresAt time.Time, got string) error {
+	if want == "" || got != want {
+		return cognito.ErrCodeMismatch
+	}
+	if time.Now().After(expiresAt) {
+		return cognito.ErrCodeExpired
+	}
+	return nil
+}
+
+// newCode returns a 6-digit verification code
+func newCode() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%06d", n.Int64())
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
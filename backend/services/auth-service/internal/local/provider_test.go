@@ -0,0 +1,366 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/ec-recommend/auth-service/internal/jwt"
+	"github.com/ec-recommend/auth-service/internal/proof"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testPoolID   = "ap-northeast-1_local"
+	testRegion   = "ap-northeast-1"
+	testClientID = "local-client"
+	testPassword = "correct-horse"
+)
+
+func newTestProvider(t *testing.T, cfg Config) *Provider {
+	t.Helper()
+
+	cfg.UserPoolID, cfg.Region, cfg.ClientID = testPoolID, testRegion, testClientID
+	p, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return p
+}
+
+func signUp(t *testing.T, p *Provider, email string) {
+	t.Helper()
+
+	err := p.SignUp(context.Background(), cognito.SignUpRequest{Email: email, Password: testPassword, Name: "Test User"})
+	if err != nil {
+		t.Fatalf("SignUp(%s): %v", email, err)
+	}
+}
+
+func signIn(t *testing.T, p *Provider, email string) *cognito.AuthResponse {
+	t.Helper()
+
+	resp, _, err := p.SignIn(context.Background(), cognito.SignInRequest{Email: email, Password: testPassword})
+	if err != nil {
+		t.Fatalf("SignIn(%s): %v", email, err)
+	}
+	return resp
+}
+
+func TestSignUp(t *testing.T) {
+	p := newTestProvider(t, Config{AutoConfirm: true})
+	signUp(t, p, "alice@example.com")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		want     error
+	}{
+		{"short password", "bob@example.com", "short", cognito.ErrInvalidPassword},
+		{"taken email", "alice@example.com", testPassword, cognito.ErrUsernameExists},
+		{"taken email in another case", " Alice@Example.com", testPassword, cognito.ErrUsernameExists},
+		{"new user", "bob@example.com", testPassword, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.SignUp(context.Background(), cognito.SignUpRequest{Email: tt.email, Password: tt.password})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("SignUp() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	p := newTestProvider(t, Config{AutoConfirm: true})
+	ctx := context.Background()
+
+	for _, email := range []string{"alice@example.com", "disabled@example.com", "reset@example.com"} {
+		signUp(t, p, email)
+	}
+	p.cfg.AutoConfirm = false
+	signUp(t, p, "unconfirmed@example.com")
+
+	disabled, _ := p.FindUserByEmail(ctx, "disabled@example.com")
+	if err := p.AdminSetUserEnabled(ctx, disabled.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	reset, _ := p.FindUserByEmail(ctx, "reset@example.com")
+	if err := p.AdminResetUserPassword(ctx, reset.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		want     error
+	}{
+		{"valid", "alice@example.com", testPassword, nil},
+		{"email in another case", "ALICE@example.com", testPassword, nil},
+		{"wrong password", "alice@example.com", "wrong-password", cognito.ErrNotAuthorized},
+		{"unknown user", "nobody@example.com", testPassword, cognito.ErrUserNotFound},
+		{"unconfirmed", "unconfirmed@example.com", testPassword, cognito.ErrUserNotConfirmed},
+		// Unconfirmed users only learn that once the password is right
+		{"unconfirmed with wrong password", "unconfirmed@example.com", "wrong-password", cognito.ErrNotAuthorized},
+		{"disabled", "disabled@example.com", testPassword, cognito.ErrNotAuthorized},
+		{"reset required", "reset@example.com", testPassword, cognito.ErrPasswordResetRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, challenge, err := p.SignIn(ctx, cognito.SignInRequest{Email: tt.email, Password: tt.password})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SignIn() error = %v, want %v", err, tt.want)
+			}
+			if challenge != nil {
+				t.Errorf("SignIn() raised challenge %s", challenge.ChallengeName)
+			}
+			if err == nil && (resp.AccessToken == "" || resp.IdToken == "" || resp.RefreshToken == "") {
+				t.Errorf("SignIn() = %+v, want all three tokens", resp)
+			}
+		})
+	}
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	// Unknown emails only take as long as wrong passwords while the dummy
+	// hash costs the same as real ones
+	cost, err := bcrypt.Cost(dummyPasswordHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("dummy hash cost = %d, want %d", cost, bcrypt.DefaultCost)
+	}
+}
+
+func TestSignInWithCustomChallenge(t *testing.T) {
+	secret := []byte("proof-secret")
+	p := newTestProvider(t, Config{AutoConfirm: true, ProofSecret: secret})
+	ctx := context.Background()
+
+	signUp(t, p, "alice@example.com")
+	signUp(t, p, "disabled@example.com")
+	alice, _ := p.FindUserByEmail(ctx, "alice@example.com")
+	disabled, _ := p.FindUserByEmail(ctx, "disabled@example.com")
+	if err := p.AdminSetUserEnabled(ctx, disabled.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		answer   string
+		want     error
+	}{
+		{"valid proof", alice.ID, proof.Sign(secret, alice.ID, []byte("cred-1")), nil},
+		{"any answer", alice.ID, "verified", cognito.ErrNotAuthorized},
+		{"empty answer", alice.ID, "", cognito.ErrNotAuthorized},
+		{"proof for another user", alice.ID, proof.Sign(secret, disabled.ID, []byte("cred-1")), cognito.ErrNotAuthorized},
+		{"proof with another secret", alice.ID, proof.Sign([]byte("other-secret"), alice.ID, []byte("cred-1")), cognito.ErrNotAuthorized},
+		{"disabled", disabled.ID, proof.Sign(secret, disabled.ID, []byte("cred-1")), cognito.ErrNotAuthorized},
+		{"unknown user", "nobody", proof.Sign(secret, "nobody", []byte("cred-1")), cognito.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := p.SignInWithCustomChallenge(ctx, tt.username, tt.answer)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SignInWithCustomChallenge() error = %v, want %v", err, tt.want)
+			}
+			if err == nil && resp.AccessToken == "" {
+				t.Errorf("SignInWithCustomChallenge() = %+v, want tokens", resp)
+			}
+		})
+	}
+
+	// Without a secret no proof can be checked, so none is accepted
+	p.cfg.ProofSecret = nil
+	if _, err := p.SignInWithCustomChallenge(ctx, alice.ID, proof.Sign(nil, alice.ID, []byte("cred-1"))); !errors.Is(err, cognito.ErrNotAuthorized) {
+		t.Errorf("SignInWithCustomChallenge() without a secret error = %v, want %v", err, cognito.ErrNotAuthorized)
+	}
+}
+
+func TestConfirmSignUp(t *testing.T) {
+	p := newTestProvider(t, Config{})
+	ctx := context.Background()
+	signUp(t, p, "alice@example.com")
+	signUp(t, p, "expired@example.com")
+	p.users["expired@example.com"].codeExpiresAt = time.Now().Add(-time.Minute)
+
+	code := p.users["alice@example.com"].code
+	expiredCode := p.users["expired@example.com"].code
+
+	tests := []struct {
+		name  string
+		email string
+		code  string
+		want  error
+	}{
+		{"wrong code", "alice@example.com", "not-the-code", cognito.ErrCodeMismatch},
+		{"expired code", "expired@example.com", expiredCode, cognito.ErrCodeExpired},
+		{"unknown user", "nobody@example.com", code, cognito.ErrUserNotFound},
+		{"right code", "alice@example.com", code, nil},
+		{"already confirmed", "alice@example.com", "anything", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.ConfirmSignUp(ctx, tt.email, tt.code); !errors.Is(err, tt.want) {
+				t.Errorf("ConfirmSignUp() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	signIn(t, p, "alice@example.com")
+}
+
+func TestTokensValidateAsCognitoTokens(t *testing.T) {
+	p := newTestProvider(t, Config{AutoConfirm: true, AdminEmails: []string{"Root@Example.com"}})
+	signUp(t, p, "root@example.com")
+	resp := signIn(t, p, "root@example.com")
+
+	v, err := jwt.NewValidator(testPoolID, testRegion, testClientID, jwt.WithKeySource(p.JWKS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	access, err := v.ValidateToken(resp.AccessToken, jwt.TokenUses("access"), jwt.MaxAuthAge(time.Minute))
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+	if access.Username != resp.User.ID || len(access.Groups) != 1 || access.Groups[0] != "admin" {
+		t.Errorf("access claims = %+v, want the user's ID and the admin group", access)
+	}
+
+	id, err := v.ValidateToken(resp.IdToken, jwt.TokenUses("id"))
+	if err != nil {
+		t.Fatalf("ID token: %v", err)
+	}
+	if id.Email != "root@example.com" || !id.EmailVerified {
+		t.Errorf("ID claims = %+v, want the verified email", id)
+	}
+}
+
+func TestRefreshAndSignOut(t *testing.T) {
+	p := newTestProvider(t, Config{AutoConfirm: true})
+	ctx := context.Background()
+	signUp(t, p, "alice@example.com")
+
+	first := signIn(t, p, "alice@example.com")
+	second := signIn(t, p, "alice@example.com")
+
+	refreshed, err := p.RefreshToken(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if _, err := p.GetUser(ctx, refreshed.AccessToken); err != nil {
+		t.Fatalf("GetUser with a refreshed token: %v", err)
+	}
+
+	if err := p.RevokeToken(ctx, first.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.RefreshToken(ctx, first.RefreshToken); !errors.Is(err, cognito.ErrNotAuthorized) {
+		t.Errorf("revoked refresh token: error = %v, want %v", err, cognito.ErrNotAuthorized)
+	}
+	if _, err := p.RefreshToken(ctx, second.RefreshToken); err != nil {
+		t.Errorf("other session's refresh token: %v", err)
+	}
+
+	if err := p.GlobalSignOut(ctx, second.AccessToken); err != nil {
+		t.Fatalf("GlobalSignOut: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"access token", func() error { _, err := p.GetUser(ctx, second.AccessToken); return err }},
+		{"refresh token", func() error { _, err := p.RefreshToken(ctx, second.RefreshToken); return err }},
+		{"garbage token", func() error { _, err := p.GetUser(ctx, "not-a-token"); return err }},
+		{"ID token as access token", func() error { _, err := p.GetUser(ctx, first.IdToken); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, cognito.ErrNotAuthorized) {
+				t.Errorf("error = %v, want %v", err, cognito.ErrNotAuthorized)
+			}
+		})
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	p := newTestProvider(t, Config{AutoConfirm: true})
+	ctx := context.Background()
+	signUp(t, p, "alice@example.com")
+
+	if err := p.ForgotPassword(ctx, "nobody@example.com"); !errors.Is(err, cognito.ErrUserNotFound) {
+		t.Errorf("ForgotPassword(unknown) error = %v, want %v", err, cognito.ErrUserNotFound)
+	}
+	if err := p.ForgotPassword(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	code := p.users["alice@example.com"].resetCode
+
+	tests := []struct {
+		name     string
+		code     string
+		password string
+		want     error
+	}{
+		{"short password", code, "short", cognito.ErrInvalidPassword},
+		{"wrong code", "not-the-code", "new-password", cognito.ErrCodeMismatch},
+		{"right code", code, "new-password", nil},
+		{"code reused", code, "newer-password", cognito.ErrCodeMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.ConfirmForgotPassword(ctx, "alice@example.com", tt.code, tt.password); !errors.Is(err, tt.want) {
+				t.Errorf("ConfirmForgotPassword() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := p.SignIn(ctx, cognito.SignInRequest{Email: "alice@example.com", Password: "new-password"}); err != nil {
+		t.Errorf("SignIn with the new password: %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	p := newTestProvider(t, Config{AutoConfirm: true})
+	ctx := context.Background()
+	signUp(t, p, "alice@example.com")
+	token := signIn(t, p, "alice@example.com").AccessToken
+
+	tests := []struct {
+		name     string
+		previous string
+		proposed string
+		want     error
+	}{
+		{"wrong previous password", "wrong-password", "new-password", cognito.ErrNotAuthorized},
+		{"short new password", testPassword, "short", cognito.ErrInvalidPassword},
+		{"valid", testPassword, "new-password", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.ChangePassword(ctx, token, tt.previous, tt.proposed); !errors.Is(err, tt.want) {
+				t.Errorf("ChangePassword() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := p.SignIn(ctx, cognito.SignInRequest{Email: "alice@example.com", Password: testPassword}); !errors.Is(err, cognito.ErrNotAuthorized) {
+		t.Errorf("SignIn with the old password: error = %v, want %v", err, cognito.ErrNotAuthorized)
+	}
+}
@@ -0,0 +1,147 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/cognito"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// cognitoAdminScope is the scope Cognito puts on user-pool access tokens
+const cognitoAdminScope = "aws.cognito.signin.user.admin"
+
+// JWKS returns the public half of the signing key in JWKS form
+func (p *Provider) JWKS(ctx context.Context) (jwk.Set, error) {
+	key, err := jwk.FromRaw(&p.key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build JWK: %v", err)
+	}
+
+	_ = key.Set(jwk.KeyIDKey, p.kid)
+	_ = key.Set(jwk.AlgorithmKey, jwa.RS256)
+	_ = key.Set(jwk.KeyUsageKey, jwk.ForSignature)
+
+	set := jwk.NewSet()
+	if err := set.AddKey(key); err != nil {
+		return nil, fmt.Errorf("failed to build JWKS: %v", err)
+	}
+
+	return set, nil
+}
+
+// issueTokens signs an access and ID token pair for profile with the same
+// claims Cognito puts in its tokens
+func (p *Provider) issueTokens(profile cognito.User, groups []string, authTime time.Time) (string, string, error) {
+	now := time.Now()
+	exp := now.Add(p.cfg.TokenTTL)
+
+	access := jwt.MapClaims{
+		"sub":       profile.ID,
+		"iss":       p.issuer,
+		"client_id": p.cfg.ClientID,
+		"token_use": "access",
+		"scope":     cognitoAdminScope,
+		"auth_time": authTime.Unix(),
+		"iat":       now.Unix(),
+		"exp":       exp.Unix(),
+		"jti":       uuid.NewString(),
+		"username":  profile.ID,
+	}
+
+	id := jwt.MapClaims{
+		"sub":              profile.ID,
+		"iss":              p.issuer,
+		"aud":              p.cfg.ClientID,
+		"token_use":        "id",
+		"auth_time":        authTime.Unix(),
+		"iat":              now.Unix(),
+		"exp":              exp.Unix(),
+		"jti":              uuid.NewString(),
+		"cognito:username": profile.ID,
+		"email":            profile.Email,
+		"email_verified":   profile.EmailVerified,
+	}
+	if profile.Name != "" {
+		id["name"] = profile.Name
+	}
+	if len(groups) > 0 {
+		access["cognito:groups"] = groups
+		id["cognito:groups"] = groups
+	}
+	for name, value := range profile.Attributes {
+		id[name] = value
+	}
+
+	accessToken, err := p.sign(access)
+	if err != nil {
+		return "", "", err
+	}
+
+	idToken, err := p.sign(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, idToken, nil
+}
+
+func (p *Provider) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %v", err)
+	}
+
+	return signed, nil
+}
+
+// authenticate verifies one of the provider's access tokens and returns its
+// user, as Cognito does for calls authorized by an access token
+func (p *Provider) authenticate(accessToken string) (*user, error) {
+	accessToken = strings.TrimPrefix(accessToken, "Bearer ")
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return &p.key.PublicKey, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, cognito.ErrNotAuthorized
+	}
+
+	if claims["token_use"] != "access" {
+		return nil, cognito.ErrNotAuthorized
+	}
+
+	sub, _ := claims.GetSubject()
+	u, found := p.userByID(sub)
+	if !found {
+		return nil, cognito.ErrUserNotFound
+	}
+
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return nil, cognito.ErrNotAuthorized
+	}
+
+	p.mu.RLock()
+	signedOut := !u.signedOutAt.IsZero() && !issuedAt.Time.After(u.signedOutAt)
+	disabled := u.disabled
+	p.mu.RUnlock()
+	if signedOut || disabled {
+		return nil, cognito.ErrNotAuthorized
+	}
+
+	return u, nil
+}
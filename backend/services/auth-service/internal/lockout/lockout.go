@@ -0,0 +1,300 @@
+package lockout
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// Config sets how sign-in failures are throttled. Zero values take the
+// defaults below.
+type Config struct {
+	// MaxFailures locks an email after that many consecutive failures
+	MaxFailures int
+	// IPMaxFailures locks a client IP; it is higher than MaxFailures because
+	// many users can share an address
+	IPMaxFailures int
+	// CaptchaAfter flags responses as needing a CAPTCHA once an email has
+	// that many failures; negative disables the flag
+	CaptchaAfter int
+	// BaseDelay is the wait after the first failure, doubling with each
+	// further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutDuration is how long a locked email or IP is refused
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+const (
+	defaultMaxFailures     = 5
+	defaultIPMaxFailures   = 20
+	defaultCaptchaAfter    = 3
+	defaultBaseDelay       = time.Second
+	defaultMaxDelay        = 30 * time.Second
+	defaultLockoutDuration = 15 * time.Minute
+	defaultWindow          = 15 * time.Minute
+)
+
+// Decision is the verdict for a sign-in attempt
+type Decision struct {
+	Allowed bool
+	// Locked is set when the email or IP is locked out rather than backing off
+	Locked bool
+	// RetryAfter is how long the client must wait when not allowed
+	RetryAfter      time.Duration
+	CaptchaRequired bool
+}
+
+// Lock event types
+const (
+	EventLocked   = "locked"
+	EventUnlocked = "unlocked"
+)
+
+// Event reports an email or IP being locked out, or its lockout running out
+type Event struct {
+	Type string
+	// Email or IP is the locked subject; the other is empty
+	Email string
+	IP    string
+	// Failures and Until describe a lock
+	Failures int
+	Until    time.Time
+}
+
+// Notifier receives lock events, such as for the security audit log
+type Notifier func(ctx context.Context, event Event)
+
+// Limiter tracks failed sign-ins by email and by client IP, slowing repeated
+// failures down with exponential backoff and locking out after too many
+type Limiter struct {
+	cfg    Config
+	store  Store
+	notify Notifier
+}
+
+// NewLimiter throttles with cfg, keeping counts in store. notify, if not
+// nil, is told about every lock and unlock.
+func NewLimiter(cfg Config, store Store, notify Notifier) *Limiter {
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = defaultMaxFailures
+	}
+	if cfg.IPMaxFailures <= 0 {
+		cfg.IPMaxFailures = defaultIPMaxFailures
+	}
+	if cfg.CaptchaAfter == 0 {
+		cfg.CaptchaAfter = defaultCaptchaAfter
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = defaultLockoutDuration
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultWindow
+	}
+
+	if notify == nil {
+		notify = func(context.Context, Event) {}
+	}
+
+	return &Limiter{
+		cfg:    cfg,
+		store:  store,
+		notify: notify,
+	}
+}
+
+// subject is a throttled email or IP and its store key
+type subject struct {
+	key   string
+	email string
+	ip    string
+}
+
+func emailSubject(email string) subject {
+	email = strings.ToLower(strings.TrimSpace(email))
+	return subject{key: "email:" + email, email: email}
+}
+
+func ipSubject(ip string) subject {
+	return subject{key: "ip:" + ip, ip: ip}
+}
+
+func subjects(email, ip string) []subject {
+	return []subject{emailSubject(email), ipSubject(ip)}
+}
+
+// event describes a lock change of s
+func (s subject) event(eventType string) Event {
+	return Event{Type: eventType, Email: s.email, IP: s.ip}
+}
+
+// Reserve decides whether a sign-in may be attempted now and, if it may,
+// counts the attempt against the email and IP before the password is
+// checked, so concurrent attempts can't all get in under the limit. Every
+// allowed attempt must be settled with Failure or Release. Store errors are
+// logged and the attempt allowed, so an outage of the store doesn't lock
+// every user out; the identity provider still applies its own limits.
+func (l *Limiter) Reserve(ctx context.Context, email, ip string) Decision {
+	now := time.Now()
+	decision := Decision{Allowed: true}
+
+	var reserved []subject
+	for _, s := range subjects(email, ip) {
+		state, err := l.reserve(ctx, s, now)
+		if err != nil {
+			log.Printf("Sign-in throttle update for %s failed: %v", s.key, err)
+			continue
+		}
+		reserved = append(reserved, s)
+
+		// Decide on the attempts before this one
+		prior := state
+		prior.Failures--
+		l.apply(&decision, s.key, prior, now)
+
+		if state.Failures > l.maxFailures(s.key) && !decision.Locked {
+			// Attempts still in flight take up the rest of the limit
+			decision.Allowed = false
+			decision.RetryAfter = max(decision.RetryAfter, l.cfg.BaseDelay)
+		}
+	}
+
+	if !decision.Allowed {
+		for _, s := range reserved {
+			l.release(ctx, s)
+		}
+	}
+
+	return decision
+}
+
+// reserve counts an attempt for s, starting the key over first if its
+// lockout has run out
+func (l *Limiter) reserve(ctx context.Context, s subject, now time.Time) (State, error) {
+	state, err := l.store.Reserve(ctx, s.key, now, l.cfg.Window)
+	if err != nil || state.LockedUntil.IsZero() || now.Before(state.LockedUntil) {
+		return state, err
+	}
+
+	if err := l.store.Reset(ctx, s.key); err != nil {
+		log.Printf("Sign-in throttle reset for %s failed: %v", s.key, err)
+	}
+	l.notify(ctx, s.event(EventUnlocked))
+
+	return l.store.Reserve(ctx, s.key, now, l.cfg.Window)
+}
+
+// Failure settles a reserved attempt as a failed sign-in and returns the
+// decision for the next attempt, locking the email or IP once it reaches
+// its limit
+func (l *Limiter) Failure(ctx context.Context, email, ip string) Decision {
+	now := time.Now()
+	decision := Decision{Allowed: true}
+
+	for _, s := range subjects(email, ip) {
+		state, err := l.store.RecordFailure(ctx, s.key, now, l.cfg.Window)
+		if err != nil {
+			log.Printf("Sign-in throttle update for %s failed: %v", s.key, err)
+			continue
+		}
+
+		if state.Failures >= l.maxFailures(s.key) && state.LockedUntil.IsZero() {
+			state.LockedUntil = now.Add(l.cfg.LockoutDuration)
+			if err := l.store.Lock(ctx, s.key, state.LockedUntil); err != nil {
+				log.Printf("Sign-in lockout for %s failed: %v", s.key, err)
+				continue
+			}
+
+			event := s.event(EventLocked)
+			event.Failures, event.Until = state.Failures, state.LockedUntil
+			l.notify(ctx, event)
+		}
+
+		l.apply(&decision, s.key, state, now)
+	}
+
+	return decision
+}
+
+// Release settles a reserved attempt that didn't fail: the password was
+// right, or was never checked because of a CAPTCHA or provider error
+func (l *Limiter) Release(ctx context.Context, email, ip string) {
+	for _, s := range subjects(email, ip) {
+		l.release(ctx, s)
+	}
+}
+
+func (l *Limiter) release(ctx context.Context, s subject) {
+	if err := l.store.Release(ctx, s.key); err != nil {
+		log.Printf("Sign-in throttle release for %s failed: %v", s.key, err)
+	}
+}
+
+// Success clears the email's failures once the user is signed in, after
+// any MFA challenge. The IP's are kept so one valid account can't be used
+// to reset the counter while guessing others.
+func (l *Limiter) Success(ctx context.Context, email string) {
+	key := emailSubject(email).key
+
+	state, err := l.store.Get(ctx, key)
+	if err != nil {
+		log.Printf("Sign-in throttle lookup for %s failed: %v", key, err)
+		return
+	}
+	if state.Failures == 0 {
+		return
+	}
+
+	if err := l.store.Reset(ctx, key); err != nil {
+		log.Printf("Sign-in throttle reset for %s failed: %v", key, err)
+	}
+}
+
+func (l *Limiter) maxFailures(key string) int {
+	if strings.HasPrefix(key, "ip:") {
+		return l.cfg.IPMaxFailures
+	}
+	return l.cfg.MaxFailures
+}
+
+// apply folds one key's state into the decision
+func (l *Limiter) apply(decision *Decision, key string, state State, now time.Time) {
+	if state.Failures == 0 {
+		return
+	}
+
+	if strings.HasPrefix(key, "email:") && l.cfg.CaptchaAfter > 0 && state.Failures >= l.cfg.CaptchaAfter {
+		decision.CaptchaRequired = true
+	}
+
+	var wait time.Duration
+	if now.Before(state.LockedUntil) {
+		decision.Locked = true
+		wait = state.LockedUntil.Sub(now)
+	} else {
+		wait = state.LastFailure.Add(l.backoff(state.Failures)).Sub(now)
+	}
+
+	if wait > 0 {
+		decision.Allowed = false
+		decision.RetryAfter = max(decision.RetryAfter, wait)
+	}
+}
+
+// backoff is the wait after the given number of failures
+func (l *Limiter) backoff(failures int) time.Duration {
+	delay := l.cfg.BaseDelay
+	for i := 1; i < failures && delay < l.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, l.cfg.MaxDelay)
+}
@@ -0,0 +1,382 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// noBackoff keeps backoff out of the way of threshold tests
+var noBackoff = Config{BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond}
+
+// recorder collects lock events
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) notify(ctx context.Context, e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := make([]string, len(r.events))
+	for i, e := range r.events {
+		types[i] = e.Type + ":" + e.Email + e.IP
+	}
+	return types
+}
+
+// fail makes a sign-in attempt that fails, as the sign-in handler would
+func fail(ctx context.Context, l *Limiter, email, ip string) Decision {
+	if d := l.Reserve(ctx, email, ip); !d.Allowed {
+		return d
+	}
+	return l.Failure(ctx, email, ip)
+}
+
+func stores(t *testing.T) map[string]func() Store {
+	return map[string]func() Store{
+		"memory": func() Store { return NewMemoryStore() },
+		"redis": func() Store {
+			server := miniredis.RunT(t)
+			store, err := NewRedisStore("redis://" + server.Addr())
+			if err != nil {
+				t.Fatalf("NewRedisStore: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+}
+
+func TestEmailThresholds(t *testing.T) {
+	cfg := noBackoff
+	cfg.MaxFailures = 3
+	cfg.CaptchaAfter = 2
+
+	tests := []struct {
+		failures    int
+		wantAllowed bool
+		wantLocked  bool
+		wantCaptcha bool
+	}{
+		{0, true, false, false},
+		{1, true, false, false},
+		{2, true, false, true},
+		{3, false, true, true},
+	}
+
+	for name, newStore := range stores(t) {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/%d failures", name, tt.failures), func(t *testing.T) {
+				l := NewLimiter(cfg, newStore(), nil)
+				ctx := context.Background()
+
+				for i := 0; i < tt.failures; i++ {
+					fail(ctx, l, "alice@example.com", "192.0.2.1")
+				}
+				time.Sleep(time.Millisecond)
+
+				d := l.Reserve(ctx, "alice@example.com", "192.0.2.1")
+				if d.Allowed != tt.wantAllowed || d.Locked != tt.wantLocked || d.CaptchaRequired != tt.wantCaptcha {
+					t.Fatalf("after %d failures: Reserve() = %+v, want allowed=%v locked=%v captcha=%v",
+						tt.failures, d, tt.wantAllowed, tt.wantLocked, tt.wantCaptcha)
+				}
+				if tt.wantLocked && (d.RetryAfter <= 0 || d.RetryAfter > defaultLockoutDuration) {
+					t.Errorf("RetryAfter = %v, want up to the lockout duration", d.RetryAfter)
+				}
+			})
+		}
+	}
+}
+
+func TestIPThreshold(t *testing.T) {
+	cfg := noBackoff
+	cfg.MaxFailures = 10
+	cfg.IPMaxFailures = 3
+
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := NewLimiter(cfg, newStore(), nil)
+			ctx := context.Background()
+
+			// Guessing a different account each time still adds up per IP
+			for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+				fail(ctx, l, email, "192.0.2.1")
+			}
+
+			if d := l.Reserve(ctx, "d@example.com", "192.0.2.1"); d.Allowed || !d.Locked {
+				t.Errorf("same IP: Reserve() = %+v, want locked", d)
+			}
+			if d := l.Reserve(ctx, "d@example.com", "192.0.2.2"); !d.Allowed {
+				t.Errorf("other IP: Reserve() = %+v, want allowed", d)
+			}
+			// The email count is below its own limit, so no CAPTCHA
+			if d := l.Reserve(ctx, "a@example.com", "192.0.2.2"); d.CaptchaRequired {
+				t.Errorf("IP failures flagged a CAPTCHA for the email: %+v", d)
+			}
+		})
+	}
+}
+
+func TestReserveCountsAttemptsInFlight(t *testing.T) {
+	cfg := noBackoff
+	cfg.MaxFailures = 3
+
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := NewLimiter(cfg, newStore(), nil)
+			ctx := context.Background()
+
+			// Attempts that haven't been settled yet count against the limit
+			for i := 0; i < cfg.MaxFailures; i++ {
+				if d := l.Reserve(ctx, "alice@example.com", "192.0.2.1"); !d.Allowed {
+					t.Fatalf("attempt %d: Reserve() = %+v, want allowed", i+1, d)
+				}
+			}
+			d := l.Reserve(ctx, "alice@example.com", "192.0.2.1")
+			if d.Allowed || d.Locked || d.RetryAfter <= 0 {
+				t.Fatalf("attempt over the limit: Reserve() = %+v, want refused without a lock", d)
+			}
+
+			// A refused attempt takes its own reservation back, and a
+			// released one frees its place
+			l.Release(ctx, "alice@example.com", "192.0.2.1")
+			if d := l.Reserve(ctx, "alice@example.com", "192.0.2.1"); !d.Allowed {
+				t.Errorf("after a release: Reserve() = %+v, want allowed", d)
+			}
+		})
+	}
+}
+
+func TestReserveIsAtomic(t *testing.T) {
+	cfg := noBackoff
+	cfg.MaxFailures = 5
+
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := NewLimiter(cfg, newStore(), nil)
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			allowed := 0
+			for i := 0; i < 4*cfg.MaxFailures; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if d := l.Reserve(ctx, "alice@example.com", "192.0.2.1"); d.Allowed {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if allowed != cfg.MaxFailures {
+				t.Errorf("%d concurrent attempts allowed, want %d", allowed, cfg.MaxFailures)
+			}
+		})
+	}
+}
+
+func TestReleaseLeavesNoRecord(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			l := NewLimiter(noBackoff, store, nil)
+			ctx := context.Background()
+
+			fail(ctx, l, "alice@example.com", "192.0.2.1")
+			l.Reserve(ctx, "alice@example.com", "192.0.2.1")
+			l.Release(ctx, "alice@example.com", "192.0.2.1")
+
+			if state, _ := store.Get(ctx, "email:alice@example.com"); state.Failures != 1 {
+				t.Errorf("after a failure and a release: %d failures, want 1", state.Failures)
+			}
+
+			l.Reserve(ctx, "bob@example.com", "192.0.2.2")
+			l.Release(ctx, "bob@example.com", "192.0.2.2")
+
+			for _, key := range []string{"email:bob@example.com", "ip:192.0.2.2"} {
+				if state, _ := store.Get(ctx, key); state != (State{}) {
+					t.Errorf("%s after a release = %+v, want no record", key, state)
+				}
+			}
+		})
+	}
+}
+
+func TestEmailsAreNormalized(t *testing.T) {
+	cfg := noBackoff
+	cfg.MaxFailures = 2
+	l := NewLimiter(cfg, NewMemoryStore(), nil)
+	ctx := context.Background()
+
+	fail(ctx, l, "Alice@Example.com", "192.0.2.1")
+	fail(ctx, l, " alice@example.com ", "192.0.2.2")
+
+	if d := l.Reserve(ctx, "ALICE@example.com", "192.0.2.3"); !d.Locked {
+		t.Errorf("Reserve() = %+v, want the email locked across spellings", d)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	l := NewLimiter(Config{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, NewMemoryStore(), nil)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{20, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := l.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestFailureBacksOff(t *testing.T) {
+	l := NewLimiter(Config{BaseDelay: time.Minute, MaxDelay: time.Hour}, NewMemoryStore(), nil)
+	ctx := context.Background()
+
+	next := fail(ctx, l, "alice@example.com", "192.0.2.1")
+	if next.Allowed || next.Locked {
+		t.Fatalf("Failure() = %+v, want a backoff", next)
+	}
+	if next.RetryAfter <= 0 || next.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v, want up to a minute", next.RetryAfter)
+	}
+
+	if d := l.Reserve(ctx, "alice@example.com", "192.0.2.1"); d.Allowed {
+		t.Errorf("Reserve() during backoff = %+v, want refused", d)
+	}
+}
+
+func TestSuccessKeepsIPFailures(t *testing.T) {
+	cfg := noBackoff
+	cfg.MaxFailures = 2
+	cfg.IPMaxFailures = 3
+	l := NewLimiter(cfg, NewMemoryStore(), nil)
+	ctx := context.Background()
+
+	fail(ctx, l, "alice@example.com", "192.0.2.1")
+	l.Success(ctx, "alice@example.com")
+	fail(ctx, l, "alice@example.com", "192.0.2.1")
+
+	if d := l.Reserve(ctx, "alice@example.com", "192.0.2.9"); !d.Allowed {
+		t.Errorf("email after success: Reserve() = %+v, want the count restarted", d)
+	}
+
+	// A valid sign-in doesn't clear the IP, so the third failure locks it
+	fail(ctx, l, "bob@example.com", "192.0.2.1")
+	if d := l.Reserve(ctx, "carol@example.com", "192.0.2.1"); !d.Locked {
+		t.Errorf("IP: Reserve() = %+v, want locked", d)
+	}
+}
+
+func TestCaptchaDisabled(t *testing.T) {
+	cfg := noBackoff
+	cfg.CaptchaAfter = -1
+	l := NewLimiter(cfg, NewMemoryStore(), nil)
+	ctx := context.Background()
+
+	for i := 0; i < defaultMaxFailures-1; i++ {
+		if d := fail(ctx, l, "alice@example.com", "192.0.2.1"); d.CaptchaRequired {
+			t.Fatalf("failure %d asked for a CAPTCHA with CaptchaAfter = -1", i+1)
+		}
+	}
+}
+
+func TestLockEvents(t *testing.T) {
+	events := &recorder{}
+	cfg := noBackoff
+	cfg.MaxFailures = 2
+	cfg.IPMaxFailures = 2
+	cfg.LockoutDuration = 20 * time.Millisecond
+	l := NewLimiter(cfg, NewMemoryStore(), events.notify)
+	ctx := context.Background()
+
+	fail(ctx, l, "alice@example.com", "192.0.2.1")
+	before := time.Now()
+	fail(ctx, l, "alice@example.com", "192.0.2.1")
+	// Further failures while locked don't lock again
+	fail(ctx, l, "alice@example.com", "192.0.2.1")
+
+	want := []string{"locked:alice@example.com", "locked:192.0.2.1"}
+	if got := events.types(); !equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	locked := events.events[0]
+	if locked.Failures != 2 || locked.Until.Before(before.Add(cfg.LockoutDuration)) {
+		t.Errorf("lock event = %+v, want 2 failures locked for %v", locked, cfg.LockoutDuration)
+	}
+
+	time.Sleep(2 * cfg.LockoutDuration)
+
+	if d := l.Reserve(ctx, "alice@example.com", "192.0.2.1"); !d.Allowed || d.CaptchaRequired {
+		t.Fatalf("Reserve() after the lockout = %+v, want allowed with a fresh count", d)
+	}
+	want = append(want, "unlocked:alice@example.com", "unlocked:192.0.2.1")
+	if got := events.types(); !equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
+
+// failingStore fails every call, like an unreachable Redis
+type failingStore struct{}
+
+var errStoreDown = errors.New("store down")
+
+func (failingStore) Get(context.Context, string) (State, error) { return State{}, errStoreDown }
+func (failingStore) Reserve(context.Context, string, time.Time, time.Duration) (State, error) {
+	return State{}, errStoreDown
+}
+func (failingStore) Release(context.Context, string) error { return errStoreDown }
+func (failingStore) RecordFailure(context.Context, string, time.Time, time.Duration) (State, error) {
+	return State{}, errStoreDown
+}
+func (failingStore) Lock(context.Context, string, time.Time) error { return errStoreDown }
+func (failingStore) Reset(context.Context, string) error           { return errStoreDown }
+
+func TestStoreOutageAllowsSignIn(t *testing.T) {
+	l := NewLimiter(Config{}, failingStore{}, nil)
+	ctx := context.Background()
+
+	for i := 0; i < 2*defaultIPMaxFailures; i++ {
+		fail(ctx, l, "alice@example.com", "192.0.2.1")
+	}
+	if d := l.Reserve(ctx, "alice@example.com", "192.0.2.1"); !d.Allowed {
+		t.Errorf("Reserve() = %+v, want allowed while the store is down", d)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
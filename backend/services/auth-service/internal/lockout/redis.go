@@ -0,0 +1,138 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "auth:signin:"
+
+// RedisStore keeps failure records in Redis so every instance of the
+// service sees the same counts. Each key is a hash of failures, last and
+// locked_until, with the hash's TTL doing the expiry.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis server at url (redis://...)
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %v", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return &RedisStore{client: client}, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (State, error) {
+	values, err := s.client.HGetAll(ctx, redisKeyPrefix+key).Result()
+	if err != nil {
+		return State{}, err
+	}
+	return stateFromHash(values), nil
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, key string, now time.Time, window time.Duration) (State, error) {
+	redisKey := redisKeyPrefix + key
+
+	var failures *redis.IntCmd
+	var values *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		failures = pipe.HIncrBy(ctx, redisKey, "failures", 1)
+		values = pipe.HGetAll(ctx, redisKey)
+		return nil
+	})
+	if err != nil {
+		return State{}, err
+	}
+
+	state := stateFromHash(values.Val())
+	state.Failures = int(failures.Val())
+
+	return state, s.expire(ctx, redisKey, state, now.Add(window))
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	redisKey := redisKeyPrefix + key
+
+	failures, err := s.client.HIncrBy(ctx, redisKey, "failures", -1).Result()
+	if err != nil {
+		return err
+	}
+	// Nothing is left to count, or the record expired while the attempt was
+	// in flight and the decrement created a new one without a TTL
+	if failures <= 0 {
+		return s.client.Del(ctx, redisKey).Err()
+	}
+	return nil
+}
+
+func (s *RedisStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (State, error) {
+	redisKey := redisKeyPrefix + key
+
+	var values *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, "last", now.UnixMilli())
+		values = pipe.HGetAll(ctx, redisKey)
+		return nil
+	})
+	if err != nil {
+		return State{}, err
+	}
+
+	state := stateFromHash(values.Val())
+	return state, s.expire(ctx, redisKey, state, now.Add(window))
+}
+
+// expire keeps the record until expiresAt, or until a lockout ends if later
+func (s *RedisStore) expire(ctx context.Context, redisKey string, state State, expiresAt time.Time) error {
+	if state.LockedUntil.After(expiresAt) {
+		expiresAt = state.LockedUntil
+	}
+	return s.client.PExpireAt(ctx, redisKey, expiresAt).Err()
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, until time.Time) error {
+	redisKey := redisKeyPrefix + key
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, "locked_until", until.UnixMilli())
+		pipe.PExpireAt(ctx, redisKey, until)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, redisKeyPrefix+key).Err()
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func stateFromHash(values map[string]string) State {
+	var state State
+	state.Failures, _ = strconv.Atoi(values["failures"])
+	if ms, err := strconv.ParseInt(values["last"], 10, 64); err == nil {
+		state.LastFailure = time.UnixMilli(ms)
+	}
+	if ms, err := strconv.ParseInt(values["locked_until"], 10, 64); err == nil {
+		state.LockedUntil = time.UnixMilli(ms)
+	}
+	return state
+}
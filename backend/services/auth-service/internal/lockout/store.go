@@ -0,0 +1,142 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// State is the failure record for one email or IP
+type State struct {
+	Failures    int
+	LastFailure time.Time
+	// LockedUntil is zero unless the key has been locked out
+	LockedUntil time.Time
+}
+
+// Store keeps failure records. Records expire on their own once the window
+// after the last failure, or the lockout, has passed.
+type Store interface {
+	// Get returns the key's state, or a zero State if it has none
+	Get(ctx context.Context, key string) (State, error)
+	// Reserve counts an attempt in Failures, atomically, and keeps the
+	// record for window
+	Reserve(ctx context.Context, key string, now time.Time, window time.Duration) (State, error)
+	// Release takes a reserved attempt back out of Failures
+	Release(ctx context.Context, key string) error
+	// RecordFailure marks a reserved attempt as failed at now and keeps the
+	// record for window
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (State, error)
+	// Lock locks the key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// MemoryStore is an in-process Store
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	state     State
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, found := s.entries[key]
+	if !found || time.Now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return State{}, nil
+	}
+	return entry.state, nil
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, key string, now time.Time, window time.Duration) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeLocked(now)
+
+	entry := s.entries[key]
+	entry.state.Failures++
+	entry.expiresAt = maxTime(entry.expiresAt, now.Add(window))
+	s.entries[key] = entry
+
+	return entry.state, nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, found := s.entries[key]
+	if !found {
+		return nil
+	}
+	entry.state.Failures--
+	if entry.state.Failures <= 0 || time.Now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil
+	}
+	s.entries[key] = entry
+
+	return nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	entry.state.LastFailure = now
+	entry.expiresAt = maxTime(entry.expiresAt, now.Add(window))
+	s.entries[key] = entry
+
+	return entry.state, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	entry.state.LockedUntil = until
+	entry.expiresAt = maxTime(entry.expiresAt, until)
+	s.entries[key] = entry
+
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// purgeLocked drops expired records so abandoned keys don't accumulate
+func (s *MemoryStore) purgeLocked(now time.Time) {
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,317 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ec-recommend/backend/shared/go/observability"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+const (
+	stateTTL = 10 * time.Minute
+	// minKeyRefetch rate limits JWKS refetches for unknown key IDs
+	minKeyRefetch = time.Minute
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown oauth provider")
+	ErrTokenExchange   = errors.New("authorization code exchange failed")
+	ErrInvalidIDToken  = errors.New("invalid ID token")
+)
+
+// Client runs the authorization code flow with PKCE against the configured
+// providers and verifies the ID tokens they return
+type Client struct {
+	redirectBase string
+	states       StateStore
+	httpClient   *http.Client
+	providers    map[string]*provider
+}
+
+// provider is a Provider with its cached signing keys
+type provider struct {
+	Provider
+
+	mu          sync.Mutex
+	keys        jwk.Set
+	lastFetched time.Time
+}
+
+// NewClient creates a Client. redirectBase is the public URL of this service;
+// callbacks go to {redirectBase}/auth/oauth/{provider}/callback.
+func NewClient(redirectBase string, states StateStore, providers ...Provider) *Client {
+	c := &Client{
+		redirectBase: strings.TrimSuffix(redirectBase, "/"),
+		states:       states,
+		httpClient:   observability.HTTPClient(10 * time.Second),
+		providers:    make(map[string]*provider),
+	}
+	for _, p := range providers {
+		c.providers[p.Name] = &provider{Provider: p}
+	}
+	return c
+}
+
+// Provider returns the named provider's configuration
+func (c *Client) Provider(name string) (Provider, bool) {
+	p, ok := c.providers[name]
+	if !ok {
+		return Provider{}, false
+	}
+	return p.Provider, true
+}
+
+// Providers lists the configured provider names
+func (c *Client) Providers() []string {
+	names := make([]string, 0, len(c.providers))
+	for name := range c.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (c *Client) redirectURI(name string) string {
+	return c.redirectBase + "/auth/oauth/" + name + "/callback"
+}
+
+// AuthorizationURL starts a sign-in with the named provider, returning the
+// URL to send the user to and the state the callback must come back with.
+// loginHint, when set, pre-fills the provider's account chooser.
+func (c *Client) AuthorizationURL(ctx context.Context, name, loginHint string) (string, string, error) {
+	p, ok := c.providers[name]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	err = c.states.Save(ctx, AuthRequest{
+		State:     state,
+		Provider:  name,
+		Verifier:  verifier,
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(stateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {c.redirectURI(name)},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if p.FormPost {
+		params.Set("response_mode", "form_post")
+	}
+	if loginHint != "" {
+		params.Set("login_hint", loginHint)
+	}
+
+	return p.AuthURL + "?" + params.Encode(), state, nil
+}
+
+// Exchange finishes a sign-in: it checks state, redeems the code with the
+// PKCE verifier and verifies the returned ID token
+func (c *Client) Exchange(ctx context.Context, name, code, state string) (*Identity, error) {
+	p, ok := c.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	req, err := c.states.Take(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if req.Provider != name {
+		return nil, ErrInvalidState
+	}
+
+	idToken, err := c.redeem(ctx, p, code, req.Verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.verifyIDToken(p, idToken, req.Nonce)
+}
+
+func (c *Client) redeem(ctx context.Context, p *provider, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.redirectURI(p.Name)},
+		"client_id":     {p.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %s: %s", ErrTokenExchange, resp.Status, body)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.IDToken == "" {
+		return "", fmt.Errorf("%w: no ID token in response", ErrTokenExchange)
+	}
+
+	return tokens.IDToken, nil
+}
+
+// idTokenClaims are the claims read from provider ID tokens. Apple sends
+// email_verified as a string, so it is decoded loosely.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string      `json:"nonce"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	Name          string      `json:"name"`
+}
+
+func (c *Client) verifyIDToken(p *provider, idToken, nonce string) (*Identity, error) {
+	methods := []string{"RS256", "ES256"}
+	if p.SecretSignedIDTokens {
+		methods = append(methods, "HS256")
+	}
+
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() == "HS256" {
+			return []byte(p.ClientSecret), nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		return c.signingKey(p, kid)
+	},
+		jwt.WithValidMethods(methods),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if !p.trustsIssuer(claims.Issuer) {
+		return nil, fmt.Errorf("%w: untrusted issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+
+	verified := p.TrustEmail && claims.Email != ""
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+
+	return &Identity{
+		Provider:      p.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// signingKey finds kid in the provider's JWKS, refetching it when the key is
+// unknown (providers rotate keys) at most once per minKeyRefetch
+func (c *Client) signingKey(p *provider, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys == nil || time.Since(p.lastFetched) >= minKeyRefetch {
+		if _, found := lookupKey(p.keys, kid); !found {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			keys, err := jwk.Fetch(ctx, p.JWKSURL, jwk.WithHTTPClient(c.httpClient))
+			p.lastFetched = time.Now()
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch JWKS: %v", err)
+			}
+			p.keys = keys
+		}
+	}
+
+	key, found := lookupKey(p.keys, kid)
+	if !found {
+		return nil, fmt.Errorf("signing key %q not found", kid)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func lookupKey(keys jwk.Set, kid string) (jwk.Key, bool) {
+	if keys == nil {
+		return nil, false
+	}
+	return keys.LookupKeyID(kid)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
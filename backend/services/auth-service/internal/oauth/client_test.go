@@ -0,0 +1,281 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testRedirectBase = "https://shop.example.com/api/"
+
+// newLocalFlow serves a LocalIssuer and returns a Client configured for it
+func newLocalFlow(t *testing.T) (*Client, *MemoryStateStore) {
+	t.Helper()
+
+	var issuer *LocalIssuer
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) { issuer.Authorize(w, r) })
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) { issuer.Token(w, r) })
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) { issuer.JWKS(w, r) })
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var err error
+	issuer, err = NewLocalIssuer(server.URL, "local-client")
+	if err != nil {
+		t.Fatalf("NewLocalIssuer: %v", err)
+	}
+
+	states := NewMemoryStateStore()
+	return NewClient(testRedirectBase, states, issuer.Provider()), states
+}
+
+// authorize follows an authorization URL to the callback and returns its
+// code and state
+func authorize(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want a redirect", resp.StatusCode)
+	}
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := callback.Scheme + "://" + callback.Host + callback.Path; got != "https://shop.example.com/api/auth/oauth/local/callback" {
+		t.Errorf("callback = %s, want the client's redirect URI", got)
+	}
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func TestAuthorizationURL(t *testing.T) {
+	states := NewMemoryStateStore()
+	c := NewClient(testRedirectBase, states, Google("google-client", "secret"), Apple("apple-client", "secret"))
+	ctx := context.Background()
+
+	if _, _, err := c.AuthorizationURL(ctx, "facebook", ""); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("unknown provider: error = %v, want %v", err, ErrUnknownProvider)
+	}
+
+	tests := []struct {
+		provider     string
+		loginHint    string
+		wantClientID string
+		wantMode     string
+	}{
+		{"google", "", "google-client", ""},
+		{"google", "alice@example.com", "google-client", ""},
+		{"apple", "", "apple-client", "form_post"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.loginHint, func(t *testing.T) {
+			authURL, state, err := c.AuthorizationURL(ctx, tt.provider, tt.loginHint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(authURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := u.Query()
+
+			want := map[string]string{
+				"response_type":         "code",
+				"client_id":             tt.wantClientID,
+				"redirect_uri":          "https://shop.example.com/api/auth/oauth/" + tt.provider + "/callback",
+				"state":                 state,
+				"code_challenge_method": "S256",
+				"response_mode":         tt.wantMode,
+				"login_hint":            tt.loginHint,
+			}
+			for name, value := range want {
+				if q.Get(name) != value {
+					t.Errorf("%s = %q, want %q", name, q.Get(name), value)
+				}
+			}
+
+			// The challenge must match the verifier kept for the callback
+			req, err := states.Take(ctx, state)
+			if err != nil {
+				t.Fatalf("state was not saved: %v", err)
+			}
+			challenge := sha256.Sum256([]byte(req.Verifier))
+			if q.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+				t.Error("code_challenge is not the S256 of the saved verifier")
+			}
+			if q.Get("nonce") != req.Nonce || req.Provider != tt.provider {
+				t.Errorf("saved request = %+v, want the nonce and provider of the URL", req)
+			}
+		})
+	}
+}
+
+func TestLocalIssuerFlow(t *testing.T) {
+	c, _ := newLocalFlow(t)
+	ctx := context.Background()
+
+	authURL, _, err := c.AuthorizationURL(ctx, "local", "Alice@Example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := authorize(t, authURL)
+
+	identity, err := c.Exchange(ctx, "local", code, state)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Provider != "local" || identity.Subject == "" || identity.Email != "Alice@Example.com" || !identity.EmailVerified {
+		t.Errorf("identity = %+v", identity)
+	}
+
+	// The same email maps to the same subject on the next sign-in
+	authURL, _, _ = c.AuthorizationURL(ctx, "local", "alice@example.com")
+	code, state = authorize(t, authURL)
+	again, err := c.Exchange(ctx, "local", code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Subject != identity.Subject {
+		t.Errorf("subject = %s, want %s", again.Subject, identity.Subject)
+	}
+}
+
+func TestExchangeRejections(t *testing.T) {
+	c, states := newLocalFlow(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		tamper  func(code, state string) (string, string, string)
+		wantErr error
+	}{
+		{"unknown provider", func(code, state string) (string, string, string) { return "google", code, state }, ErrUnknownProvider},
+		{"unknown state", func(code, state string) (string, string, string) { return "local", code, "forged" }, ErrInvalidState},
+		{"wrong code", func(code, state string) (string, string, string) { return "local", "forged", state }, ErrTokenExchange},
+		{"wrong verifier", func(code, state string) (string, string, string) {
+			req, _ := states.Take(ctx, state)
+			req.Verifier = "guessed"
+			states.Save(ctx, *req)
+			return "local", code, state
+		}, ErrTokenExchange},
+		{"state from another provider", func(code, state string) (string, string, string) {
+			req, _ := states.Take(ctx, state)
+			req.Provider = "google"
+			states.Save(ctx, *req)
+			return "local", code, state
+		}, ErrInvalidState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authURL, _, err := c.AuthorizationURL(ctx, "local", "alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, code, state := tt.tamper(authorize(t, authURL))
+
+			if _, err := c.Exchange(ctx, name, code, state); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Exchange() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("replayed callback", func(t *testing.T) {
+		authURL, _, _ := c.AuthorizationURL(ctx, "local", "alice@example.com")
+		code, state := authorize(t, authURL)
+		if _, err := c.Exchange(ctx, "local", code, state); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Exchange(ctx, "local", code, state); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("Exchange() error = %v, want %v", err, ErrInvalidState)
+		}
+	})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	line := &provider{Provider: LINE("line-client", "line-secret")}
+	google := &provider{Provider: Google("google-client", "google-secret")}
+	// Google's issuers on a secret-signed provider, so tokens need no keys
+	googleIssuers := &provider{Provider: Google("line-client", "line-secret")}
+	googleIssuers.SecretSignedIDTokens = true
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "https://access.line.me",
+			"aud":   "line-client",
+			"sub":   "U123",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": "nonce",
+			"email": "alice@example.com",
+		}
+	}
+	with := func(name string, value interface{}) jwt.MapClaims {
+		claims := valid()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name         string
+		provider     *provider
+		claims       jwt.MapClaims
+		secret       string
+		wantErr      error
+		wantVerified bool
+	}{
+		{"secret signed", line, valid(), "line-secret", nil, true},
+		{"email_verified false", line, with("email_verified", false), "line-secret", nil, false},
+		{"email_verified as a string", line, with("email_verified", "true"), "line-secret", nil, true},
+		{"no email", line, with("email", nil), "line-secret", nil, false},
+		{"wrong secret", line, valid(), "other-secret", ErrInvalidIDToken, false},
+		{"HS256 for a provider with keys", google, valid(), "google-secret", ErrInvalidIDToken, false},
+		{"wrong issuer", line, with("iss", "https://evil.example.com"), "line-secret", ErrInvalidIDToken, false},
+		{"google issuer", googleIssuers, with("iss", "https://accounts.google.com"), "line-secret", nil, false},
+		{"google issuer without scheme", googleIssuers, with("iss", "accounts.google.com"), "line-secret", nil, false},
+		{"other issuer for google", googleIssuers, valid(), "line-secret", ErrInvalidIDToken, false},
+		{"no issuer", line, with("iss", nil), "line-secret", ErrInvalidIDToken, false},
+		{"wrong audience", line, with("aud", "other-client"), "line-secret", ErrInvalidIDToken, false},
+		{"expired", line, with("exp", time.Now().Add(-time.Hour).Unix()), "line-secret", ErrInvalidIDToken, false},
+		{"no expiry", line, with("exp", nil), "line-secret", ErrInvalidIDToken, false},
+		{"wrong nonce", line, with("nonce", "replayed"), "line-secret", ErrInvalidIDToken, false},
+		{"no subject", line, with("sub", nil), "line-secret", ErrInvalidIDToken, false},
+	}
+
+	c := NewClient(testRedirectBase, NewMemoryStateStore())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte(tt.secret))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			identity, err := c.verifyIDToken(tt.provider, idToken, "nonce")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyIDToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && identity.EmailVerified != tt.wantVerified {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.wantVerified)
+			}
+		})
+	}
+}
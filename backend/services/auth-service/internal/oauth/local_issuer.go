@@ -0,0 +1,233 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+const localCodeTTL = time.Minute
+
+// LocalIssuer is a minimal OpenID Connect provider for running social login
+// offline. It signs in whoever asks: the authorize page takes an email (or a
+// login_hint) and redirects straight back with a code. It still enforces
+// PKCE, redirect URI and client checks so the real flow is exercised.
+type LocalIssuer struct {
+	issuer   string
+	clientID string
+	key      *rsa.PrivateKey
+	kid      string
+
+	mu       sync.Mutex
+	codes    map[string]localCode
+	subjects map[string]string // by lower-cased email
+}
+
+type localCode struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	email       string
+	expiresAt   time.Time
+}
+
+// NewLocalIssuer creates an issuer served at issuerURL, e.g.
+// http://localhost:8080/oauth-local
+func NewLocalIssuer(issuerURL, clientID string) (*LocalIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %v", err)
+	}
+
+	return &LocalIssuer{
+		issuer:   strings.TrimSuffix(issuerURL, "/"),
+		clientID: clientID,
+		key:      key,
+		kid:      uuid.NewString(),
+		codes:    make(map[string]localCode),
+		subjects: make(map[string]string),
+	}, nil
+}
+
+// Provider returns the configuration for signing in through the issuer
+func (i *LocalIssuer) Provider() Provider {
+	return Provider{
+		Name:     "local",
+		ClientID: i.clientID,
+		Issuer:   i.issuer,
+		AuthURL:  i.issuer + "/authorize",
+		TokenURL: i.issuer + "/token",
+		JWKSURL:  i.issuer + "/jwks",
+		Scopes:   []string{"openid", "email", "profile"},
+	}
+}
+
+var localAuthorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html><body>
+<h1>Local sign-in</h1>
+<p>Development identity provider. Any email is accepted.</p>
+<form method="get">
+{{range $name, $values := .}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">{{end}}{{end}}
+<input type="email" name="login_hint" placeholder="email" required>
+<button type="submit">Sign in</button>
+</form>
+</body></html>`))
+
+// Authorize shows the sign-in form, or issues a code when login_hint is set
+func (i *LocalIssuer) Authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") != i.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "the code flow with an S256 code_challenge is required", http.StatusBadRequest)
+		return
+	}
+
+	email := strings.TrimSpace(q.Get("login_hint"))
+	if email == "" {
+		delete(q, "login_hint")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		localAuthorizePage.Execute(w, q)
+		return
+	}
+
+	code := uuid.NewString()
+	i.mu.Lock()
+	now := time.Now()
+	for c, lc := range i.codes {
+		if lc.expiresAt.Before(now) {
+			delete(i.codes, c)
+		}
+	}
+	i.codes[code] = localCode{
+		clientID:    q.Get("client_id"),
+		redirectURI: redirectURI.String(),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		email:       email,
+		expiresAt:   now.Add(localCodeTTL),
+	}
+	i.mu.Unlock()
+
+	log.Printf("local OIDC issuer: signed in %s", email)
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// Token redeems a code for an ID token after checking the PKCE verifier
+func (i *LocalIssuer) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	i.mu.Lock()
+	lc, found := i.codes[r.PostForm.Get("code")]
+	delete(i.codes, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !found, lc.expiresAt.Before(time.Now()),
+		lc.clientID != r.PostForm.Get("client_id"),
+		lc.redirectURI != r.PostForm.Get("redirect_uri"),
+		lc.challenge != base64.RawURLEncoding.EncodeToString(verifier[:]):
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	idToken, err := i.idToken(lc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": uuid.NewString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// JWKS serves the issuer's public key
+func (i *LocalIssuer) JWKS(w http.ResponseWriter, r *http.Request) {
+	key, err := jwk.FromRaw(&i.key.PublicKey)
+	if err == nil {
+		err = key.Set(jwk.KeyIDKey, i.kid)
+	}
+	if err == nil {
+		err = key.Set(jwk.AlgorithmKey, "RS256")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	set := jwk.NewSet()
+	set.AddKey(key)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(set)
+}
+
+func (i *LocalIssuer) idToken(lc localCode) (string, error) {
+	i.mu.Lock()
+	email := strings.ToLower(lc.email)
+	subject, found := i.subjects[email]
+	if !found {
+		subject = uuid.NewString()
+		i.subjects[email] = subject
+	}
+	i.mu.Unlock()
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            i.issuer,
+		"sub":            subject,
+		"aud":            lc.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          lc.nonce,
+		"email":          lc.email,
+		"email_verified": true,
+		"name":           strings.Split(lc.email, "@")[0],
+	})
+	token.Header["kid"] = i.kid
+
+	return token.SignedString(i.key)
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
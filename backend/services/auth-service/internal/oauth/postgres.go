@@ -0,0 +1,52 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresLinkStore keeps linked identities in the oauth_identity_links
+// table, so a returning social login finds its account on every instance
+// and after restarts
+type PostgresLinkStore struct {
+	db *sql.DB
+}
+
+func NewPostgresLinkStore(databaseURL string) (*PostgresLinkStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	return &PostgresLinkStore{db: db}, nil
+}
+
+func (s *PostgresLinkStore) Find(ctx context.Context, provider, subject string) (string, error) {
+	var username string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT username FROM oauth_identity_links
+		WHERE provider = $1 AND subject = $2`, provider, subject).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrLinkNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read identity link: %v", err)
+	}
+	return username, nil
+}
+
+func (s *PostgresLinkStore) Link(ctx context.Context, provider, subject, username string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO oauth_identity_links (provider, subject, username)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider, subject) DO UPDATE SET username = EXCLUDED.username`,
+		provider, subject, username)
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package oauth
+
+// Provider is an OpenID Connect identity provider users can sign in with
+type Provider struct {
+	// Name is the path segment in /auth/oauth/{name}/...
+	Name         string
+	ClientID     string
+	ClientSecret string
+
+	Issuer   string
+	AuthURL  string
+	TokenURL string
+	JWKSURL  string
+	Scopes   []string
+
+	// OtherIssuers are also accepted in the iss claim of ID tokens
+	OtherIssuers []string
+
+	// SecretSignedIDTokens accepts HS256 ID tokens signed with the client
+	// secret, which is how LINE signs web login tokens
+	SecretSignedIDTokens bool
+	// TrustEmail treats the email claim as verified for providers that only
+	// release verified addresses and send no email_verified claim
+	TrustEmail bool
+	// FormPost asks for the callback as a form POST, which Apple requires
+	// when the name or email scope is requested
+	FormPost bool
+}
+
+// trustsIssuer reports whether an ID token's iss claim names the provider
+func (p Provider) trustsIssuer(issuer string) bool {
+	if issuer == p.Issuer {
+		return true
+	}
+	for _, other := range p.OtherIssuers {
+		if issuer == other {
+			return true
+		}
+	}
+	return false
+}
+
+// Identity is the user a provider vouched for
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Google is Sign in with Google
+func Google(clientID, clientSecret string) Provider {
+	return Provider{
+		Name:         "google",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Issuer:       "https://accounts.google.com",
+		// Google's ID tokens may carry the issuer without the scheme
+		OtherIssuers: []string{"accounts.google.com"},
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		JWKSURL:      "https://www.googleapis.com/oauth2/v3/certs",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// Apple is Sign in with Apple. Its client secret is an ES256 JWT generated
+// from the team's private key; it is valid for up to six months.
+func Apple(clientID, clientSecret string) Provider {
+	return Provider{
+		Name:         "apple",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Issuer:       "https://appleid.apple.com",
+		AuthURL:      "https://appleid.apple.com/auth/authorize",
+		TokenURL:     "https://appleid.apple.com/auth/token",
+		JWKSURL:      "https://appleid.apple.com/auth/keys",
+		Scopes:       []string{"openid", "email", "name"},
+		FormPost:     true,
+	}
+}
+
+// LINE is LINE Login v2.1. The email claim requires the channel's email
+// permission, and LINE only returns addresses it has verified.
+func LINE(clientID, clientSecret string) Provider {
+	return Provider{
+		Name:                 "line",
+		ClientID:             clientID,
+		ClientSecret:         clientSecret,
+		Issuer:               "https://access.line.me",
+		AuthURL:              "https://access.line.me/oauth2/v2.1/authorize",
+		TokenURL:             "https://api.line.me/oauth2/v2.1/token",
+		JWKSURL:              "https://api.line.me/oauth2/v2.1/certs",
+		Scopes:               []string{"openid", "profile", "email"},
+		SecretSignedIDTokens: true,
+		TrustEmail:           true,
+	}
+}
@@ -0,0 +1,79 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisStatePrefix = "auth:oauth:state:"
+
+// RedisStateStore keeps pending authorization requests in Redis, so the
+// callback can land on a different instance than the one that started it
+type RedisStateStore struct {
+	client *redis.Client
+}
+
+// NewRedisStateStore connects to the Redis server at url (redis://...)
+func NewRedisStateStore(url string) (*RedisStateStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %v", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return &RedisStateStore{client: client}, nil
+}
+
+func (s *RedisStateStore) Save(ctx context.Context, req AuthRequest) error {
+	ttl := time.Until(req.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(ctx, redisStatePrefix+req.State, data, ttl).Err()
+}
+
+func (s *RedisStateStore) Take(ctx context.Context, state string) (*AuthRequest, error) {
+	// GETDEL keeps the state single use across instances
+	data, err := s.client.GetDel(ctx, redisStatePrefix+state).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var req AuthRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode oauth state: %v", err)
+	}
+
+	if req.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidState
+	}
+
+	return &req, nil
+}
+
+func (s *RedisStateStore) Close() error {
+	return s.client.Close()
+}
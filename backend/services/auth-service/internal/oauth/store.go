@@ -0,0 +1,111 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidState = errors.New("oauth state is unknown, used or expired")
+	ErrLinkNotFound = errors.New("no account is linked to this identity")
+)
+
+// AuthRequest is an authorization request waiting for its callback
+type AuthRequest struct {
+	State     string
+	Provider  string
+	Verifier  string
+	Nonce     string
+	ExpiresAt time.Time
+}
+
+// StateStore keeps pending authorization requests keyed by state. Requests
+// are single use: Take removes the request it returns.
+type StateStore interface {
+	Save(ctx context.Context, req AuthRequest) error
+	Take(ctx context.Context, state string) (*AuthRequest, error)
+}
+
+// LinkStore maps provider identities to the user they were linked to
+type LinkStore interface {
+	Find(ctx context.Context, provider, subject string) (string, error)
+	Link(ctx context.Context, provider, subject, username string) error
+}
+
+// MemoryStateStore is an in-process StateStore
+type MemoryStateStore struct {
+	mu       sync.Mutex
+	requests map[string]AuthRequest
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		requests: make(map[string]AuthRequest),
+	}
+}
+
+func (s *MemoryStateStore) Save(ctx context.Context, req AuthRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop abandoned requests so they don't accumulate
+	now := time.Now()
+	for state, r := range s.requests {
+		if r.ExpiresAt.Before(now) {
+			delete(s.requests, state)
+		}
+	}
+
+	s.requests[req.State] = req
+	return nil
+}
+
+func (s *MemoryStateStore) Take(ctx context.Context, state string) (*AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.requests[state]
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	delete(s.requests, state)
+
+	if req.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidState
+	}
+
+	return &req, nil
+}
+
+// MemoryLinkStore is an in-process LinkStore
+type MemoryLinkStore struct {
+	mu    sync.RWMutex
+	links map[string]string
+}
+
+func NewMemoryLinkStore() *MemoryLinkStore {
+	return &MemoryLinkStore{
+		links: make(map[string]string),
+	}
+}
+
+func (s *MemoryLinkStore) Find(ctx context.Context, provider, subject string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	username, ok := s.links[provider+"|"+subject]
+	if !ok {
+		return "", ErrLinkNotFound
+	}
+	return username, nil
+}
+
+func (s *MemoryLinkStore) Link(ctx context.Context, provider, subject, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.links[provider+"|"+subject] = username
+	return nil
+}
@@ -0,0 +1,122 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisStateStore(t *testing.T) (*RedisStateStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	s, err := NewRedisStateStore("redis://" + server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisStateStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, server
+}
+
+func TestStateStores(t *testing.T) {
+	tests := []struct {
+		name    string
+		saved   AuthRequest
+		take    []string
+		wantErr []error
+	}{
+		{"single use", AuthRequest{State: "s1", ExpiresAt: time.Now().Add(time.Minute)}, []string{"s1", "s1"}, []error{nil, ErrInvalidState}},
+		{"unknown state", AuthRequest{State: "s1", ExpiresAt: time.Now().Add(time.Minute)}, []string{"s2", "s1"}, []error{ErrInvalidState, nil}},
+		{"expired", AuthRequest{State: "s1", ExpiresAt: time.Now().Add(-time.Second)}, []string{"s1"}, []error{ErrInvalidState}},
+	}
+
+	backends := map[string]func(t *testing.T) StateStore{
+		"memory": func(t *testing.T) StateStore { return NewMemoryStateStore() },
+		"redis": func(t *testing.T) StateStore {
+			s, _ := newTestRedisStateStore(t)
+			return s
+		},
+	}
+
+	for backend, newStore := range backends {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				s := newStore(t)
+				ctx := context.Background()
+
+				saved := tt.saved
+				saved.Provider, saved.Verifier, saved.Nonce = "google", "verifier", "nonce"
+				if err := s.Save(ctx, saved); err != nil {
+					t.Fatalf("Save: %v", err)
+				}
+
+				for i, state := range tt.take {
+					req, err := s.Take(ctx, state)
+					if !errors.Is(err, tt.wantErr[i]) {
+						t.Fatalf("Take(%s) #%d error = %v, want %v", state, i+1, err, tt.wantErr[i])
+					}
+					if err == nil && (req.Provider != "google" || req.Verifier != "verifier" || req.Nonce != "nonce") {
+						t.Errorf("Take(%s) = %+v, want the saved request", state, req)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRedisStateStoreTTL(t *testing.T) {
+	s, server := newTestRedisStateStore(t)
+	ctx := context.Background()
+
+	if err := s.Save(ctx, AuthRequest{State: "s1", ExpiresAt: time.Now().Add(stateTTL)}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL(redisStatePrefix + "s1"); ttl <= 0 || ttl > stateTTL {
+		t.Errorf("TTL = %v, want up to %v", ttl, stateTTL)
+	}
+
+	server.FastForward(stateTTL + time.Second)
+	if _, err := s.Take(ctx, "s1"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Take() after the TTL error = %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestMemoryLinkStore(t *testing.T) {
+	s := NewMemoryLinkStore()
+	ctx := context.Background()
+
+	if err := s.Link(ctx, "google", "sub-1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Link(ctx, "google", "sub-2", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	// Relinking an identity moves it to the new user
+	if err := s.Link(ctx, "google", "sub-2", "carol"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		provider string
+		subject  string
+		want     string
+		wantErr  error
+	}{
+		{"google", "sub-1", "alice", nil},
+		{"google", "sub-2", "carol", nil},
+		{"apple", "sub-1", "", ErrLinkNotFound},
+		{"google", "sub-3", "", ErrLinkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.subject, func(t *testing.T) {
+			got, err := s.Find(ctx, tt.provider, tt.subject)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Find() = %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
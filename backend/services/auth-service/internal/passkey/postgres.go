@@ -0,0 +1,115 @@
+package passkey
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	_ "github.com/lib/pq"
+)
+
+// PostgresCredentialStore keeps credentials in the passkey_credentials
+// table, so they survive restarts and every instance sees them. Each row
+// holds the credential as JSON, keyed by its ID.
+type PostgresCredentialStore struct {
+	db *sql.DB
+}
+
+func NewPostgresCredentialStore(databaseURL string) (*PostgresCredentialStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	return &PostgresCredentialStore{db: db}, nil
+}
+
+func (s *PostgresCredentialStore) Add(ctx context.Context, userID string, credential webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO passkey_credentials (id, user_id, credential)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING`,
+		credential.ID, userID, data)
+	if err != nil {
+		return fmt.Errorf("failed to store passkey credential: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrCredentialExists
+	}
+	return nil
+}
+
+func (s *PostgresCredentialStore) ListByUser(ctx context.Context, userID string) ([]webauthn.Credential, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT credential FROM passkey_credentials
+		WHERE user_id = $1
+		ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkey credentials: %v", err)
+	}
+	defer rows.Close()
+
+	creds := []webauthn.Credential{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var credential webauthn.Credential
+		if err := json.Unmarshal(data, &credential); err != nil {
+			return nil, fmt.Errorf("failed to decode passkey credential: %v", err)
+		}
+		creds = append(creds, credential)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list passkey credentials: %v", err)
+	}
+
+	return creds, nil
+}
+
+func (s *PostgresCredentialStore) FindByID(ctx context.Context, credentialID []byte) (string, *webauthn.Credential, error) {
+	var userID string
+	var data []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id, credential FROM passkey_credentials WHERE id = $1`, credentialID).Scan(&userID, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read passkey credential: %v", err)
+	}
+
+	var credential webauthn.Credential
+	if err := json.Unmarshal(data, &credential); err != nil {
+		return "", nil, fmt.Errorf("failed to decode passkey credential: %v", err)
+	}
+	return userID, &credential, nil
+}
+
+func (s *PostgresCredentialStore) Update(ctx context.Context, userID string, credential webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE passkey_credentials SET credential = $3
+		WHERE id = $1 AND user_id = $2`,
+		credential.ID, userID, data)
+	if err != nil {
+		return fmt.Errorf("failed to update passkey credential: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
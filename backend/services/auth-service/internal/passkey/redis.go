@@ -0,0 +1,87 @@
+package passkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisSessionPrefix = "auth:passkey:session:"
+	// defaultSessionTTL bounds sessions saved without an expiry
+	defaultSessionTTL = 5 * time.Minute
+)
+
+// RedisSessionStore keeps ceremony sessions in Redis, so a ceremony can
+// begin and complete on different instances of the service
+type RedisSessionStore struct {
+	client *redis.Client
+}
+
+// NewRedisSessionStore connects to the Redis server at url (redis://...)
+func NewRedisSessionStore(url string) (*RedisSessionStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %v", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return &RedisSessionStore{client: client}, nil
+}
+
+func (s *RedisSessionStore) Save(ctx context.Context, session webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	ttl := defaultSessionTTL
+	if !session.Expires.IsZero() {
+		ttl = time.Until(session.Expires)
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.client.Set(ctx, redisSessionPrefix+session.Challenge, data, ttl).Err()
+}
+
+func (s *RedisSessionStore) Take(ctx context.Context, challenge string) (*webauthn.SessionData, error) {
+	// GETDEL makes the session single use across instances
+	data, err := s.client.GetDel(ctx, redisSessionPrefix+challenge).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode passkey session: %v", err)
+	}
+
+	if !session.Expires.IsZero() && session.Expires.Before(time.Now()) {
+		return nil, ErrSessionNotFound
+	}
+
+	return &session, nil
+}
+
+func (s *RedisSessionStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,228 @@
+package passkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ec-recommend/auth-service/internal/proof"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// ErrCloneDetected is returned when an assertion's signature counter did not
+// increase, which indicates the authenticator may have been cloned
+var ErrCloneDetected = errors.New("passkey signature counter did not increase")
+
+// ErrMissingUserID is returned when a registration names no user
+var ErrMissingUserID = errors.New("passkey user ID is required")
+
+type Config struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+	Timeout       time.Duration
+	// ProofSecret signs the proof handed to Cognito's custom auth challenge
+	ProofSecret []byte
+}
+
+// Service is a WebAuthn relying party backed by pluggable credential and
+// session stores
+type Service struct {
+	webAuthn    *webauthn.WebAuthn
+	credentials CredentialStore
+	sessions    SessionStore
+	proofSecret []byte
+}
+
+// user adapts a stored user to the webauthn.User interface
+type user struct {
+	id          string
+	name        string
+	credentials []webauthn.Credential
+}
+
+func (u *user) WebAuthnID() []byte                         { return []byte(u.id) }
+func (u *user) WebAuthnName() string                       { return u.name }
+func (u *user) WebAuthnDisplayName() string                { return u.name }
+func (u *user) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+func (u *user) WebAuthnIcon() string                       { return "" }
+
+func NewService(cfg Config, credentials CredentialStore, sessions SessionStore) (*Service, error) {
+	if len(cfg.ProofSecret) == 0 {
+		return nil, fmt.Errorf("passkey proof secret is required")
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure WebAuthn: %v", err)
+	}
+
+	return &Service{
+		webAuthn:    wa,
+		credentials: credentials,
+		sessions:    sessions,
+		proofSecret: cfg.ProofSecret,
+	}, nil
+}
+
+// BeginRegistration starts a registration ceremony for userID
+func (s *Service) BeginRegistration(ctx context.Context, userID, userName string) (*protocol.CredentialCreation, error) {
+	if userID == "" {
+		return nil, ErrMissingUserID
+	}
+
+	creds, err := s.credentials.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &user{id: userID, name: userName, credentials: creds}
+
+	// Keep the same authenticator from being registered twice
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(u, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin registration: %v", err)
+	}
+
+	if err := s.sessions.Save(ctx, *session); err != nil {
+		return nil, fmt.Errorf("failed to save session: %v", err)
+	}
+
+	return creation, nil
+}
+
+// FinishRegistration verifies an attestation response and stores the new
+// credential for userID
+func (s *Service) FinishRegistration(ctx context.Context, userID string, response []byte) (*webauthn.Credential, error) {
+	if userID == "" {
+		return nil, ErrMissingUserID
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse attestation: %v", err)
+	}
+
+	session, err := s.sessions.Take(ctx, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(session.UserID, []byte(userID)) {
+		return nil, ErrSessionNotFound
+	}
+
+	creds, err := s.credentials.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(&user{id: userID, credentials: creds}, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify attestation: %v", describe(err))
+	}
+
+	if err := s.credentials.Add(ctx, userID, *credential); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// BeginLogin starts a discoverable (usernameless) authentication ceremony
+func (s *Service) BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin login: %v", err)
+	}
+
+	if err := s.sessions.Save(ctx, *session); err != nil {
+		return nil, fmt.Errorf("failed to save session: %v", err)
+	}
+
+	return assertion, nil
+}
+
+// FinishLogin verifies an assertion response and returns the ID of the user
+// owning the credential
+func (s *Service) FinishLogin(ctx context.Context, response []byte) (string, *webauthn.Credential, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse assertion: %v", err)
+	}
+
+	session, err := s.sessions.Take(ctx, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return "", nil, err
+	}
+
+	userID, _, err := s.credentials.FindByID(ctx, parsed.RawID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	creds, err := s.credentials.ListByUser(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		return &user{id: userID, credentials: creds}, nil
+	}
+
+	credential, err := s.webAuthn.ValidateDiscoverableLogin(handler, *session, parsed)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to verify assertion: %v", describe(err))
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return userID, nil, ErrCloneDetected
+	}
+
+	if err := s.credentials.Update(ctx, userID, *credential); err != nil {
+		return "", nil, err
+	}
+
+	return userID, credential, nil
+}
+
+// Proof returns a short-lived token asserting that userID completed a passkey
+// ceremony with credentialID. It is passed as the ANSWER to Cognito's custom
+// auth challenge, whose verify trigger recomputes it with the shared secret.
+func (s *Service) Proof(userID string, credentialID []byte) string {
+	return proof.Sign(s.proofSecret, userID, credentialID)
+}
+
+// describe unwraps protocol errors, whose Error() omits the useful details
+func describe(err error) string {
+	var perr *protocol.Error
+	if errors.As(err, &perr) && perr.DevInfo != "" {
+		return perr.Details + ": " + perr.DevInfo
+	}
+	return err.Error()
+}
@@ -0,0 +1,126 @@
+package passkey
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func newTestService(t *testing.T) (*Service, *MemoryCredentialStore, *MemorySessionStore) {
+	t.Helper()
+
+	credentials, sessions := NewMemoryCredentialStore(), NewMemorySessionStore()
+	s, err := NewService(Config{
+		RPID:          "localhost",
+		RPDisplayName: "EC Recommend",
+		RPOrigins:     []string{"http://localhost:3000"},
+		ProofSecret:   []byte("test-secret"),
+	}, credentials, sessions)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s, credentials, sessions
+}
+
+func TestNewServiceRequiresProofSecret(t *testing.T) {
+	_, err := NewService(Config{RPID: "localhost", RPDisplayName: "EC Recommend", RPOrigins: []string{"http://localhost:3000"}},
+		NewMemoryCredentialStore(), NewMemorySessionStore())
+	if err == nil {
+		t.Error("NewService() without a proof secret succeeded")
+	}
+}
+
+func TestMissingUserID(t *testing.T) {
+	s, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := s.BeginRegistration(ctx, "", "alice@example.com"); !errors.Is(err, ErrMissingUserID) {
+		t.Errorf("BeginRegistration() error = %v, want %v", err, ErrMissingUserID)
+	}
+	if _, err := s.FinishRegistration(ctx, "", []byte("{}")); !errors.Is(err, ErrMissingUserID) {
+		t.Errorf("FinishRegistration() error = %v, want %v", err, ErrMissingUserID)
+	}
+}
+
+func TestBeginRegistration(t *testing.T) {
+	s, credentials, sessions := newTestService(t)
+	ctx := context.Background()
+
+	if err := credentials.Add(ctx, "alice", webauthn.Credential{ID: []byte("cred-1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	creation, err := s.BeginRegistration(ctx, "alice", "alice@example.com")
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	// The registered authenticator is excluded so it can't be added twice
+	excluded := creation.Response.CredentialExcludeList
+	if len(excluded) != 1 || !bytes.Equal(excluded[0].CredentialID, []byte("cred-1")) {
+		t.Errorf("CredentialExcludeList = %+v, want cred-1", excluded)
+	}
+
+	session, err := sessions.Take(ctx, creation.Response.Challenge.String())
+	if err != nil {
+		t.Fatalf("session was not saved under its challenge: %v", err)
+	}
+	if !bytes.Equal(session.UserID, []byte("alice")) {
+		t.Errorf("session UserID = %q, want alice", session.UserID)
+	}
+}
+
+func TestBeginLogin(t *testing.T) {
+	s, _, sessions := newTestService(t)
+	ctx := context.Background()
+
+	assertion, err := s.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	if len(assertion.Response.AllowedCredentials) != 0 {
+		t.Errorf("AllowedCredentials = %v, want a discoverable login", assertion.Response.AllowedCredentials)
+	}
+	if _, err := sessions.Take(ctx, assertion.Response.Challenge.String()); err != nil {
+		t.Errorf("session was not saved under its challenge: %v", err)
+	}
+}
+
+func TestProof(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	got := s.Proof("alice", []byte("cred-1"))
+	parts := strings.Split(got, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Proof() = %q, want <time>.<evidence>.<mac>", got)
+	}
+	if evidence, _ := base64.RawURLEncoding.DecodeString(parts[1]); string(evidence) != "cred-1" {
+		t.Errorf("evidence = %q, want cred-1", evidence)
+	}
+
+	// The verify trigger recomputes the MAC over the user and payload
+	tests := []struct {
+		userID string
+		secret string
+		want   bool
+	}{
+		{"alice", "test-secret", true},
+		{"bob", "test-secret", false},
+		{"alice", "other-secret", false},
+	}
+
+	for _, tt := range tests {
+		mac := hmac.New(sha256.New, []byte(tt.secret))
+		mac.Write([]byte(tt.userID + "." + parts[0] + "." + parts[1]))
+		if ok := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) == parts[2]; ok != tt.want {
+			t.Errorf("MAC for %s with %s matches = %v, want %v", tt.userID, tt.secret, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package passkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("passkey session not found or expired")
+	ErrCredentialNotFound = errors.New("passkey credential not found")
+	ErrCredentialExists   = errors.New("passkey credential already registered")
+)
+
+// CredentialStore persists WebAuthn credentials per user
+type CredentialStore interface {
+	Add(ctx context.Context, userID string, credential webauthn.Credential) error
+	ListByUser(ctx context.Context, userID string) ([]webauthn.Credential, error)
+	FindByID(ctx context.Context, credentialID []byte) (string, *webauthn.Credential, error)
+	Update(ctx context.Context, userID string, credential webauthn.Credential) error
+}
+
+// SessionStore keeps ceremony session data keyed by challenge.
+// Sessions are single use: Take removes the session it returns.
+type SessionStore interface {
+	Save(ctx context.Context, session webauthn.SessionData) error
+	Take(ctx context.Context, challenge string) (*webauthn.SessionData, error)
+}
+
+// MemoryCredentialStore is an in-process CredentialStore
+type MemoryCredentialStore struct {
+	mu          sync.RWMutex
+	credentials map[string][]webauthn.Credential
+}
+
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{
+		credentials: make(map[string][]webauthn.Credential),
+	}
+}
+
+func (s *MemoryCredentialStore) Add(ctx context.Context, userID string, credential webauthn.Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, creds := range s.credentials {
+		for _, c := range creds {
+			if bytes.Equal(c.ID, credential.ID) {
+				return ErrCredentialExists
+			}
+		}
+	}
+
+	s.credentials[userID] = append(s.credentials[userID], credential)
+	return nil
+}
+
+func (s *MemoryCredentialStore) ListByUser(ctx context.Context, userID string) ([]webauthn.Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	creds := make([]webauthn.Credential, len(s.credentials[userID]))
+	copy(creds, s.credentials[userID])
+	return creds, nil
+}
+
+func (s *MemoryCredentialStore) FindByID(ctx context.Context, credentialID []byte) (string, *webauthn.Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for userID, creds := range s.credentials {
+		for _, c := range creds {
+			if bytes.Equal(c.ID, credentialID) {
+				cred := c
+				return userID, &cred, nil
+			}
+		}
+	}
+
+	return "", nil, ErrCredentialNotFound
+}
+
+func (s *MemoryCredentialStore) Update(ctx context.Context, userID string, credential webauthn.Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creds := s.credentials[userID]
+	for i := range creds {
+		if bytes.Equal(creds[i].ID, credential.ID) {
+			creds[i] = credential
+			return nil
+		}
+	}
+
+	return ErrCredentialNotFound
+}
+
+// MemorySessionStore is an in-process SessionStore
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]webauthn.SessionData
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]webauthn.SessionData),
+	}
+}
+
+func (s *MemorySessionStore) Save(ctx context.Context, session webauthn.SessionData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired sessions so abandoned ceremonies don't accumulate
+	now := time.Now()
+	for challenge, sess := range s.sessions {
+		if !sess.Expires.IsZero() && sess.Expires.Before(now) {
+			delete(s.sessions, challenge)
+		}
+	}
+
+	s.sessions[session.Challenge] = session
+	return nil
+}
+
+func (s *MemorySessionStore) Take(ctx context.Context, challenge string) (*webauthn.SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[challenge]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	delete(s.sessions, challenge)
+
+	if !session.Expires.IsZero() && session.Expires.Before(time.Now()) {
+		return nil, ErrSessionNotFound
+	}
+
+	return &session, nil
+}
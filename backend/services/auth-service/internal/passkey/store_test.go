@@ -0,0 +1,167 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func newTestRedisSessionStore(t *testing.T) (*RedisSessionStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	s, err := NewRedisSessionStore("redis://" + server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisSessionStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, server
+}
+
+func TestSessionStores(t *testing.T) {
+	tests := []struct {
+		name    string
+		saved   []webauthn.SessionData
+		take    []string
+		wantErr []error
+	}{
+		{
+			"single use",
+			[]webauthn.SessionData{{Challenge: "c1", Expires: time.Now().Add(time.Minute)}},
+			[]string{"c1", "c1"},
+			[]error{nil, ErrSessionNotFound},
+		},
+		{
+			"unknown challenge",
+			[]webauthn.SessionData{{Challenge: "c1", Expires: time.Now().Add(time.Minute)}},
+			[]string{"c2", "c1"},
+			[]error{ErrSessionNotFound, nil},
+		},
+		{
+			"no expiry",
+			[]webauthn.SessionData{{Challenge: "c1"}},
+			[]string{"c1"},
+			[]error{nil},
+		},
+		{
+			"expired",
+			[]webauthn.SessionData{{Challenge: "c1", Expires: time.Now().Add(-time.Second)}},
+			[]string{"c1"},
+			[]error{ErrSessionNotFound},
+		},
+	}
+
+	backends := map[string]func(t *testing.T) SessionStore{
+		"memory": func(t *testing.T) SessionStore { return NewMemorySessionStore() },
+		"redis": func(t *testing.T) SessionStore {
+			s, _ := newTestRedisSessionStore(t)
+			return s
+		},
+	}
+
+	for backend, newStore := range backends {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				s := newStore(t)
+				ctx := context.Background()
+
+				for _, session := range tt.saved {
+					if err := s.Save(ctx, session); err != nil {
+						t.Fatalf("Save: %v", err)
+					}
+				}
+				for i, challenge := range tt.take {
+					session, err := s.Take(ctx, challenge)
+					if !errors.Is(err, tt.wantErr[i]) {
+						t.Fatalf("Take(%s) #%d error = %v, want %v", challenge, i+1, err, tt.wantErr[i])
+					}
+					if err == nil && session.Challenge != challenge {
+						t.Errorf("Take(%s) = %+v", challenge, session)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRedisSessionStoreTTL(t *testing.T) {
+	s, server := newTestRedisSessionStore(t)
+	ctx := context.Background()
+
+	if err := s.Save(ctx, webauthn.SessionData{Challenge: "timed", Expires: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, webauthn.SessionData{Challenge: "untimed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl := server.TTL(redisSessionPrefix + "timed"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL = %v, want up to the session's expiry", ttl)
+	}
+	if ttl := server.TTL(redisSessionPrefix + "untimed"); ttl != defaultSessionTTL {
+		t.Errorf("TTL without an expiry = %v, want %v", ttl, defaultSessionTTL)
+	}
+
+	// Expiry in Redis is enough to end the ceremony
+	server.FastForward(2 * time.Minute)
+	if _, err := s.Take(ctx, "timed"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Take() after the TTL error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestMemoryCredentialStore(t *testing.T) {
+	s := NewMemoryCredentialStore()
+	ctx := context.Background()
+
+	if err := s.Add(ctx, "alice", webauthn.Credential{ID: []byte("cred-1")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(ctx, "alice", webauthn.Credential{ID: []byte("cred-2")}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"add a credential another user has", func() error {
+			return s.Add(ctx, "bob", webauthn.Credential{ID: []byte("cred-1")})
+		}, ErrCredentialExists},
+		{"find unknown credential", func() error {
+			_, _, err := s.FindByID(ctx, []byte("cred-9"))
+			return err
+		}, ErrCredentialNotFound},
+		{"update another user's credential", func() error {
+			return s.Update(ctx, "bob", webauthn.Credential{ID: []byte("cred-1")})
+		}, ErrCredentialNotFound},
+		{"update", func() error {
+			cred := webauthn.Credential{ID: []byte("cred-2")}
+			cred.Authenticator.SignCount = 7
+			return s.Update(ctx, "alice", cred)
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	userID, cred, err := s.FindByID(ctx, []byte("cred-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != "alice" || cred.Authenticator.SignCount != 7 {
+		t.Errorf("FindByID() = %s, %+v; want alice's updated credential", userID, cred)
+	}
+	if creds, _ := s.ListByUser(ctx, "bob"); len(creds) != 0 {
+		t.Errorf("ListByUser(bob) = %v, want none", creds)
+	}
+}
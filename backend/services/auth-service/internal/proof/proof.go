@@ -0,0 +1,78 @@
+// Package proof signs the answers this service gives to Cognito's custom
+// auth challenge after verifying a user some other way (a passkey, a social
+// login), and verifies them.
+//
+// The pool's verify auth challenge trigger must run the same check as Verify
+// with the shared PASSKEY_PROOF_SECRET: split the answer on ".", recompute
+// HMAC-SHA256 over "<username>.<unix time>.<evidence>", compare it in
+// constant time with the decoded MAC, and reject proofs older than MaxAge.
+package proof
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxAge is how long a proof is accepted after it is signed. It only has to
+// cover the round trip to the identity provider.
+const MaxAge = 5 * time.Minute
+
+// maxSkew tolerates clocks that run slightly behind the signer's
+const maxSkew = 30 * time.Second
+
+var (
+	ErrInvalid = errors.New("invalid proof")
+	ErrExpired = errors.New("proof has expired")
+)
+
+// Sign returns a short-lived proof that userID was verified with evidence,
+// such as a passkey credential ID.
+// Format: <unix time>.<evidence>.<HMAC-SHA256 over userID.payload>, with
+// evidence and MAC base64url without padding.
+func Sign(secret []byte, userID string, evidence []byte) string {
+	payload := strconv.FormatInt(time.Now().Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(evidence)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(secret, userID, payload))
+}
+
+// Verify checks that answer was signed for userID with secret no more than
+// maxAge ago, and returns the evidence it carries.
+func Verify(secret []byte, userID, answer string, maxAge time.Duration) ([]byte, error) {
+	parts := strings.Split(answer, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if !hmac.Equal(mac, sign(secret, userID, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalid
+	}
+
+	signedAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	age := time.Since(time.Unix(signedAt, 0))
+	if age > maxAge || age < -maxSkew {
+		return nil, ErrExpired
+	}
+
+	evidence, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return evidence, nil
+}
+
+func sign(secret []byte, userID, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(userID + "." + payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,58 @@
+package proof
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedAt returns a proof for alice as if Sign had run at t
+func signedAt(secret []byte, t time.Time) string {
+	payload := strconv.FormatInt(t.Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString([]byte("cred-1"))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("alice." + payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("test-secret")
+	valid := Sign(secret, "alice", []byte("cred-1"))
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		userID  string
+		answer  string
+		wantErr error
+	}{
+		{"valid", secret, "alice", valid, nil},
+		{"other user", secret, "bob", valid, ErrInvalid},
+		{"other secret", []byte("other-secret"), "alice", valid, ErrInvalid},
+		{"tampered evidence", secret, "alice", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte("cred-2")) + "." + parts[2], ErrInvalid},
+		{"tampered time", secret, "alice", strconv.FormatInt(time.Now().Unix()+1, 10) + "." + parts[1] + "." + parts[2], ErrInvalid},
+		{"truncated MAC", secret, "alice", parts[0] + "." + parts[1] + "." + parts[2][:10], ErrInvalid},
+		{"malformed", secret, "alice", "not-a-proof", ErrInvalid},
+		{"empty", secret, "alice", "", ErrInvalid},
+		{"expired", secret, "alice", signedAt(secret, time.Now().Add(-MaxAge-time.Minute)), ErrExpired},
+		{"from the future", secret, "alice", signedAt(secret, time.Now().Add(time.Hour)), ErrExpired},
+		{"slight clock skew", secret, "alice", signedAt(secret, time.Now().Add(10*time.Second)), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evidence, err := Verify(tt.secret, tt.userID, tt.answer, MaxAge)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && string(evidence) != "cred-1" {
+				t.Errorf("Verify() evidence = %q, want %q", evidence, "cred-1")
+			}
+		})
+	}
+}
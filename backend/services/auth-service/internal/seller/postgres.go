@@ -0,0 +1,183 @@
+package seller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// sellerColumns are selected in the order scanSeller reads them
+const sellerColumns = `
+	id, COALESCE(cognito_user_id, ''), company_name, COALESCE(company_name_kana, ''),
+	email, phone_number, postal_code, address, COALESCE(business_license_number, ''),
+	status, COALESCE(status_reason, ''), approved_at, created_at, updated_at`
+
+// sortColumns maps ListOptions.SortBy to columns
+var sortColumns = map[string]string{
+	"":            "created_at",
+	"createdAt":   "created_at",
+	"updatedAt":   "updated_at",
+	"companyName": "company_name",
+}
+
+// PostgresStore keeps sellers in the platform's sellers table
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	return &PostgresStore{db: db}, nil
+}
+
+func (p *PostgresStore) Apply(ctx context.Context, s Seller) (*Seller, error) {
+	// A rejected application is replaced in place; any other existing row
+	// makes the upsert return nothing
+	row := p.db.QueryRowContext(ctx, `
+		INSERT INTO sellers (cognito_user_id, company_name, company_name_kana, email,
+			phone_number, postal_code, address, business_license_number, status)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, NULLIF($8, ''), 'pending')
+		ON CONFLICT (cognito_user_id) DO UPDATE SET
+			company_name = EXCLUDED.company_name,
+			company_name_kana = EXCLUDED.company_name_kana,
+			email = EXCLUDED.email,
+			phone_number = EXCLUDED.phone_number,
+			postal_code = EXCLUDED.postal_code,
+			address = EXCLUDED.address,
+			business_license_number = EXCLUDED.business_license_number,
+			status = 'pending',
+			status_reason = NULL,
+			approved_at = NULL,
+			approved_by = NULL
+		WHERE sellers.status = 'rejected'
+		RETURNING `+sellerColumns,
+		s.UserID, s.CompanyName, s.CompanyNameKana, s.Email,
+		s.PhoneNumber, s.PostalCode, s.Address, s.BusinessLicenseNumber)
+
+	created, err := scanSeller(row)
+	if errors.Is(err, ErrSellerNotFound) {
+		return nil, ErrApplicationExists
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrDuplicateSeller
+	}
+	return created, err
+}
+
+func (p *PostgresStore) Get(ctx context.Context, id string) (*Seller, error) {
+	return scanSeller(p.db.QueryRowContext(ctx,
+		`SELECT `+sellerColumns+` FROM sellers WHERE id = $1`, id))
+}
+
+func (p *PostgresStore) FindByUser(ctx context.Context, userID string) (*Seller, error) {
+	return scanSeller(p.db.QueryRowContext(ctx,
+		`SELECT `+sellerColumns+` FROM sellers WHERE cognito_user_id = $1`, userID))
+}
+
+func (p *PostgresStore) List(ctx context.Context, opts ListOptions) ([]Seller, int, error) {
+	column, ok := sortColumns[opts.SortBy]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort %q", opts.SortBy)
+	}
+	order := "ASC"
+	if opts.Descending {
+		order = "DESC"
+	}
+
+	var total int
+	err := p.db.QueryRowContext(ctx,
+		`SELECT count(*) FROM sellers WHERE $1 = '' OR status::text = $1`, string(opts.Status)).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count sellers: %v", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT `+sellerColumns+` FROM sellers
+		WHERE $1 = '' OR status::text = $1
+		ORDER BY `+column+` `+order+`, id
+		OFFSET $2 LIMIT $3`,
+		string(opts.Status), opts.Offset, opts.Limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sellers: %v", err)
+	}
+	defer rows.Close()
+
+	sellers := []Seller{}
+	for rows.Next() {
+		s, err := scanSeller(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		sellers = append(sellers, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list sellers: %v", err)
+	}
+
+	return sellers, total, nil
+}
+
+func (p *PostgresStore) SetStatus(ctx context.Context, id string, status Status, reason, actor string) (*Seller, error) {
+	from := make([]string, 0, 3)
+	for _, s := range allowedFrom(status) {
+		from = append(from, string(s))
+	}
+
+	// approved_by references users, so it is only set when the admin has a
+	// users row of their own
+	row := p.db.QueryRowContext(ctx, `
+		UPDATE sellers SET
+			status = $2,
+			status_reason = NULLIF($3, ''),
+			approved_at = CASE WHEN $2 = 'active' AND status <> 'active' THEN CURRENT_TIMESTAMP ELSE approved_at END,
+			approved_by = CASE WHEN $2 = 'active' AND status <> 'active'
+				THEN (SELECT id FROM users WHERE cognito_user_id = $4) ELSE approved_by END
+		WHERE id = $1 AND status::text = ANY($5)
+		RETURNING `+sellerColumns,
+		id, string(status), reason, actor, pq.Array(from))
+
+	updated, err := scanSeller(row)
+	if !errors.Is(err, ErrSellerNotFound) {
+		return updated, err
+	}
+
+	// Nothing updated: tell a missing seller from a disallowed change
+	if _, err := p.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, ErrInvalidTransition
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSeller(row rowScanner) (*Seller, error) {
+	var s Seller
+	var status string
+	var approvedAt sql.NullTime
+
+	err := row.Scan(&s.ID, &s.UserID, &s.CompanyName, &s.CompanyNameKana,
+		&s.Email, &s.PhoneNumber, &s.PostalCode, &s.Address, &s.BusinessLicenseNumber,
+		&status, &s.StatusReason, &approvedAt, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSellerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status = Status(status)
+	if approvedAt.Valid {
+		s.ApprovedAt = &approvedAt.Time
+	}
+	return &s, nil
+}
@@ -0,0 +1,226 @@
+package seller
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSellerNotFound    = errors.New("seller not found")
+	ErrApplicationExists = errors.New("a seller application already exists for this user")
+	ErrDuplicateSeller   = errors.New("a seller with this email or license number already exists")
+	ErrInvalidTransition = errors.New("the seller's status does not allow this change")
+)
+
+// Status mirrors the seller_status enum. Approved sellers are active.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusActive    Status = "active"
+	StatusSuspended Status = "suspended"
+	StatusBanned    Status = "banned"
+	StatusRejected  Status = "rejected"
+)
+
+// Seller is a row of the sellers table
+type Seller struct {
+	ID string `json:"id"`
+	// UserID is the applicant's sub claim, stored as sellers.cognito_user_id.
+	// The user pool signs in by email, so it is also their username.
+	UserID                string     `json:"userId"`
+	CompanyName           string     `json:"companyName"`
+	CompanyNameKana       string     `json:"companyNameKana,omitempty"`
+	Email                 string     `json:"email"`
+	PhoneNumber           string     `json:"phoneNumber"`
+	PostalCode            string     `json:"postalCode"`
+	Address               string     `json:"address"`
+	BusinessLicenseNumber string     `json:"businessLicenseNumber,omitempty"`
+	Status                Status     `json:"status"`
+	StatusReason          string     `json:"statusReason,omitempty"`
+	ApprovedAt            *time.Time `json:"approvedAt,omitempty"`
+	CreatedAt             time.Time  `json:"createdAt"`
+	UpdatedAt             time.Time  `json:"updatedAt"`
+}
+
+// HasAccess reports whether the seller may act as one
+func (s *Seller) HasAccess() bool {
+	return s.Status == StatusActive
+}
+
+// allowedFrom lists the statuses a seller may move to status from. Moving
+// to the current status is allowed so a failed identity update can be
+// retried.
+func allowedFrom(status Status) []Status {
+	switch status {
+	case StatusActive:
+		return []Status{StatusPending, StatusSuspended, StatusActive}
+	case StatusRejected:
+		return []Status{StatusPending, StatusRejected}
+	case StatusSuspended:
+		return []Status{StatusActive, StatusSuspended}
+	}
+	return nil
+}
+
+// ListOptions selects a page of sellers. SortBy is createdAt, updatedAt or
+// companyName.
+type ListOptions struct {
+	Status     Status
+	SortBy     string
+	Descending bool
+	Offset     int
+	Limit      int
+}
+
+// Store persists sellers
+type Store interface {
+	// Apply creates a pending seller for s.UserID. A rejected applicant may
+	// apply again, replacing their application; otherwise it fails with
+	// ErrApplicationExists.
+	Apply(ctx context.Context, s Seller) (*Seller, error)
+	Get(ctx context.Context, id string) (*Seller, error)
+	FindByUser(ctx context.Context, userID string) (*Seller, error)
+	// List returns a page of sellers and the total matching count
+	List(ctx context.Context, opts ListOptions) ([]Seller, int, error)
+	// SetStatus moves a seller to status, failing with ErrInvalidTransition
+	// when its current status doesn't allow it. Approvals record actor, the
+	// approving admin's sub.
+	SetStatus(ctx context.Context, id string, status Status, reason, actor string) (*Seller, error)
+}
+
+// MemoryStore is an in-process Store for running without PostgreSQL
+type MemoryStore struct {
+	mu      sync.Mutex
+	sellers map[string]*Seller
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sellers: make(map[string]*Seller),
+	}
+}
+
+func (m *MemoryStore) Apply(ctx context.Context, s Seller) (*Seller, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var previous *Seller
+	for _, existing := range m.sellers {
+		switch {
+		case existing.UserID == s.UserID:
+			if existing.Status != StatusRejected {
+				return nil, ErrApplicationExists
+			}
+			previous = existing
+		case strings.EqualFold(existing.Email, s.Email),
+			s.BusinessLicenseNumber != "" && existing.BusinessLicenseNumber == s.BusinessLicenseNumber:
+			return nil, ErrDuplicateSeller
+		}
+	}
+
+	now := time.Now().UTC()
+	s.ID = uuid.NewString()
+	s.CreatedAt = now
+	if previous != nil {
+		s.ID, s.CreatedAt = previous.ID, previous.CreatedAt
+	}
+	s.Status = StatusPending
+	s.StatusReason = ""
+	s.ApprovedAt = nil
+	s.UpdatedAt = now
+
+	m.sellers[s.ID] = &s
+	copied := s
+	return &copied, nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Seller, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sellers[id]
+	if !ok {
+		return nil, ErrSellerNotFound
+	}
+	copied := *s
+	return &copied, nil
+}
+
+func (m *MemoryStore) FindByUser(ctx context.Context, userID string) (*Seller, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sellers {
+		if s.UserID == userID {
+			copied := *s
+			return &copied, nil
+		}
+	}
+	return nil, ErrSellerNotFound
+}
+
+func (m *MemoryStore) List(ctx context.Context, opts ListOptions) ([]Seller, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sellers := []Seller{}
+	for _, s := range m.sellers {
+		if opts.Status == "" || s.Status == opts.Status {
+			sellers = append(sellers, *s)
+		}
+	}
+
+	less := func(a, b *Seller) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	switch opts.SortBy {
+	case "updatedAt":
+		less = func(a, b *Seller) bool { return a.UpdatedAt.Before(b.UpdatedAt) }
+	case "companyName":
+		less = func(a, b *Seller) bool { return a.CompanyName < b.CompanyName }
+	}
+	sort.SliceStable(sellers, func(i, j int) bool {
+		if opts.Descending {
+			return less(&sellers[j], &sellers[i])
+		}
+		return less(&sellers[i], &sellers[j])
+	})
+
+	start := min(opts.Offset, len(sellers))
+	end := min(start+opts.Limit, len(sellers))
+	return sellers[start:end], len(sellers), nil
+}
+
+func (m *MemoryStore) SetStatus(ctx context.Context, id string, status Status, reason, actor string) (*Seller, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sellers[id]
+	if !ok {
+		return nil, ErrSellerNotFound
+	}
+
+	allowed := false
+	for _, from := range allowedFrom(status) {
+		allowed = allowed || s.Status == from
+	}
+	if !allowed {
+		return nil, ErrInvalidTransition
+	}
+
+	now := time.Now().UTC()
+	if status == StatusActive && s.Status != StatusActive {
+		s.ApprovedAt = &now
+	}
+	s.Status = status
+	s.StatusReason = reason
+	s.UpdatedAt = now
+
+	copied := *s
+	return &copied, nil
+}
@@ -0,0 +1,163 @@
+package seller
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func application(userID, email string) Seller {
+	return Seller{
+		UserID:      userID,
+		CompanyName: "Shop " + userID,
+		Email:       email,
+		PhoneNumber: "03-0000-0000",
+		PostalCode:  "100-0001",
+		Address:     "Tokyo",
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []Status
+		to      Status
+		wantErr error
+	}{
+		{"approve pending", nil, StatusActive, nil},
+		{"reject pending", nil, StatusRejected, nil},
+		{"suspend active", []Status{StatusActive}, StatusSuspended, nil},
+		{"reinstate suspended", []Status{StatusActive, StatusSuspended}, StatusActive, nil},
+		{"approve again", []Status{StatusActive}, StatusActive, nil},
+		{"suspend pending", nil, StatusSuspended, ErrInvalidTransition},
+		{"approve rejected", []Status{StatusRejected}, StatusActive, ErrInvalidTransition},
+		{"reject active", []Status{StatusActive}, StatusRejected, ErrInvalidTransition},
+		{"back to pending", nil, StatusPending, ErrInvalidTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			ctx := context.Background()
+
+			s, err := store.Apply(ctx, application("user-1", "shop@example.com"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, status := range tt.path {
+				if _, err := store.SetStatus(ctx, s.ID, status, "", "admin-1"); err != nil {
+					t.Fatalf("SetStatus(%s): %v", status, err)
+				}
+			}
+
+			got, err := store.SetStatus(ctx, s.ID, tt.to, "reason", "admin-1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetStatus(%s) error = %v, want %v", tt.to, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Status != tt.to || got.StatusReason != "reason" {
+				t.Errorf("seller = %s %q, want %s", got.Status, got.StatusReason, tt.to)
+			}
+			if got.HasAccess() != (tt.to == StatusActive) || (got.HasAccess() && got.ApprovedAt == nil) {
+				t.Errorf("seller = %s approved at %v, want access only when active", got.Status, got.ApprovedAt)
+			}
+		})
+	}
+
+	if _, err := NewMemoryStore().SetStatus(context.Background(), "missing", StatusActive, "", ""); !errors.Is(err, ErrSellerNotFound) {
+		t.Errorf("SetStatus() of an unknown seller error = %v, want %v", err, ErrSellerNotFound)
+	}
+}
+
+func TestApply(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	first, err := store.Apply(ctx, application("user-1", "shop@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != StatusPending {
+		t.Errorf("status = %s, want %s", first.Status, StatusPending)
+	}
+
+	// A pending applicant can't apply twice, nor can another user reuse the email
+	if _, err := store.Apply(ctx, application("user-1", "other@example.com")); !errors.Is(err, ErrApplicationExists) {
+		t.Errorf("second Apply() error = %v, want %v", err, ErrApplicationExists)
+	}
+	if _, err := store.Apply(ctx, application("user-2", "SHOP@example.com")); !errors.Is(err, ErrDuplicateSeller) {
+		t.Errorf("Apply() with a taken email error = %v, want %v", err, ErrDuplicateSeller)
+	}
+
+	// A rejected applicant may apply again, replacing their application
+	if _, err := store.SetStatus(ctx, first.ID, StatusRejected, "incomplete", "admin-1"); err != nil {
+		t.Fatal(err)
+	}
+	again, err := store.Apply(ctx, application("user-1", "shop@example.com"))
+	if err != nil {
+		t.Fatalf("Apply() after rejection: %v", err)
+	}
+	if again.ID != first.ID || again.Status != StatusPending || again.StatusReason != "" {
+		t.Errorf("reapplication = %+v, want the same seller pending again", again)
+	}
+
+	// Once approved, there is no way back to an application
+	if _, err := store.SetStatus(ctx, again.ID, StatusActive, "", "admin-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Apply(ctx, application("user-1", "shop@example.com")); !errors.Is(err, ErrApplicationExists) {
+		t.Errorf("Apply() after approval error = %v, want %v", err, ErrApplicationExists)
+	}
+}
+
+func TestList(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	for _, userID := range []string{"c", "a", "b"} {
+		s, err := store.Apply(ctx, application(userID, userID+"@example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userID == "b" {
+			store.SetStatus(ctx, s.ID, StatusActive, "", "admin-1")
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      ListOptions
+		want      []string
+		wantTotal int
+	}{
+		{"by company", ListOptions{SortBy: "companyName", Limit: 10}, []string{"a", "b", "c"}, 3},
+		{"descending page", ListOptions{SortBy: "companyName", Descending: true, Offset: 1, Limit: 1}, []string{"b"}, 3},
+		{"pending only", ListOptions{Status: StatusPending, SortBy: "companyName", Limit: 10}, []string{"a", "c"}, 2},
+		{"past the end", ListOptions{Offset: 5, Limit: 10}, nil, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sellers, total, err := store.List(ctx, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, s := range sellers {
+				got = append(got, s.UserID)
+			}
+			if len(got) != len(tt.want) || total != tt.wantTotal {
+				t.Fatalf("List() = %v (%d), want %v (%d)", got, total, tt.want, tt.wantTotal)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("List() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
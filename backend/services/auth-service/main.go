@@ -1,71 +1,199 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ec-recommend/auth-service/internal/handlers"
+	"github.com/ec-recommend/backend/shared/go/observability"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+const serviceName = "auth-service"
+
 func main() {
+	// JSON logs at LOG_LEVEL; log.Printf goes through the same logger
+	observability.SetupLogging(serviceName)
+
+	shutdownTracing, err := observability.SetupTracing(context.Background(), serviceName)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+
 	// Initialize auth handler
 	authHandler, err := handlers.NewAuthHandler()
 	if err != nil {
 		log.Fatal("Failed to initialize auth handler:", err)
 	}
 
-	// Initialize Gin router
-	r := gin.Default()
+	passkeyHandler, err := handlers.NewPasskeyHandler(authHandler)
+	if err != nil {
+		log.Fatal("Failed to initialize passkey handler:", err)
+	}
+
+	accountHandler, err := handlers.NewAccountHandler(authHandler)
+	if err != nil {
+		log.Fatal("Failed to initialize account handler:", err)
+	}
+
+	// Background work stops with the server
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go accountHandler.RunDeletions(workers)
+
+	oauthHandler, err := handlers.NewOAuthHandler(authHandler)
+	if err != nil {
+		log.Fatal("Failed to initialize OAuth handler:", err)
+	}
+
+	adminHandler, err := handlers.NewAdminHandler(authHandler)
+	if err != nil {
+		log.Fatal("Failed to initialize admin handler:", err)
+	}
+
+	sellerHandler, err := handlers.NewSellerHandler(authHandler, adminHandler)
+	if err != nil {
+		log.Fatal("Failed to initialize seller handler:", err)
+	}
+
+	// Initialize Gin router. The observability middleware replaces
+	// gin.Default's logger with traced, request-ID-tagged JSON logs.
+	r := gin.New()
+
+	// X-Forwarded-For is only believed from TRUSTED_PROXIES (none by
+	// default), so clients can't choose the IP that sign-in throttling and
+	// the audit log see
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
+	r.Use(gin.Recovery())
+	r.Use(observability.GinMiddleware(serviceName)...)
+
+	// CORS configuration. Credentials are allowed so browsers send the
+	// session cookies, which rules out a wildcard origin.
+	origins := []string{"http://localhost:3001"}
+	if env := os.Getenv("CORS_ALLOWED_ORIGINS"); env != "" {
+		origins = strings.Split(env, ",")
+	}
+	for i := range origins {
+		origins[i] = strings.TrimSpace(origins[i])
+		if origins[i] == "*" {
+			log.Fatal("CORS_ALLOWED_ORIGINS cannot be * when credentials are allowed")
+		}
+	}
 
-	// CORS configuration
 	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3001"}
+	config.AllowOrigins = origins
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-CSRF-Token", observability.RequestIDHeader}
+	config.ExposeHeaders = []string{"Retry-After", observability.RequestIDHeader}
+	config.AllowCredentials = true
 	r.Use(cors.New(config))
+	r.Use(authHandler.AuditContext())
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Signing keys when the identity provider issues its own tokens
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// Auth routes
-	auth := r.Group("/auth")
+	auth := r.Group("/auth", authHandler.CSRFProtect())
 	{
 		auth.POST("/signup", authHandler.SignUp)
 		auth.POST("/signin", authHandler.SignIn)
+		auth.POST("/challenge", authHandler.RespondToChallenge)
 		auth.POST("/confirm", authHandler.ConfirmSignUp)
+		auth.POST("/confirm/resend", authHandler.ResendConfirmationCode)
+		auth.POST("/password/forgot", authHandler.ForgotPassword)
+		auth.POST("/password/reset", authHandler.ResetPassword)
+		auth.POST("/password/change", authHandler.ChangePassword)
 		auth.POST("/refresh", authHandler.RefreshToken)
 		auth.POST("/validate", authHandler.ValidateToken)
 		auth.GET("/user", authHandler.GetCurrentUser)
+		auth.PUT("/user", authHandler.UpdateCurrentUser)
+		auth.POST("/user/verify", authHandler.VerifyUserAttribute)
+		auth.DELETE("/user", accountHandler.RequestDeletion)
+		auth.GET("/user/deletion", accountHandler.GetDeletion)
+		auth.POST("/user/deletion/cancel", accountHandler.CancelDeletion)
+		auth.GET("/user/export", accountHandler.ExportData)
+		auth.POST("/logout", authHandler.SignOut)
+		auth.POST("/logout/all", authHandler.SignOutAll)
+		auth.GET("/mfa", authHandler.GetMFAStatus)
+		auth.POST("/mfa/totp/setup", authHandler.StartTOTPEnrollment)
+		auth.POST("/mfa/totp/verify", authHandler.VerifyTOTPEnrollment)
+		auth.DELETE("/mfa/:method", authHandler.DisableMFAMethod)
+		auth.POST("/seller/application", sellerHandler.Apply)
+		auth.GET("/seller/application", sellerHandler.GetApplication)
+	}
+
+	// Passkey routes
+	passkey := r.Group("/auth/passkey", authHandler.CSRFProtect())
+	{
+		passkey.POST("/register/begin", passkeyHandler.RegisterBegin)
+		passkey.POST("/register/complete", passkeyHandler.RegisterComplete)
+		passkey.POST("/authenticate/begin", passkeyHandler.AuthenticateBegin)
+		passkey.POST("/authenticate/complete", passkeyHandler.AuthenticateComplete)
+	}
+
+	// Social login. The callback is outside the CSRF check: Apple posts it
+	// cross-site, and the state cookie already binds it to the browser.
+	social := r.Group("/auth/oauth")
+	{
+		social.GET("/:provider/start", oauthHandler.Start)
+		social.GET("/:provider/callback", oauthHandler.Callback)
+		social.POST("/:provider/callback", oauthHandler.Callback)
 	}
 
-	// Passkey routes (placeholder for future implementation)
-	passkey := r.Group("/auth/passkey")
+	// Offline OIDC issuer for social login in development
+	localIssuer := r.Group("/oauth-local")
 	{
-		passkey.POST("/register/begin", func(c *gin.Context) {
-			c.JSON(200, gin.H{"challenge": "dummy-challenge"})
-		})
-		passkey.POST("/register/complete", func(c *gin.Context) {
-			c.JSON(200, gin.H{"success": true})
-		})
-		passkey.POST("/authenticate/begin", func(c *gin.Context) {
-			c.JSON(200, gin.H{"challenge": "dummy-challenge"})
-		})
-		passkey.POST("/authenticate/complete", func(c *gin.Context) {
-			c.JSON(200, gin.H{
-				"user": gin.H{
-					"id":    "passkey-user-123",
-					"email": "passkey@example.com",
-				},
-				"tokens": gin.H{
-					"idToken":     "dummy-id-token",
-					"accessToken": "dummy-access-token",
-				},
-			})
-		})
+		localIssuer.GET("/authorize", oauthHandler.LocalAuthorize)
+		localIssuer.POST("/token", oauthHandler.LocalToken)
+		localIssuer.GET("/jwks", oauthHandler.LocalJWKS)
+	}
+
+	// Admin routes, for members of the admin group
+	admin := r.Group("/admin", adminHandler.Middleware()...)
+	{
+		// User management
+		users := admin.Group("/users")
+		users.GET("", adminHandler.ListUsers)
+		users.GET("/:username", adminHandler.GetUser)
+		users.POST("/:username/disable", adminHandler.DisableUser)
+		users.POST("/:username/enable", adminHandler.EnableUser)
+		users.PUT("/:username/groups/:group", adminHandler.AddUserToGroup)
+		users.DELETE("/:username/groups/:group", adminHandler.RemoveUserFromGroup)
+		users.POST("/:username/password/reset", adminHandler.ResetPassword)
+		users.POST("/:username/signout", adminHandler.SignOutUser)
+
+		// Recent audit events of a user
+		admin.GET("/audit/events", adminHandler.SearchEvents)
+
+		// Review of seller applications
+		sellers := admin.Group("/sellers")
+		sellers.GET("", sellerHandler.ListSellers)
+		sellers.GET("/:seller_id", sellerHandler.GetSeller)
+		sellers.POST("/:seller_id/approve", sellerHandler.Approve)
+		sellers.POST("/:seller_id/reject", sellerHandler.Reject)
+		sellers.POST("/:seller_id/suspend", sellerHandler.Suspend)
 	}
 
 	// Get port from environment or use default
@@ -74,8 +202,34 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Starting auth service on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
+
+	go func() {
+		log.Printf("Starting auth service on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// Drain requests and flush pending audit events and spans before exiting
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	stopWorkers()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown failed: %v", err)
+	}
+	if err := authHandler.CloseAudit(ctx); err != nil {
+		log.Printf("Audit shutdown failed: %v", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Tracing shutdown failed: %v", err)
 	}
-}
\ No newline at end of file
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/golang-jwt/jwt/v5"
@@ -30,6 +31,9 @@ type AuthInfo struct {
 	Roles       []string
 	SellerID    string
 	Permissions []string
+	// Pool names the user pool (realm) that issued the caller's token,
+	// e.g. "buyer", "seller" or "admin"
+	Pool string
 }
 
 // CognitoClient interface for mocking
@@ -42,75 +46,307 @@ type AuthMiddleware struct {
 	cognitoClient CognitoClient
 	userPoolID    string
 	region        string
+	clientID      string
+	issuer        string
+	poolName      string
+	tokenUses     []string
+	policy        *Policy
+
+	jwksSource     string
+	jwksRefresh    time.Duration
+	jwksMinRefetch time.Duration
+
+	extraPools []TrustedPool
+	// pools maps each trusted issuer to its pool
+	pools map[string]*trustedPool
+
+	revocations RevocationList
+	verifier    TokenVerifier
 }
 
-// NewAuthMiddleware creates a new auth middleware
-func NewAuthMiddleware(cognitoClient CognitoClient, userPoolID, region string) *AuthMiddleware {
-	return &AuthMiddleware{
+// TokenVerifier checks a bearer token and returns the caller it identifies
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (*AuthInfo, error)
+}
+
+// TrustedPool is an additional user pool whose tokens are accepted
+type TrustedPool struct {
+	// Name is reported as AuthInfo.Pool; it defaults to UserPoolID
+	Name       string
+	UserPoolID string
+	Region     string
+	ClientIDs  []string
+	// Issuer and JWKSSource default to the pool's Cognito endpoints
+	Issuer     string
+	JWKSSource string
+}
+
+// trustedPool is a TrustedPool with its key cache loaded
+type trustedPool struct {
+	name      string
+	clientIDs []string
+	jwks      *JWKSCache
+}
+
+// Option configures an AuthMiddleware
+type Option func(*AuthMiddleware)
+
+// WithJWKSSource overrides the Cognito JWKS endpoint with another URL or a
+// local JWKS file, e.g. for offline tests
+func WithJWKSSource(source string) Option {
+	return func(a *AuthMiddleware) {
+		a.jwksSource = source
+	}
+}
+
+// WithJWKSRefresh sets the background refresh interval and the minimum
+// interval between refetches triggered by unknown key IDs
+func WithJWKSRefresh(interval, minRefetch time.Duration) Option {
+	return func(a *AuthMiddleware) {
+		a.jwksRefresh = interval
+		a.jwksMinRefetch = minRefetch
+	}
+}
+
+// WithIssuer overrides the expected issuer, e.g. for a local token stand-in
+func WithIssuer(issuer string) Option {
+	return func(a *AuthMiddleware) {
+		a.issuer = issuer
+	}
+}
+
+// WithPoolName sets the realm name reported for the primary pool's tokens
+// (default: the user pool ID)
+func WithPoolName(name string) Option {
+	return func(a *AuthMiddleware) {
+		a.poolName = name
+	}
+}
+
+// WithTrustedPool also accepts tokens from another user pool, verified
+// against that pool's own JWKS and app clients
+func WithTrustedPool(pool TrustedPool) Option {
+	return func(a *AuthMiddleware) {
+		a.extraPools = append(a.extraPools, pool)
+	}
+}
+
+// WithTokenUses restricts the accepted token_use values (default: id, access)
+func WithTokenUses(uses ...string) Option {
+	return func(a *AuthMiddleware) {
+		a.tokenUses = uses
+	}
+}
+
+// WithPolicy sets the method authorization policy (default: policy.yaml)
+func WithPolicy(policy *Policy) Option {
+	return func(a *AuthMiddleware) {
+		a.policy = policy
+	}
+}
+
+// WithRevocationList rejects tokens on the list. Lookup errors let the token
+// through, so an outage of the list doesn't reject every request.
+func WithRevocationList(list RevocationList) Option {
+	return func(a *AuthMiddleware) {
+		a.revocations = list
+	}
+}
+
+// WithTokenVerifier verifies tokens with v instead of the pools' JWKS, for
+// services that already validate their own tokens but want the middleware's
+// policy and role checks. No JWKS is loaded.
+func WithTokenVerifier(v TokenVerifier) Option {
+	return func(a *AuthMiddleware) {
+		a.verifier = v
+	}
+}
+
+// NewAuthMiddleware creates a new auth middleware and loads the JWKS of the
+// pool and of any trusted pools
+func NewAuthMiddleware(cognitoClient CognitoClient, userPoolID, region, clientID string, opts ...Option) (*AuthMiddleware, error) {
+	a := &AuthMiddleware{
 		cognitoClient: cognitoClient,
 		userPoolID:    userPoolID,
 		region:        region,
+		clientID:      clientID,
+		issuer:        fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID),
+		poolName:      userPoolID,
+		tokenUses:     []string{"id", "access"},
+		jwksSource:    CognitoJWKSURL(region, userPoolID),
 	}
-}
 
-// UnaryServerInterceptor returns a gRPC unary interceptor for authentication
-func (a *AuthMiddleware) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
-	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		// Skip auth for health check endpoints
-		if strings.Contains(info.FullMethod, "Health") {
-			return handler(ctx, req)
-		}
+	for _, opt := range opts {
+		opt(a)
+	}
 
-		// Extract token from metadata
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "missing metadata")
+	if a.policy == nil {
+		policy, err := DefaultPolicy()
+		if err != nil {
+			return nil, err
 		}
+		a.policy = policy
+	}
 
-		authorization := md.Get("authorization")
-		if len(authorization) == 0 {
-			// Check if this endpoint requires authentication
-			if requiresAuth(info.FullMethod) {
-				return nil, status.Error(codes.Unauthenticated, "missing authorization header")
-			}
-			return handler(ctx, req)
+	if a.verifier != nil {
+		return a, nil
+	}
+
+	pools := append([]TrustedPool{{
+		Name:       a.poolName,
+		ClientIDs:  []string{a.clientID},
+		Issuer:     a.issuer,
+		JWKSSource: a.jwksSource,
+	}}, a.extraPools...)
+
+	a.pools = make(map[string]*trustedPool, len(pools))
+	for _, pool := range pools {
+		if pool.Issuer == "" {
+			pool.Issuer = fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", pool.Region, pool.UserPoolID)
+		}
+		if pool.JWKSSource == "" {
+			pool.JWKSSource = CognitoJWKSURL(pool.Region, pool.UserPoolID)
+		}
+		if pool.Name == "" {
+			pool.Name = pool.UserPoolID
+		}
+		if _, dup := a.pools[pool.Issuer]; dup {
+			a.Close()
+			return nil, fmt.Errorf("issuer %s is configured twice", pool.Issuer)
 		}
 
-		// Extract bearer token
-		token := strings.TrimPrefix(authorization[0], "Bearer ")
-		if token == authorization[0] {
-			return nil, status.Error(codes.Unauthenticated, "invalid authorization format")
+		jwks, err := NewJWKSCache(pool.JWKSSource, a.jwksRefresh, a.jwksMinRefetch)
+		if err != nil {
+			a.Close()
+			return nil, err
 		}
+		a.pools[pool.Issuer] = &trustedPool{name: pool.Name, clientIDs: pool.ClientIDs, jwks: jwks}
+	}
+
+	return a, nil
+}
+
+// ValidatePolicy fails if any method registered on server has no policy rule.
+// Call it at startup after registering services.
+func (a *AuthMiddleware) ValidatePolicy(server ServiceInfoProvider) error {
+	return a.policy.Validate(server)
+}
+
+// Close stops the background JWKS refreshers
+func (a *AuthMiddleware) Close() {
+	for _, pool := range a.pools {
+		pool.jwks.Close()
+	}
+}
 
-		// Verify token
-		authInfo, err := a.verifyToken(ctx, token)
+// UnaryServerInterceptor returns a gRPC unary interceptor for authentication
+func (a *AuthMiddleware) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
 		if err != nil {
-			return nil, status.Error(codes.Unauthenticated, err.Error())
+			return nil, err
 		}
 
-		// Add auth info to context
-		ctx = context.WithValue(ctx, AuthContextKey, authInfo)
-		ctx = context.WithValue(ctx, UserIDKey, authInfo.UserID)
-		ctx = context.WithValue(ctx, RolesKey, authInfo.Roles)
-		if authInfo.SellerID != "" {
-			ctx = context.WithValue(ctx, SellerIDKey, authInfo.SellerID)
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC stream interceptor for authentication.
+// Handlers can read GetAuthInfo from the stream's context.
+func (a *AuthMiddleware) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
 		}
 
-		// Check permissions
-		if !hasRequiredRole(authInfo.Roles, info.FullMethod) {
-			return nil, status.Error(codes.PermissionDenied, "insufficient permissions")
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authServerStream overrides the stream context with the authenticated one
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate applies the method's policy rule and returns the context
+// carrying the caller's auth info
+func (a *AuthMiddleware) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	rule, ok := a.policy.Lookup(fullMethod)
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "no access policy for method")
+	}
+
+	// Extract token from metadata
+	var authorization []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		authorization = md.Get("authorization")
+	}
+
+	if len(authorization) == 0 {
+		if rule.Access != AccessPublic {
+			return nil, status.Error(codes.Unauthenticated, "missing authorization header")
 		}
+		return ctx, nil
+	}
 
-		return handler(ctx, req)
+	// Extract bearer token
+	token := strings.TrimPrefix(authorization[0], "Bearer ")
+	if token == authorization[0] {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization format")
 	}
+
+	// Verify token
+	authInfo, err := a.verifyToken(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	// Add auth info to context
+	ctx = withAuthInfo(ctx, authInfo)
+
+	// Check permissions
+	if !rule.Allows(authInfo) {
+		return nil, status.Error(codes.PermissionDenied, "insufficient permissions")
+	}
+
+	return ctx, nil
 }
 
-// verifyToken verifies the JWT token with Cognito
+// verifyToken verifies the JWT signature against the issuing pool's JWKS and
+// checks issuer, expiry, token_use and audience, unless a TokenVerifier is
+// configured
 func (a *AuthMiddleware) verifyToken(ctx context.Context, tokenString string) (*AuthInfo, error) {
-	// Parse token without verification first to get claims
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if a.verifier != nil {
+		return a.verifier.VerifyToken(ctx, tokenString)
+	}
+
+	// The issuer picks the pool whose keys and clients apply
+	var pool *trustedPool
+
+	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		issuer, _ := token.Claims.GetIssuer()
+		var trusted bool
+		if pool, trusted = a.pools[issuer]; !trusted {
+			return nil, fmt.Errorf("untrusted issuer: %s", issuer)
+		}
+
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("missing kid in token header")
+		}
+		return pool.jwks.PublicKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+		return nil, fmt.Errorf("failed to verify token: %w", err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -118,10 +354,41 @@ func (a *AuthMiddleware) verifyToken(ctx context.Context, tokenString string) (*
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
+	tokenUse := getStringClaim(claims, "token_use")
+	if !containsString(a.tokenUses, tokenUse) {
+		return nil, fmt.Errorf("invalid token use: %s", tokenUse)
+	}
+
+	// ID tokens carry the app client in aud, access tokens in client_id
+	switch tokenUse {
+	case "id":
+		aud, err := claims.GetAudience()
+		if err != nil || !containsAny(pool.clientIDs, aud) {
+			return nil, fmt.Errorf("invalid audience")
+		}
+	case "access":
+		if !containsString(pool.clientIDs, getStringClaim(claims, "client_id")) {
+			return nil, fmt.Errorf("invalid client_id")
+		}
+	}
+
+	if a.revocations != nil {
+		issuedAt, _ := claims.GetIssuedAt()
+		var iat time.Time
+		if issuedAt != nil {
+			iat = issuedAt.Time
+		}
+		revoked, err := a.revocations.IsRevoked(ctx, getStringClaim(claims, "jti"), getStringClaim(claims, "sub"), iat)
+		if err == nil && revoked {
+			return nil, fmt.Errorf("token revoked")
+		}
+	}
+
 	// Extract user info from claims
 	authInfo := &AuthInfo{
 		UserID: getStringClaim(claims, "sub"),
 		Email:  getStringClaim(claims, "email"),
+		Pool:   pool.name,
 	}
 
 	// Extract groups/roles
@@ -136,9 +403,8 @@ func (a *AuthMiddleware) verifyToken(ctx context.Context, tokenString string) (*
 	// Extract custom claims
 	authInfo.SellerID = getStringClaim(claims, "custom:seller_id")
 
-	// TODO: Verify token signature with Cognito JWKS
-	// This would involve fetching and caching the JWKS from Cognito
-	// For now, we'll use the Cognito API to verify the token
+	// Access tokens carry OAuth scopes, used as permissions
+	authInfo.Permissions = strings.Fields(getStringClaim(claims, "scope"))
 
 	return authInfo, nil
 }
@@ -152,57 +418,33 @@ func getStringClaim(claims jwt.MapClaims, key string) string {
 	return ""
 }
 
-func requiresAuth(method string) bool {
-	// Define public endpoints that don't require authentication
-	publicEndpoints := []string{
-		"GetProduct",
-		"ListProducts",
-		"ListCategories",
-		"SearchProducts",
-		"GetRecommendations",
-	}
-
-	for _, endpoint := range publicEndpoints {
-		if strings.Contains(method, endpoint) {
-			return false
-		}
-	}
-	return true
-}
-
-func hasRequiredRole(userRoles []string, method string) bool {
-	// Define role requirements for specific endpoints
-	roleRequirements := map[string][]string{
-		"seller": {
-			"CreateProduct",
-			"UpdateProduct",
-			"UpdateStock",
-			"ListSellerOrders",
-		},
-		"admin": {
-			"ListAllUsers",
-			"ApproveSeller",
-			"GetAnalytics",
-		},
-	}
-
-	// Check if method requires specific role
-	for role, methods := range roleRequirements {
-		for _, m := range methods {
-			if strings.Contains(method, m) {
-				// User must have this role
-				for _, userRole := range userRoles {
-					if userRole == role {
-						return true
-					}
-				}
-				return false
-			}
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, c := range candidates {
+		if containsString(values, c) {
+			return true
 		}
 	}
+	return false
+}
 
-	// No specific role required
-	return true
+// withAuthInfo stores auth info and its commonly used fields in the context
+func withAuthInfo(ctx context.Context, authInfo *AuthInfo) context.Context {
+	ctx = context.WithValue(ctx, AuthContextKey, authInfo)
+	ctx = context.WithValue(ctx, UserIDKey, authInfo.UserID)
+	ctx = context.WithValue(ctx, RolesKey, authInfo.Roles)
+	if authInfo.SellerID != "" {
+		ctx = context.WithValue(ctx, SellerIDKey, authInfo.SellerID)
+	}
+	return ctx
 }
 
 // GetAuthInfo extracts auth info from context
@@ -235,4 +477,4 @@ func HasRole(ctx context.Context, role string) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
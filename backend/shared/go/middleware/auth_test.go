@@ -0,0 +1,364 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testIssuer       = "https://issuer.example.com/buyer"
+	testSellerIssuer = "https://issuer.example.com/seller"
+	testClientID     = "buyer-client"
+	testKeyID        = "test-key"
+)
+
+// testKeys is a signing key published in a JWKS file
+type testKeys struct {
+	key  *rsa.PrivateKey
+	kid  string
+	path string
+}
+
+func newTestKeys(t *testing.T, kid string) *testKeys {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := jwk.FromRaw(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public.Set(jwk.KeyIDKey, kid)
+	public.Set(jwk.AlgorithmKey, "RS256")
+
+	set := jwk.NewSet()
+	set.AddKey(public)
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), kid+".json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testKeys{key: key, kid: kid, path: path}
+}
+
+func (k *testKeys) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func accessClaims(issuer, clientID string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":       issuer,
+		"sub":       "user-1",
+		"token_use": "access",
+		"client_id": clientID,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"iat":       time.Now().Unix(),
+	}
+}
+
+func newTestMiddleware(t *testing.T, keys *testKeys, opts ...Option) *AuthMiddleware {
+	t.Helper()
+
+	opts = append([]Option{
+		WithJWKSSource(keys.path),
+		WithIssuer(testIssuer),
+		WithPoolName("buyer"),
+		WithPolicy(mustParsePolicy(t, testPolicyYAML)),
+	}, opts...)
+
+	a, err := NewAuthMiddleware(nil, "pool", "region", testClientID, opts...)
+	if err != nil {
+		t.Fatalf("NewAuthMiddleware: %v", err)
+	}
+	t.Cleanup(a.Close)
+	return a
+}
+
+func TestVerifyToken(t *testing.T) {
+	keys := newTestKeys(t, testKeyID)
+	otherKeys := newTestKeys(t, testKeyID)
+	sellerKeys := newTestKeys(t, "seller-key")
+
+	a := newTestMiddleware(t, keys, WithTrustedPool(TrustedPool{
+		Name:       "seller",
+		ClientIDs:  []string{"seller-client"},
+		Issuer:     testSellerIssuer,
+		JWKSSource: sellerKeys.path,
+	}))
+
+	with := func(claims jwt.MapClaims, key string, value interface{}) jwt.MapClaims {
+		claims[key] = value
+		return claims
+	}
+	without := func(claims jwt.MapClaims, key string) jwt.MapClaims {
+		delete(claims, key)
+		return claims
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss":       testIssuer,
+		"sub":       "user-1",
+		"token_use": "id",
+		"aud":       testClientID,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims(testIssuer, testClientID)).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknownKid := jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims(testIssuer, testClientID))
+	unknownKid.Header["kid"] = "rotated-away"
+	unknownKidToken, err := unknownKid.SignedString(keys.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noKid, err := jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims(testIssuer, testClientID)).SignedString(keys.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantErr  string
+		wantPool string
+	}{
+		{"access token", keys.sign(t, accessClaims(testIssuer, testClientID)), "", "buyer"},
+		{"id token", keys.sign(t, idClaims), "", "buyer"},
+		{"trusted pool", sellerKeys.sign(t, accessClaims(testSellerIssuer, "seller-client")), "", "seller"},
+		{"malformed", "not-a-jwt", "failed to verify token", ""},
+		{"wrong key", otherKeys.sign(t, accessClaims(testIssuer, testClientID)), "failed to verify token", ""},
+		{"other pool's key", sellerKeys.sign(t, accessClaims(testIssuer, testClientID)), "failed to verify token", ""},
+		{"HS256", hs256, "failed to verify token", ""},
+		{"unknown kid", unknownKidToken, "key not found", ""},
+		{"missing kid", noKid, "missing kid", ""},
+		{"untrusted issuer", keys.sign(t, accessClaims("https://evil.example.com", testClientID)), "untrusted issuer", ""},
+		{"expired", keys.sign(t, with(accessClaims(testIssuer, testClientID), "exp", time.Now().Add(-time.Minute).Unix())), "expired", ""},
+		{"no expiry", keys.sign(t, without(accessClaims(testIssuer, testClientID), "exp")), "exp", ""},
+		{"unknown token_use", keys.sign(t, with(accessClaims(testIssuer, testClientID), "token_use", "refresh")), "invalid token use", ""},
+		{"access token for another client", keys.sign(t, accessClaims(testIssuer, "other-client")), "invalid client_id", ""},
+		{"access token for another pool's client", keys.sign(t, accessClaims(testIssuer, "seller-client")), "invalid client_id", ""},
+		{"id token for another client", keys.sign(t, with(idClaims, "aud", "other-client")), "invalid audience", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := a.verifyToken(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("verifyToken() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyToken() error = %v", err)
+			}
+			if info.Pool != tt.wantPool || info.UserID != "user-1" {
+				t.Errorf("verifyToken() = %+v, want pool %q and user-1", info, tt.wantPool)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenClaims(t *testing.T) {
+	keys := newTestKeys(t, testKeyID)
+	a := newTestMiddleware(t, keys)
+
+	claims := accessClaims(testIssuer, testClientID)
+	claims["cognito:groups"] = []string{"seller", "admin"}
+	claims["custom:seller_id"] = "alice"
+	claims["scope"] = "orders.read orders.write"
+
+	info, err := a.verifyToken(context.Background(), keys.sign(t, claims))
+	if err != nil {
+		t.Fatalf("verifyToken: %v", err)
+	}
+
+	if strings.Join(info.Roles, ",") != "seller,admin" {
+		t.Errorf("Roles = %v", info.Roles)
+	}
+	if info.SellerID != "alice" {
+		t.Errorf("SellerID = %q", info.SellerID)
+	}
+	if strings.Join(info.Permissions, ",") != "orders.read,orders.write" {
+		t.Errorf("Permissions = %v", info.Permissions)
+	}
+}
+
+func TestWithTokenUses(t *testing.T) {
+	keys := newTestKeys(t, testKeyID)
+	a := newTestMiddleware(t, keys, WithTokenUses("access"))
+
+	idClaims := jwt.MapClaims{
+		"iss":       testIssuer,
+		"sub":       "user-1",
+		"token_use": "id",
+		"aud":       testClientID,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+	if _, err := a.verifyToken(context.Background(), keys.sign(t, idClaims)); err == nil {
+		t.Fatal("verifyToken() accepted an ID token with WithTokenUses(\"access\")")
+	}
+}
+
+func TestNewAuthMiddlewareRejectsDuplicateIssuers(t *testing.T) {
+	keys := newTestKeys(t, testKeyID)
+
+	_, err := NewAuthMiddleware(nil, "pool", "region", testClientID,
+		WithJWKSSource(keys.path),
+		WithIssuer(testIssuer),
+		WithTrustedPool(TrustedPool{Issuer: testIssuer, JWKSSource: keys.path}),
+	)
+	if err == nil || !strings.Contains(err.Error(), "configured twice") {
+		t.Fatalf("NewAuthMiddleware() error = %v, want a duplicate issuer error", err)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	keys := newTestKeys(t, testKeyID)
+	a := newTestMiddleware(t, keys)
+	interceptor := a.UnaryServerInterceptor()
+
+	valid := keys.sign(t, accessClaims(testIssuer, testClientID))
+	admin := accessClaims(testIssuer, testClientID)
+	admin["cognito:groups"] = []string{"admin"}
+	seller := accessClaims(testIssuer, testClientID)
+	seller["cognito:groups"] = []string{"seller"}
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		code          codes.Code
+	}{
+		{"public without token", "/test.Shop/Browse", "", codes.OK},
+		{"public with invalid token", "/test.Shop/Browse", "Bearer not-a-jwt", codes.Unauthenticated},
+		{"authenticated", "/test.Shop/Checkout", "Bearer " + valid, codes.OK},
+		{"missing token", "/test.Shop/Checkout", "", codes.Unauthenticated},
+		{"not a bearer token", "/test.Shop/Checkout", valid, codes.Unauthenticated},
+		{"required role", "/test.Shop/Restock", "Bearer " + keys.sign(t, seller), codes.OK},
+		{"missing role", "/test.Shop/Restock", "Bearer " + valid, codes.PermissionDenied},
+		{"missing permissions", "/test.Shop/Audit", "Bearer " + keys.sign(t, admin), codes.PermissionDenied},
+		{"no policy", "/test.Other/Browse", "Bearer " + valid, codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.authorization))
+			}
+
+			var authenticated bool
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				_, authenticated = GetAuthInfo(ctx)
+				return nil, nil
+			}
+
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("interceptor() code = %v, want %v (%v)", got, tt.code, err)
+			}
+			if tt.code == codes.OK && authenticated != (tt.authorization != "") {
+				t.Errorf("handler saw auth info = %v, want %v", authenticated, tt.authorization != "")
+			}
+		})
+	}
+}
+
+// contextStream is a grpc.ServerStream carrying only a context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	keys := newTestKeys(t, testKeyID)
+	a := newTestMiddleware(t, keys)
+	interceptor := a.StreamServerInterceptor()
+
+	valid := keys.sign(t, accessClaims(testIssuer, testClientID))
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		code          codes.Code
+		wantUser      string
+	}{
+		{"public without token", "/test.Shop/Browse", "", codes.OK, ""},
+		{"authenticated", "/test.Shop/Watch", "Bearer " + valid, codes.OK, "user-1"},
+		{"missing token", "/test.Shop/Watch", "", codes.Unauthenticated, ""},
+		{"invalid token", "/test.Shop/Watch", "Bearer not-a-jwt", codes.Unauthenticated, ""},
+		{"missing role", "/test.Shop/Restock", "Bearer " + valid, codes.PermissionDenied, ""},
+		{"no policy", "/test.Other/Watch", "Bearer " + valid, codes.PermissionDenied, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tt.authorization != "" {
+				md = metadata.Pairs("authorization", tt.authorization)
+			}
+			ss := &contextStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+
+			var called bool
+			var userID string
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				called = true
+				if info, ok := GetAuthInfo(stream.Context()); ok {
+					userID = info.UserID
+				}
+				return nil
+			}
+
+			err := interceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: tt.method}, handler)
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("interceptor() code = %v, want %v (%v)", got, tt.code, err)
+			}
+			if called != (tt.code == codes.OK) {
+				t.Errorf("handler called = %v, want %v", called, tt.code == codes.OK)
+			}
+			if userID != tt.wantUser {
+				t.Errorf("stream context user = %q, want %q", userID, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the JSON error body returned by the Gin middleware,
+// matching the shape used by the REST handlers
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// GinAuth returns a Gin middleware that requires a valid bearer token and,
+// when roles are given, at least one of them. AuthInfo is stored in both the
+// Gin context and the request context.
+func (a *AuthMiddleware) GinAuth(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "missing_token",
+				Message: "Authorization header is required",
+			})
+			return
+		}
+
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		if token == authHeader {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "invalid_token",
+				Message: "Authorization header must use the Bearer scheme",
+			})
+			return
+		}
+
+		authInfo, err := a.verifyToken(c.Request.Context(), token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "invalid_token",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.Set(string(AuthContextKey), authInfo)
+		c.Request = c.Request.WithContext(withAuthInfo(c.Request.Context(), authInfo))
+
+		if len(roles) > 0 && !(Rule{Roles: roles}).Allows(authInfo) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Error:   "insufficient_permissions",
+				Message: "Insufficient permissions",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GinRequireRoles returns a Gin middleware requiring at least one of roles.
+// It must run after GinAuth, e.g. on a route group needing extra roles.
+func GinRequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authInfo, ok := GinAuthInfo(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "missing_token",
+				Message: "Authorization header is required",
+			})
+			return
+		}
+
+		if !(Rule{Roles: roles}).Allows(authInfo) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Error:   "insufficient_permissions",
+				Message: "Insufficient permissions",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GinAuthInfo extracts auth info from the Gin context
+func GinAuthInfo(c *gin.Context) (*AuthInfo, bool) {
+	value, ok := c.Get(string(AuthContextKey))
+	if !ok {
+		return nil, false
+	}
+	authInfo, ok := value.(*AuthInfo)
+	return authInfo, ok
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGinAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys := newTestKeys(t, testKeyID)
+	a := newTestMiddleware(t, keys)
+
+	buyer := keys.sign(t, accessClaims(testIssuer, testClientID))
+	sellerClaims := accessClaims(testIssuer, testClientID)
+	sellerClaims["cognito:groups"] = []interface{}{"seller"}
+	seller := keys.sign(t, sellerClaims)
+
+	tests := []struct {
+		name          string
+		header        string
+		roles         []string
+		extraRoles    []string
+		wantStatus    int
+		wantErrorCode string
+	}{
+		{"valid token", "Bearer " + buyer, nil, nil, http.StatusOK, ""},
+		{"no header", "", nil, nil, http.StatusUnauthorized, "missing_token"},
+		{"not bearer", "Basic dXNlcjpwYXNz", nil, nil, http.StatusUnauthorized, "invalid_token"},
+		{"invalid token", "Bearer not-a-jwt", nil, nil, http.StatusUnauthorized, "invalid_token"},
+		{"has role", "Bearer " + seller, []string{"seller", "admin"}, nil, http.StatusOK, ""},
+		{"lacks role", "Bearer " + buyer, []string{"seller"}, nil, http.StatusForbidden, "insufficient_permissions"},
+		{"group requires role", "Bearer " + seller, nil, []string{"seller"}, http.StatusOK, ""},
+		{"group lacks role", "Bearer " + seller, nil, []string{"admin"}, http.StatusForbidden, "insufficient_permissions"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			handlers := []gin.HandlerFunc{a.GinAuth(tt.roles...)}
+			if tt.extraRoles != nil {
+				handlers = append(handlers, GinRequireRoles(tt.extraRoles...))
+			}
+			handlers = append(handlers, func(c *gin.Context) {
+				// Handlers see the caller through Gin and the request context
+				fromGin, ok := GinAuthInfo(c)
+				fromCtx, ctxOK := GetAuthInfo(c.Request.Context())
+				if !ok || !ctxOK || fromGin.UserID != "user-1" || fromCtx.UserID != "user-1" {
+					c.Status(http.StatusInternalServerError)
+					return
+				}
+				c.Status(http.StatusOK)
+			})
+			r.GET("/products", handlers...)
+
+			req := httptest.NewRequest(http.MethodGet, "/products", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantErrorCode == "" {
+				return
+			}
+			var body ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error != tt.wantErrorCode {
+				t.Errorf("body = %s, want error %q", w.Body, tt.wantErrorCode)
+			}
+		})
+	}
+}
+
+func TestGinRequireRolesWithoutAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/admin", GinRequireRoles("admin"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d when GinAuth did not run", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// verifierFunc adapts a function to TokenVerifier
+type verifierFunc func(ctx context.Context, token string) (*AuthInfo, error)
+
+func (f verifierFunc) VerifyToken(ctx context.Context, token string) (*AuthInfo, error) {
+	return f(ctx, token)
+}
+
+func TestGinAuthWithTokenVerifier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	verifier := verifierFunc(func(ctx context.Context, token string) (*AuthInfo, error) {
+		switch token {
+		case "admin":
+			return &AuthInfo{UserID: "user-1", Roles: []string{"admin"}}, nil
+		case "buyer":
+			return &AuthInfo{UserID: "user-2"}, nil
+		}
+		return nil, errors.New("unknown token")
+	})
+	// No JWKS source is reachable, so loading one would fail
+	a, err := NewAuthMiddleware(nil, "pool", "region", testClientID, WithJWKSSource("/nonexistent/jwks.json"), WithTokenVerifier(verifier))
+	if err != nil {
+		t.Fatalf("NewAuthMiddleware: %v", err)
+	}
+	t.Cleanup(a.Close)
+
+	r := gin.New()
+	r.GET("/admin", a.GinAuth("admin"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		token      string
+		wantStatus int
+	}{
+		{"admin", http.StatusOK},
+		{"buyer", http.StatusForbidden},
+		{"forged", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ec-recommend/backend/shared/go/observability"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	defaultJWKSMinRefetch      = time.Minute
+	jwksFetchTimeout           = 10 * time.Second
+)
+
+var errKeyNotFound = errors.New("key not found in JWKS")
+
+// jwksHTTPClient traces JWKS fetches; fetchLocked bounds them with a timeout
+var jwksHTTPClient = observability.HTTPClient(0)
+
+// JWKSCache holds a JSON Web Key Set, refreshes it in the background and
+// refetches on unknown key IDs at most once per minRefetchInterval.
+//
+// The source may be an http(s) URL (Cognito or a local stand-in), a file://
+// URL or a plain file path, so tests can run offline.
+type JWKSCache struct {
+	source             string
+	refreshInterval    time.Duration
+	minRefetchInterval time.Duration
+	staleKeyGrace      time.Duration
+	loader             KeySetLoader
+
+	mu      sync.RWMutex
+	keySet  jwk.Set
+	retired map[string]retiredKey
+
+	// fetchMu serializes fetches so concurrent misses trigger a single refetch
+	fetchMu     sync.Mutex
+	lastFetched time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// retiredKey is a key that disappeared from the key set but is still
+// accepted until the stale-key grace window ends
+type retiredKey struct {
+	key       jwk.Key
+	removedAt time.Time
+}
+
+// KeySetLoader loads a key set from somewhere other than a URL or file, such
+// as an in-process token signer
+type KeySetLoader func(ctx context.Context) (jwk.Set, error)
+
+// JWKSOption configures a JWKSCache
+type JWKSOption func(*JWKSCache)
+
+// WithKeySetLoader loads the key set with load instead of reading source,
+// which then only names the keys in traces and errors
+func WithKeySetLoader(load KeySetLoader) JWKSOption {
+	return func(c *JWKSCache) {
+		c.loader = load
+	}
+}
+
+// WithStaleKeyGrace keeps accepting keys dropped from the key set for grace
+// after they disappear, so tokens signed just before a rotation still verify
+func WithStaleKeyGrace(grace time.Duration) JWKSOption {
+	return func(c *JWKSCache) {
+		c.staleKeyGrace = grace
+	}
+}
+
+// NewJWKSCache loads the key set from source and starts the background refresher.
+// Zero intervals fall back to the defaults.
+func NewJWKSCache(source string, refreshInterval, minRefetchInterval time.Duration, opts ...JWKSOption) (*JWKSCache, error) {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJWKSRefreshInterval
+	}
+	if minRefetchInterval <= 0 {
+		minRefetchInterval = defaultJWKSMinRefetch
+	}
+
+	c := &JWKSCache{
+		source:             source,
+		refreshInterval:    refreshInterval,
+		minRefetchInterval: minRefetchInterval,
+		retired:            make(map[string]retiredKey),
+		stop:               make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	go c.refreshLoop()
+
+	return c, nil
+}
+
+// CognitoJWKSURL returns the JWKS endpoint of a Cognito user pool
+func CognitoJWKSURL(region, userPoolID string) string {
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json", region, userPoolID)
+}
+
+// Refresh fetches the key set from the source and swaps it in
+func (c *JWKSCache) Refresh(ctx context.Context) error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	return c.fetchLocked(ctx)
+}
+
+func (c *JWKSCache) fetchLocked(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+
+	c.lastFetched = time.Now()
+
+	ctx, span := observability.StartSpan(ctx, "jwks.fetch", attribute.String("jwks.source", c.source))
+	keySet, err := c.load(ctx)
+	observability.EndSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	c.swapKeySet(keySet)
+	return nil
+}
+
+// swapKeySet installs a new key set, moving keys that were dropped into the
+// retired list while the stale-key grace lasts
+func (c *JWKSCache) swapKeySet(keySet jwk.Set) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if c.keySet != nil && c.staleKeyGrace > 0 {
+		for it := c.keySet.Keys(context.Background()); it.Next(context.Background()); {
+			key := it.Pair().Value.(jwk.Key)
+			if _, found := keySet.LookupKeyID(key.KeyID()); !found {
+				if _, already := c.retired[key.KeyID()]; !already {
+					c.retired[key.KeyID()] = retiredKey{key: key, removedAt: now}
+				}
+			}
+		}
+	}
+
+	for kid, rk := range c.retired {
+		_, back := keySet.LookupKeyID(kid)
+		if back || now.Sub(rk.removedAt) > c.staleKeyGrace {
+			delete(c.retired, kid)
+		}
+	}
+
+	c.keySet = keySet
+}
+
+func (c *JWKSCache) load(ctx context.Context) (jwk.Set, error) {
+	if c.loader != nil {
+		return c.loader(ctx)
+	}
+
+	if strings.HasPrefix(c.source, "http://") || strings.HasPrefix(c.source, "https://") {
+		return jwk.Fetch(ctx, c.source, jwk.WithHTTPClient(jwksHTTPClient))
+	}
+
+	path := c.source
+	if strings.HasPrefix(path, "file://") {
+		u, err := url.Parse(path)
+		if err != nil {
+			return nil, err
+		}
+		path = u.Path
+	}
+
+	return jwk.ReadFile(path)
+}
+
+// PublicKey returns the RSA public key for kid. An unknown kid triggers a
+// refetch unless one happened within minRefetchInterval.
+func (c *JWKSCache) PublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	key, ok := c.lookup(kid)
+	if !ok {
+		if err := c.refetch(ctx); err != nil {
+			return nil, err
+		}
+		if key, ok = c.lookup(kid); !ok {
+			return nil, errKeyNotFound
+		}
+	}
+
+	var rsaKey rsa.PublicKey
+	if err := key.Raw(&rsaKey); err != nil {
+		return nil, fmt.Errorf("failed to parse RSA key: %w", err)
+	}
+
+	return &rsaKey, nil
+}
+
+// lookup finds kid in the current key set or among keys still in their
+// grace window
+func (c *JWKSCache) lookup(kid string) (jwk.Key, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if key, found := c.keySet.LookupKeyID(kid); found {
+		return key, true
+	}
+
+	if rk, found := c.retired[kid]; found && time.Since(rk.removedAt) <= c.staleKeyGrace {
+		return rk.key, true
+	}
+
+	return nil, false
+}
+
+// refetch reloads the key set for an unknown kid, rate limited so forged
+// tokens with random kids cannot hammer the JWKS endpoint
+func (c *JWKSCache) refetch(ctx context.Context) error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	if time.Since(c.lastFetched) < c.minRefetchInterval {
+		// Either refetched very recently or another caller just did
+		return nil
+	}
+
+	return c.fetchLocked(ctx)
+}
+
+func (c *JWKSCache) refreshLoop() {
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Refresh(context.Background()); err != nil {
+				// Keep serving the previous key set until the next tick
+				log.Printf("JWKS refresh failed: %v", err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background refresher
+func (c *JWKSCache) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
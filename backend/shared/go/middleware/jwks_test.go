@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// keySets is a KeySetLoader publishing whichever keys are current
+type keySets struct {
+	mu      sync.Mutex
+	kids    []string
+	fetches int
+}
+
+func (k *keySets) publish(kids ...string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.kids = kids
+}
+
+func (k *keySets) load(ctx context.Context) (jwk.Set, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.fetches++
+	set := jwk.NewSet()
+	for _, kid := range k.kids {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			return nil, err
+		}
+		public, err := jwk.FromRaw(&key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		public.Set(jwk.KeyIDKey, kid)
+		set.AddKey(public)
+	}
+	return set, nil
+}
+
+func TestJWKSCacheLoader(t *testing.T) {
+	keys := &keySets{}
+	keys.publish("key-1")
+
+	c, err := NewJWKSCache("in-process", time.Hour, time.Nanosecond, WithKeySetLoader(keys.load))
+	if err != nil {
+		t.Fatalf("NewJWKSCache: %v", err)
+	}
+	t.Cleanup(c.Close)
+
+	if _, err := c.PublicKey(context.Background(), "key-1"); err != nil {
+		t.Fatalf("PublicKey(key-1): %v", err)
+	}
+
+	// An unknown kid is refetched from the loader
+	keys.publish("key-1", "key-2")
+	time.Sleep(time.Millisecond)
+	if _, err := c.PublicKey(context.Background(), "key-2"); err != nil {
+		t.Fatalf("PublicKey(key-2) after rotation: %v", err)
+	}
+	if keys.fetches != 2 {
+		t.Errorf("fetches = %d, want 2", keys.fetches)
+	}
+}
+
+func TestJWKSCacheStaleKeyGrace(t *testing.T) {
+	const grace = 100 * time.Millisecond
+
+	tests := []struct {
+		name  string
+		opts  []JWKSOption
+		grace bool
+	}{
+		{"no grace", nil, false},
+		{"grace", []JWKSOption{WithStaleKeyGrace(grace)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &keySets{}
+			keys.publish("old")
+
+			c, err := NewJWKSCache("in-process", time.Hour, time.Hour, append(tt.opts, WithKeySetLoader(keys.load))...)
+			if err != nil {
+				t.Fatalf("NewJWKSCache: %v", err)
+			}
+			t.Cleanup(c.Close)
+
+			// Rotate the old key out
+			keys.publish("new")
+			if err := c.Refresh(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = c.PublicKey(context.Background(), "old")
+			if (err == nil) != tt.grace {
+				t.Fatalf("PublicKey(old) right after rotation error = %v, want accepted %v", err, tt.grace)
+			}
+
+			time.Sleep(2 * grace)
+			if _, err := c.PublicKey(context.Background(), "old"); !errors.Is(err, errKeyNotFound) {
+				t.Errorf("PublicKey(old) past the grace error = %v, want %v", err, errKeyNotFound)
+			}
+		})
+	}
+}
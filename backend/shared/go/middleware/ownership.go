@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OwnerResolver returns the seller ID owning the resource a request targets
+type OwnerResolver func(ctx context.Context, req interface{}) (string, error)
+
+// OwnerLookup maps a resource ID (e.g. a product ID) to its owning seller ID
+type OwnerLookup func(ctx context.Context, resourceID string) (string, error)
+
+// SellersLookup maps a resource ID to every seller with a stake in it, such
+// as the sellers of an order's items
+type SellersLookup func(ctx context.Context, resourceID string) ([]string, error)
+
+// OwnershipChecker rejects seller RPCs that target another seller's
+// resources. Chain its interceptors after AuthMiddleware's so the caller's
+// AuthInfo is in the context.
+type OwnershipChecker struct {
+	rules map[string]OwnerResolver
+}
+
+// NewOwnershipChecker creates a checker with no rules
+func NewOwnershipChecker() *OwnershipChecker {
+	return &OwnershipChecker{
+		rules: make(map[string]OwnerResolver),
+	}
+}
+
+// NewDefaultOwnershipChecker registers the seller-owned product and order
+// RPCs, covering every seller-role RPC in policy.yaml. productOwner resolves
+// product IDs and orderSellers order IDs for requests that only carry one.
+func NewDefaultOwnershipChecker(productOwner OwnerLookup, orderSellers SellersLookup) *OwnershipChecker {
+	o := NewOwnershipChecker()
+	o.Register("/ecommerce.product.ProductService/CreateProduct", SellerIDFromRequest)
+	o.Register("/ecommerce.product.ProductService/ListSellerProducts", SellerIDFromRequest)
+	o.Register("/ecommerce.product.ProductService/UpdateProduct", ProductOwner(productOwner))
+	o.Register("/ecommerce.product.ProductService/UpdateStock", ProductOwner(productOwner))
+	o.Register("/ecommerce.order.OrderService/UpdateOrderStatus", OrderOwner(orderSellers))
+	o.Register("/ecommerce.order.OrderService/RefundOrder", OrderOwner(orderSellers))
+	o.Register("/ecommerce.order.OrderService/ListSellerOrders", SellerIDFromRequest)
+	return o
+}
+
+// Register declares how to find the owner of fullMethod's request
+func (o *OwnershipChecker) Register(fullMethod string, resolve OwnerResolver) {
+	o.rules[fullMethod] = resolve
+}
+
+// SellerIDFromRequest reads the request's seller_id field
+func SellerIDFromRequest(ctx context.Context, req interface{}) (string, error) {
+	r, ok := req.(interface{ GetSellerId() string })
+	if !ok {
+		return "", status.Error(codes.Internal, "request has no seller_id")
+	}
+	return r.GetSellerId(), nil
+}
+
+// ProductOwner resolves the owner of the request's product_id through lookup
+func ProductOwner(lookup OwnerLookup) OwnerResolver {
+	return func(ctx context.Context, req interface{}) (string, error) {
+		r, ok := req.(interface{ GetProductId() string })
+		if !ok {
+			return "", status.Error(codes.Internal, "request has no product_id")
+		}
+		return lookup(ctx, r.GetProductId())
+	}
+}
+
+// OrderOwner resolves the owner of the request's order_id through lookup.
+// An order is owned by a seller only when all its items are theirs; orders
+// spanning several sellers resolve to no owner and are left to admins.
+func OrderOwner(lookup SellersLookup) OwnerResolver {
+	return func(ctx context.Context, req interface{}) (string, error) {
+		r, ok := req.(interface{ GetOrderId() string })
+		if !ok {
+			return "", status.Error(codes.Internal, "request has no order_id")
+		}
+
+		sellers, err := lookup(ctx, r.GetOrderId())
+		if err != nil {
+			return "", err
+		}
+		if len(sellers) == 0 {
+			return "", nil
+		}
+		for _, seller := range sellers[1:] {
+			if seller != sellers[0] {
+				return "", nil
+			}
+		}
+		return sellers[0], nil
+	}
+}
+
+// UnaryServerInterceptor returns a gRPC unary interceptor enforcing ownership
+func (o *OwnershipChecker) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := o.check(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC stream interceptor enforcing
+// ownership on every message the client sends
+func (o *OwnershipChecker) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if _, ok := o.rules[info.FullMethod]; !ok {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &ownershipServerStream{ServerStream: ss, checker: o, fullMethod: info.FullMethod})
+	}
+}
+
+// ownershipServerStream checks each received message before the handler sees it
+type ownershipServerStream struct {
+	grpc.ServerStream
+	checker    *OwnershipChecker
+	fullMethod string
+}
+
+func (s *ownershipServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.checker.check(s.Context(), s.fullMethod, m)
+}
+
+func (o *OwnershipChecker) check(ctx context.Context, fullMethod string, req interface{}) error {
+	resolve, ok := o.rules[fullMethod]
+	if !ok {
+		return nil
+	}
+
+	authInfo, ok := GetAuthInfo(ctx)
+	if !ok {
+		return status.Error(codes.PermissionDenied, "authentication required")
+	}
+
+	// Admins may act on any seller's resources
+	if containsString(authInfo.Roles, "admin") {
+		return nil
+	}
+
+	owner, err := resolve(ctx, req)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, "failed to resolve resource owner")
+	}
+
+	if authInfo.SellerID == "" || owner != authInfo.SellerID {
+		return status.Error(codes.PermissionDenied, "resource belongs to another seller")
+	}
+
+	return nil
+}
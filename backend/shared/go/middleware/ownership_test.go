@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type sellerRequest struct{ sellerID string }
+
+func (r sellerRequest) GetSellerId() string { return r.sellerID }
+
+type productRequest struct{ productID string }
+
+func (r productRequest) GetProductId() string { return r.productID }
+
+type orderRequest struct{ orderID string }
+
+func (r orderRequest) GetOrderId() string { return r.orderID }
+
+var (
+	testProducts = map[string]string{"p-alice": "alice", "p-bob": "bob"}
+	testOrders   = map[string][]string{
+		"o-alice":  {"alice", "alice"},
+		"o-shared": {"alice", "bob"},
+		"o-empty":  nil,
+	}
+	errLookupFailed = errors.New("lookup failed")
+)
+
+func testProductOwner(ctx context.Context, id string) (string, error) {
+	if id == "p-broken" {
+		return "", errLookupFailed
+	}
+	return testProducts[id], nil
+}
+
+func testOrderSellers(ctx context.Context, id string) ([]string, error) {
+	if id == "o-broken" {
+		return nil, errLookupFailed
+	}
+	return testOrders[id], nil
+}
+
+func TestOwnershipCheck(t *testing.T) {
+	checker := NewDefaultOwnershipChecker(testProductOwner, testOrderSellers)
+
+	alice := &AuthInfo{UserID: "u1", Roles: []string{"seller"}, SellerID: "alice"}
+	noSellerID := &AuthInfo{UserID: "u2", Roles: []string{"seller"}}
+	admin := &AuthInfo{UserID: "u3", Roles: []string{"admin"}}
+
+	const (
+		createProduct     = "/ecommerce.product.ProductService/CreateProduct"
+		updateProduct     = "/ecommerce.product.ProductService/UpdateProduct"
+		updateOrderStatus = "/ecommerce.order.OrderService/UpdateOrderStatus"
+		refundOrder       = "/ecommerce.order.OrderService/RefundOrder"
+	)
+
+	tests := []struct {
+		name   string
+		method string
+		caller *AuthInfo
+		req    interface{}
+		code   codes.Code
+	}{
+		{"own seller_id", createProduct, alice, sellerRequest{"alice"}, codes.OK},
+		{"other seller_id", createProduct, alice, sellerRequest{"bob"}, codes.PermissionDenied},
+		{"caller without seller_id", createProduct, noSellerID, sellerRequest{""}, codes.PermissionDenied},
+		{"own product", updateProduct, alice, productRequest{"p-alice"}, codes.OK},
+		{"other seller's product", updateProduct, alice, productRequest{"p-bob"}, codes.PermissionDenied},
+		{"unknown product", updateProduct, alice, productRequest{"p-missing"}, codes.PermissionDenied},
+		{"product lookup error", updateProduct, alice, productRequest{"p-broken"}, codes.Internal},
+		{"request without product_id", updateProduct, alice, sellerRequest{"alice"}, codes.Internal},
+		{"own order", updateOrderStatus, alice, orderRequest{"o-alice"}, codes.OK},
+		{"refund own order", refundOrder, alice, orderRequest{"o-alice"}, codes.OK},
+		{"order shared with another seller", updateOrderStatus, alice, orderRequest{"o-shared"}, codes.PermissionDenied},
+		{"order without items", refundOrder, alice, orderRequest{"o-empty"}, codes.PermissionDenied},
+		{"order lookup error", refundOrder, alice, orderRequest{"o-broken"}, codes.Internal},
+		{"admin on another seller's product", updateProduct, admin, productRequest{"p-bob"}, codes.OK},
+		{"admin on a shared order", refundOrder, admin, orderRequest{"o-shared"}, codes.OK},
+		{"unauthenticated", updateProduct, nil, productRequest{"p-alice"}, codes.PermissionDenied},
+		{"unregistered method", "/ecommerce.order.OrderService/GetOrder", alice, orderRequest{"o-shared"}, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.caller != nil {
+				ctx = withAuthInfo(ctx, tt.caller)
+			}
+
+			err := checker.check(ctx, tt.method, tt.req)
+			if got := status.Code(err); got != tt.code {
+				t.Errorf("check() code = %v, want %v (%v)", got, tt.code, err)
+			}
+		})
+	}
+}
+
+func TestOwnershipUnaryInterceptor(t *testing.T) {
+	checker := NewDefaultOwnershipChecker(testProductOwner, testOrderSellers)
+	interceptor := checker.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/ecommerce.product.ProductService/UpdateProduct"}
+	ctx := withAuthInfo(context.Background(), &AuthInfo{Roles: []string{"seller"}, SellerID: "alice"})
+
+	var called bool
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return "ok", nil
+	}
+
+	if _, err := interceptor(ctx, productRequest{"p-bob"}, info, handler); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("interceptor() = %v, want PermissionDenied", err)
+	}
+	if called {
+		t.Fatal("handler ran for another seller's product")
+	}
+
+	if _, err := interceptor(ctx, productRequest{"p-alice"}, info, handler); err != nil {
+		t.Fatalf("interceptor() = %v, want nil", err)
+	}
+	if !called {
+		t.Fatal("handler did not run for the seller's own product")
+	}
+}
+
+// fakeServerStream delivers queued requests to RecvMsg
+type fakeServerStream struct {
+	ctx  context.Context
+	reqs []orderRequest
+}
+
+func (s *fakeServerStream) SetHeader(metadata.MD) error  { return nil }
+func (s *fakeServerStream) SendHeader(metadata.MD) error { return nil }
+func (s *fakeServerStream) SetTrailer(metadata.MD)       {}
+func (s *fakeServerStream) Context() context.Context     { return s.ctx }
+func (s *fakeServerStream) SendMsg(m interface{}) error  { return nil }
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	*m.(*orderRequest) = s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return nil
+}
+
+func TestOwnershipStreamInterceptorChecksEachMessage(t *testing.T) {
+	checker := NewDefaultOwnershipChecker(testProductOwner, testOrderSellers)
+	interceptor := checker.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/ecommerce.order.OrderService/UpdateOrderStatus"}
+
+	stream := &fakeServerStream{
+		ctx:  withAuthInfo(context.Background(), &AuthInfo{Roles: []string{"seller"}, SellerID: "alice"}),
+		reqs: []orderRequest{{"o-alice"}, {"o-shared"}},
+	}
+
+	var errs []error
+	err := interceptor(nil, stream, info, func(srv interface{}, ss grpc.ServerStream) error {
+		for i := 0; i < 2; i++ {
+			var req orderRequest
+			errs = append(errs, ss.RecvMsg(&req))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor() = %v", err)
+	}
+
+	if errs[0] != nil {
+		t.Errorf("first message: %v, want nil", errs[0])
+	}
+	if status.Code(errs[1]) != codes.PermissionDenied {
+		t.Errorf("second message: %v, want PermissionDenied", errs[1])
+	}
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed policy.yaml
+var defaultPolicyYAML []byte
+
+// Access is the authentication level a method requires
+type Access string
+
+const (
+	AccessPublic        Access = "public"
+	AccessAuthenticated Access = "authenticated"
+)
+
+// Rule describes who may call a method. Callers need at least one of Roles
+// (when set) and all of Permissions.
+type Rule struct {
+	Access      Access   `yaml:"access"`
+	Roles       []string `yaml:"roles"`
+	Permissions []string `yaml:"permissions"`
+}
+
+// Policy maps fully-qualified gRPC method names to rules. Keys are either
+// exact ("/pkg.Service/Method") or service wildcards ("/pkg.Service/*");
+// exact rules win. Methods matching neither are denied.
+type Policy struct {
+	methods  map[string]Rule
+	services map[string]Rule
+}
+
+// ServiceInfoProvider is implemented by *grpc.Server
+type ServiceInfoProvider interface {
+	GetServiceInfo() map[string]grpc.ServiceInfo
+}
+
+// DefaultPolicy returns the policy embedded from policy.yaml
+func DefaultPolicy() (*Policy, error) {
+	return ParsePolicy(defaultPolicyYAML)
+}
+
+// LoadPolicy reads a YAML policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses a YAML policy document
+func ParsePolicy(data []byte) (*Policy, error) {
+	var doc struct {
+		Methods map[string]Rule `yaml:"methods"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	p := &Policy{
+		methods:  make(map[string]Rule),
+		services: make(map[string]Rule),
+	}
+
+	for key, rule := range doc.Methods {
+		if rule.Access == "" {
+			rule.Access = AccessAuthenticated
+		}
+		if rule.Access != AccessPublic && rule.Access != AccessAuthenticated {
+			return nil, fmt.Errorf("policy %s: unknown access %q", key, rule.Access)
+		}
+		if rule.Access == AccessPublic && (len(rule.Roles) > 0 || len(rule.Permissions) > 0) {
+			return nil, fmt.Errorf("policy %s: public methods cannot require roles or permissions", key)
+		}
+
+		service, method, ok := splitMethod(key)
+		if !ok {
+			return nil, fmt.Errorf("policy %s: expected /package.Service/Method", key)
+		}
+
+		if method == "*" {
+			p.services[service] = rule
+		} else {
+			p.methods[key] = rule
+		}
+	}
+
+	return p, nil
+}
+
+// Lookup returns the rule for a fully-qualified method name
+func (p *Policy) Lookup(fullMethod string) (Rule, bool) {
+	if rule, ok := p.methods[fullMethod]; ok {
+		return rule, true
+	}
+
+	service, _, ok := splitMethod(fullMethod)
+	if !ok {
+		return Rule{}, false
+	}
+
+	rule, ok := p.services[service]
+	return rule, ok
+}
+
+// Validate returns an error listing every registered method without a rule.
+// Call it at startup after registering services.
+func (p *Policy) Validate(server ServiceInfoProvider) error {
+	var missing []string
+	for service, info := range server.GetServiceInfo() {
+		for _, m := range info.Methods {
+			fullMethod := "/" + service + "/" + m.Name
+			if _, ok := p.Lookup(fullMethod); !ok {
+				missing = append(missing, fullMethod)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no access policy for methods: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// Allows reports whether an authenticated caller satisfies the rule
+func (r Rule) Allows(authInfo *AuthInfo) bool {
+	if len(r.Roles) > 0 {
+		var hasRole bool
+		for _, role := range r.Roles {
+			if containsString(authInfo.Roles, role) {
+				hasRole = true
+				break
+			}
+		}
+		if !hasRole {
+			return false
+		}
+	}
+
+	for _, perm := range r.Permissions {
+		if !containsString(authInfo.Permissions, perm) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func splitMethod(fullMethod string) (service, method string, ok bool) {
+	if !strings.HasPrefix(fullMethod, "/") {
+		return "", "", false
+	}
+
+	i := strings.LastIndex(fullMethod, "/")
+	if i <= 1 || i == len(fullMethod)-1 {
+		return "", "", false
+	}
+
+	return fullMethod[1:i], fullMethod[i+1:], true
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testPolicyYAML = `
+methods:
+  /test.Shop/*:
+    access: authenticated
+  /test.Shop/Browse:
+    access: public
+  /test.Shop/Restock:
+    roles: [seller, admin]
+  /test.Shop/Audit:
+    roles: [admin]
+    permissions: [audit.read, audit.export]
+`
+
+func mustParsePolicy(t *testing.T, doc string) *Policy {
+	t.Helper()
+	policy, err := ParsePolicy([]byte(doc))
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	return policy
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"invalid yaml", "methods: [", "failed to parse policy"},
+		{"unknown access", "methods:\n  /test.Shop/Browse:\n    access: anyone\n", "unknown access"},
+		{"public with roles", "methods:\n  /test.Shop/Browse:\n    access: public\n    roles: [admin]\n", "public methods cannot require"},
+		{"public with permissions", "methods:\n  /test.Shop/Browse:\n    access: public\n    permissions: [read]\n", "public methods cannot require"},
+		{"no leading slash", "methods:\n  test.Shop/Browse: {}\n", "expected /package.Service/Method"},
+		{"no method", "methods:\n  /test.Shop/: {}\n", "expected /package.Service/Method"},
+		{"no service", "methods:\n  /Browse: {}\n", "expected /package.Service/Method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ParsePolicy() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyLookup(t *testing.T) {
+	policy := mustParsePolicy(t, testPolicyYAML)
+
+	tests := []struct {
+		method     string
+		wantOK     bool
+		wantAccess Access
+		wantRoles  []string
+	}{
+		{"/test.Shop/Browse", true, AccessPublic, nil},
+		{"/test.Shop/Restock", true, AccessAuthenticated, []string{"seller", "admin"}},
+		// Falls back to the service wildcard
+		{"/test.Shop/Checkout", true, AccessAuthenticated, nil},
+		// Anything else is denied by default
+		{"/test.Other/Browse", false, "", nil},
+		{"/test.ShopAdmin/Browse", false, "", nil},
+		{"/test.Shop", false, "", nil},
+		{"test.Shop/Browse", false, "", nil},
+		{"", false, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rule, ok := policy.Lookup(tt.method)
+			if ok != tt.wantOK {
+				t.Fatalf("Lookup() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if rule.Access != tt.wantAccess {
+				t.Errorf("Access = %q, want %q", rule.Access, tt.wantAccess)
+			}
+			if strings.Join(rule.Roles, ",") != strings.Join(tt.wantRoles, ",") {
+				t.Errorf("Roles = %v, want %v", rule.Roles, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestRuleAllows(t *testing.T) {
+	policy := mustParsePolicy(t, testPolicyYAML)
+
+	tests := []struct {
+		name   string
+		method string
+		info   AuthInfo
+		want   bool
+	}{
+		{"no requirements", "/test.Shop/Checkout", AuthInfo{}, true},
+		{"one of the roles", "/test.Shop/Restock", AuthInfo{Roles: []string{"seller"}}, true},
+		{"another of the roles", "/test.Shop/Restock", AuthInfo{Roles: []string{"buyer", "admin"}}, true},
+		{"missing role", "/test.Shop/Restock", AuthInfo{Roles: []string{"buyer"}}, false},
+		{"role and all permissions", "/test.Shop/Audit", AuthInfo{Roles: []string{"admin"}, Permissions: []string{"audit.export", "audit.read"}}, true},
+		{"role and some permissions", "/test.Shop/Audit", AuthInfo{Roles: []string{"admin"}, Permissions: []string{"audit.read"}}, false},
+		{"permissions without role", "/test.Shop/Audit", AuthInfo{Permissions: []string{"audit.read", "audit.export"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := policy.Lookup(tt.method)
+			if !ok {
+				t.Fatalf("no rule for %s", tt.method)
+			}
+			if got := rule.Allows(&tt.info); got != tt.want {
+				t.Errorf("Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeServiceInfo map[string]grpc.ServiceInfo
+
+func (f fakeServiceInfo) GetServiceInfo() map[string]grpc.ServiceInfo {
+	return f
+}
+
+func TestPolicyValidate(t *testing.T) {
+	policy := mustParsePolicy(t, testPolicyYAML)
+
+	covered := fakeServiceInfo{
+		"test.Shop": {Methods: []grpc.MethodInfo{{Name: "Browse"}, {Name: "Anything"}}},
+	}
+	if err := policy.Validate(covered); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+
+	uncovered := fakeServiceInfo{
+		"test.Shop":  {Methods: []grpc.MethodInfo{{Name: "Browse"}}},
+		"test.Other": {Methods: []grpc.MethodInfo{{Name: "B"}, {Name: "A"}}},
+	}
+	err := policy.Validate(uncovered)
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error for test.Other")
+	}
+	if want := "/test.Other/A, /test.Other/B"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Validate() = %v, want it to list %q", err, want)
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	policy, err := DefaultPolicy()
+	if err != nil {
+		t.Fatalf("DefaultPolicy: %v", err)
+	}
+
+	tests := []struct {
+		method string
+		wantOK bool
+		access Access
+	}{
+		{"/grpc.health.v1.Health/Check", true, AccessPublic},
+		{"/ecommerce.product.ProductService/GetProduct", true, AccessPublic},
+		{"/ecommerce.product.ProductService/CreateProduct", true, AccessAuthenticated},
+		{"/ecommerce.order.OrderService/CreateOrder", true, AccessAuthenticated},
+		{"/ecommerce.product.ProductService/DeleteEverything", false, ""},
+		{"/ecommerce.unknown.Service/Method", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rule, ok := policy.Lookup(tt.method)
+			if ok != tt.wantOK || rule.Access != tt.access {
+				t.Errorf("Lookup() = (%q, %v), want (%q, %v)", rule.Access, ok, tt.access, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAuthenticateDeniesMethodsWithoutPolicy(t *testing.T) {
+	a := &AuthMiddleware{policy: mustParsePolicy(t, testPolicyYAML)}
+
+	tests := []struct {
+		name   string
+		method string
+		code   codes.Code
+	}{
+		{"unknown method", "/test.Other/Browse", codes.PermissionDenied},
+		{"authenticated without token", "/test.Shop/Checkout", codes.Unauthenticated},
+		{"public without token", "/test.Shop/Browse", codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.authenticate(context.Background(), tt.method)
+			if got := status.Code(err); got != tt.code {
+				t.Errorf("authenticate() code = %v, want %v (%v)", got, tt.code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RevocationList reports tokens revoked before they expire, e.g. when
+// auth-service signs a user out everywhere or takes a role away
+type RevocationList interface {
+	IsRevoked(ctx context.Context, jti, subject string, issuedAt time.Time) (bool, error)
+}
+
+// Keys written by auth-service's Redis denylist: a revoked jti maps to "1",
+// a revoked subject to the Unix time before which its tokens are rejected
+const (
+	revokedTokenPrefix   = "auth:revoked:jti:"
+	revokedSubjectPrefix = "auth:revoked:sub:"
+)
+
+// RedisRevocationList reads the revocation list auth-service keeps in Redis
+type RedisRevocationList struct {
+	client *redis.Client
+}
+
+// NewRedisRevocationList connects to the Redis server at url (redis://...)
+func NewRedisRevocationList(url string) (*RedisRevocationList, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %v", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return &RedisRevocationList{client: client}, nil
+}
+
+func (r *RedisRevocationList) IsRevoked(ctx context.Context, jti, subject string, issuedAt time.Time) (bool, error) {
+	values, err := r.client.MGet(ctx, revokedTokenPrefix+jti, revokedSubjectPrefix+subject).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+
+	if jti != "" && values[0] != nil {
+		return true, nil
+	}
+
+	if s, ok := values[1].(string); ok {
+		revokedAt, err := strconv.ParseInt(s, 10, 64)
+		if err == nil && issuedAt.Unix() < revokedAt {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *RedisRevocationList) Close() error {
+	return r.client.Close()
+}
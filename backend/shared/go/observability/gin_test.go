@@ -0,0 +1,49 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGinRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var seen string
+	r := gin.New()
+	r.Use(GinRequestID())
+	r.GET("/", func(c *gin.Context) {
+		seen = RequestIDFromContext(c.Request.Context())
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		wantKept bool
+	}{
+		{"kept", "req-1", true},
+		{"generated", "", false},
+		{"unsafe replaced", "req\t1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			echoed := w.Header().Get(RequestIDHeader)
+			if echoed == "" || echoed != seen {
+				t.Errorf("response ID %q, context ID %q; want the same ID", echoed, seen)
+			}
+			if kept := echoed == tt.incoming; kept != tt.wantKept {
+				t.Errorf("request ID = %q, kept = %v, want %v", echoed, kept, tt.wantKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package observability
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServerOptions traces every RPC, reads or generates its request ID and
+// logs it once served. Add them before the auth interceptors so rejected
+// calls are traced and logged too.
+func GRPCServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unaryServerInterceptor),
+		grpc.ChainStreamInterceptor(streamServerInterceptor),
+	}
+}
+
+// GRPCDialOptions traces outgoing RPCs and forwards the request ID of the
+// call's context
+func GRPCDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(unaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(streamClientInterceptor),
+	}
+}
+
+func unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = incomingRequestID(ctx)
+	start := time.Now()
+
+	resp, err := handler(ctx, req)
+	logRPC(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := incomingRequestID(ss.Context())
+	start := time.Now()
+
+	err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+	logRPC(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// incomingRequestID takes the request ID from the call's metadata, or
+// generates one, and returns it to the caller in the response header
+func incomingRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	id = requestIDOrNew(id)
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+	return WithRequestID(ctx, id)
+}
+
+func logRPC(ctx context.Context, method string, start time.Time, err error) {
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+
+	slog.LogAttrs(ctx, level, "rpc",
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+	)
+}
+
+// contextServerStream overrides the stream context with one carrying the
+// request ID
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func unaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+func streamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+}
+
+// outgoingRequestID adds the context's request ID to the call's metadata
+// unless the caller set one
+func outgoingRequestID(ctx context.Context) context.Context {
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(RequestIDMetadataKey)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, id)
+}
@@ -0,0 +1,84 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// contextStream is a server stream with a fixed context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestServerInterceptorsRequestID(t *testing.T) {
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "req-1"))
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantKept bool
+	}{
+		{"from metadata", incoming, true},
+		{"generated", context.Background(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/unary", func(t *testing.T) {
+			var got string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				got = RequestIDFromContext(ctx)
+				return nil, nil
+			}
+			unaryServerInterceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Shop/Get"}, handler)
+
+			if got == "" || (got == "req-1") != tt.wantKept {
+				t.Errorf("request ID = %q, want kept = %v", got, tt.wantKept)
+			}
+		})
+
+		t.Run(tt.name+"/stream", func(t *testing.T) {
+			var got string
+			handler := func(srv interface{}, ss grpc.ServerStream) error {
+				got = RequestIDFromContext(ss.Context())
+				return nil
+			}
+			streamServerInterceptor(nil, &contextStream{ctx: tt.ctx}, &grpc.StreamServerInfo{FullMethod: "/test.Shop/Watch"}, handler)
+
+			if got == "" || (got == "req-1") != tt.wantKept {
+				t.Errorf("request ID = %q, want kept = %v", got, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestOutgoingRequestID(t *testing.T) {
+	withID := WithRequestID(context.Background(), "req-1")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{"forwarded", withID, []string{"req-1"}},
+		{"caller's metadata wins", metadata.AppendToOutgoingContext(withID, RequestIDMetadataKey, "req-2"), []string{"req-2"}},
+		{"no request ID", context.Background(), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, _ := metadata.FromOutgoingContext(outgoingRequestID(tt.ctx))
+			got := md.Get(RequestIDMetadataKey)
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("outgoing %s = %v, want %v", RequestIDMetadataKey, got, tt.want)
+			}
+		})
+	}
+}
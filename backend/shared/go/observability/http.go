@@ -0,0 +1,25 @@
+package observability
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Transport wraps base (http.DefaultTransport when nil) so each request
+// gets a client span, trace context headers and the context's X-Request-ID
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(requestIDTransport{base: base})
+}
+
+// HTTPClient returns a client using Transport
+func HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: Transport(nil),
+		Timeout:   timeout,
+	}
+}
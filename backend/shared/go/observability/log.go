@@ -0,0 +1,74 @@
+// Package observability provides the structured logging, request IDs and
+// OpenTelemetry tracing shared by the Go services, as specified in
+// docs/COMMUNICATION_DESIGN.md.
+package observability
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ParseLevel maps a LOG_LEVEL value (debug, info, warn, error) to a level,
+// defaulting to info
+func ParseLevel(value string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// NewLogger writes JSON lines at level to w. Records logged with a context
+// carry its request ID and trace and span IDs.
+func NewLogger(w io.Writer, service string, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(contextHandler{handler}).With("service", service)
+}
+
+// SetupLogging makes a JSON logger at LOG_LEVEL the default for slog and
+// for the standard log package, so existing log.Printf calls become
+// structured too
+func SetupLogging(service string) *slog.Logger {
+	logger := NewLogger(os.Stdout, service, ParseLevel(os.Getenv("LOG_LEVEL")))
+	slog.SetDefault(logger)
+	// slog.SetDefault routes the log package through logger; drop its own
+	// timestamp prefix since the JSON record has one
+	log.SetFlags(0)
+	return logger
+}
+
+// contextHandler adds request and trace correlation to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,77 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{" DEBUG ", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"warn", slog.LevelWarn},
+		{"warning", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"", slog.LevelInfo},
+		{"verbose", slog.LevelInfo},
+	}
+
+	for _, tt := range tests {
+		if got := ParseLevel(tt.value); got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerCorrelation(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := trace.SpanIDFromHex("0102030405060708")
+	span := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]string
+	}{
+		{"plain", context.Background(), map[string]string{"request_id": "", "trace_id": "", "span_id": ""}},
+		{"request ID", WithRequestID(context.Background(), "req-1"), map[string]string{"request_id": "req-1", "trace_id": ""}},
+		{"span", trace.ContextWithSpanContext(context.Background(), span), map[string]string{"trace_id": traceID.String(), "span_id": spanID.String()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := NewLogger(&buf, "test-service", slog.LevelInfo).With("component", "test")
+			logger.InfoContext(tt.ctx, "hello")
+
+			var record map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("log line %q: %v", buf.String(), err)
+			}
+			if record["service"] != "test-service" || record["component"] != "test" || record["msg"] != "hello" {
+				t.Errorf("record = %v, want the service, attributes and message", record)
+			}
+			for key, want := range tt.want {
+				got, _ := record[key].(string)
+				if got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	NewLogger(&buf, "test-service", slog.LevelWarn).Info("dropped")
+	if buf.Len() != 0 {
+		t.Errorf("logged %q below the level", buf.String())
+	}
+}
@@ -0,0 +1,67 @@
+package observability
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	// RequestIDHeader carries the request ID over HTTP
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDMetadataKey carries it in gRPC metadata, which is lower case
+	RequestIDMetadataKey = "x-request-id"
+
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the context's request ID, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random 128-bit ID in hex
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDOrNew keeps an incoming ID if it is reasonable to log and
+// forward, and generates one otherwise
+func requestIDOrNew(id string) string {
+	if id == "" || len(id) > maxRequestIDLength {
+		return NewRequestID()
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return NewRequestID()
+		}
+	}
+	return id
+}
+
+// requestIDTransport sets X-Request-ID on outgoing requests from their
+// context
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id := RequestIDFromContext(req.Context()); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,80 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDOrNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		wantKept bool
+	}{
+		{"kept", "req-123", true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"longest kept", strings.Repeat("a", maxRequestIDLength), true},
+		{"space", "req 123", false},
+		{"newline", "req\n123", false},
+		{"non-ASCII", "リクエスト", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := requestIDOrNew(tt.id)
+			if kept := got == tt.id; kept != tt.wantKept {
+				t.Errorf("requestIDOrNew(%q) = %q, kept = %v, want %v", tt.id, got, kept, tt.wantKept)
+			}
+			if !tt.wantKept && len(got) != 32 {
+				t.Errorf("generated ID %q, want 32 hex characters", got)
+			}
+		})
+	}
+}
+
+func TestTransportForwardsRequestID(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(RequestIDHeader)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: Transport(nil)}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		header string
+		want   string
+	}{
+		{"from context", WithRequestID(context.Background(), "req-1"), "", "req-1"},
+		{"caller's header wins", WithRequestID(context.Background(), "req-1"), "req-2", "req-2"},
+		{"no request ID", context.Background(), "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(tt.ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if got != tt.want {
+				t.Errorf("%s = %q, want %q", RequestIDHeader, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the spans this package starts
+const instrumentationName = "github.com/ec-recommend/backend/shared/go/observability"
+
+// SetupTracing installs the global tracer provider and W3C trace context
+// propagation. OTEL_TRACES_EXPORTER picks the exporter:
+//
+//   - none (default): spans are created for propagation but not exported
+//   - stdout: spans are printed as JSON
+//   - otlp: spans go to a collector, over OTEL_EXPORTER_OTLP_PROTOCOL grpc
+//     (default) or http/protobuf, at OTEL_EXPORTER_OTLP_ENDPOINT
+//
+// The standard OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES and
+// OTEL_TRACES_SAMPLER settings apply. The returned function flushes and
+// stops the exporter.
+func SetupTracing(ctx context.Context, service string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attributes from the environment override the service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", service)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %v", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch name := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); name {
+	case "", "none":
+		return nil, nil
+	case "stdout", "console":
+		return stdouttrace.New()
+	case "otlp":
+		switch protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol {
+		case "", "grpc":
+			return otlptracegrpc.New(ctx)
+		case "http/protobuf":
+			return otlptracehttp.New(ctx)
+		default:
+			return nil, fmt.Errorf("unsupported OTEL_EXPORTER_OTLP_PROTOCOL %q", protocol)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q", name)
+	}
+}
+
+// tracer returns the global provider's tracer for this package's spans
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// StartSpan starts a span under ctx's span, for work worth timing on its
+// own such as a JWKS fetch
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records err on span, if any, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,10 @@
+-- Seller onboarding: link each seller to the Cognito user who applied and
+-- record why an application was rejected or a seller suspended
+
+-- ADD VALUE can't run inside a transaction block with later uses of the value
+ALTER TYPE seller_status ADD VALUE IF NOT EXISTS 'rejected';
+
+ALTER TABLE sellers ADD COLUMN cognito_user_id VARCHAR(255) UNIQUE;
+ALTER TABLE sellers ADD COLUMN status_reason TEXT;
+
+CREATE INDEX idx_sellers_status ON sellers(status);
@@ -0,0 +1,16 @@
+-- WebAuthn passkeys registered through auth-service. user_id is the Cognito
+-- username the passkey signs in as; credential is the go-webauthn
+-- credential as JSON, including its signature counter.
+
+CREATE TABLE passkey_credentials (
+    id BYTEA PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    credential JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_passkey_credentials_user_id ON passkey_credentials(user_id);
+
+CREATE TRIGGER update_passkey_credentials_updated_at BEFORE UPDATE ON passkey_credentials
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,17 @@
+-- Account deletions requested through auth-service, kept for the grace
+-- period until they are carried out or cancelled. subject is the Cognito
+-- sub (users.cognito_user_id); leased_until hides a due deletion from other
+-- instances while one carries it out.
+
+CREATE TABLE account_deletions (
+    subject VARCHAR(255) PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    pool VARCHAR(50),
+    requested_at TIMESTAMP NOT NULL,
+    delete_at TIMESTAMP NOT NULL,
+    user_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    leased_until TIMESTAMP
+);
+
+CREATE INDEX idx_account_deletions_delete_at ON account_deletions(delete_at);
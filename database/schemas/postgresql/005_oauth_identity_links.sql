@@ -0,0 +1,16 @@
+-- Social login identities linked to an account by auth-service. username
+-- is the Cognito username the provider's subject signs in as.
+
+CREATE TABLE oauth_identity_links (
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX idx_oauth_identity_links_username ON oauth_identity_links(username);
+
+CREATE TRIGGER update_oauth_identity_links_updated_at BEFORE UPDATE ON oauth_identity_links
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
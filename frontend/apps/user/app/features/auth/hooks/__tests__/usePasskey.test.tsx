@@ -1,7 +1,7 @@
 import { renderHook, act } from '@testing-library/react';
 import { describe, it, expect, vi, beforeEach } from 'vitest';
 
-import { apiClient } from '@/lib/api';
+import { authApiClient } from '@/lib/auth/api';
 
 import { usePasskey } from '../usePasskey';
 
@@ -17,17 +17,20 @@ vi.mock('@/lib/auth/passkey', () => ({
   PasskeyService: vi.fn(() => mockPasskeyService),
 }));
 
-// Mock API client
-vi.mock('@/lib/api', () => ({
-  apiClient: {
-    post: vi.fn(),
-    get: vi.fn(),
+// Mock auth API client
+vi.mock('@/lib/auth/api', () => ({
+  authApiClient: {
+    passkeyRegisterBegin: vi.fn(),
+    passkeyRegisterComplete: vi.fn(),
+    passkeyAuthenticateBegin: vi.fn(),
+    passkeyAuthenticateComplete: vi.fn(),
   },
 }));
 
 describe('usePasskey', () => {
   beforeEach(() => {
     vi.clearAllMocks();
+    localStorage.clear();
   });
 
   it('should check if WebAuthn is supported', () => {
@@ -36,8 +39,13 @@ describe('usePasskey', () => {
   });
 
   describe('registerPasskey', () => {
-    it('should register a new passkey successfully', async () => {
-      const mockChallenge = { challenge: 'test-challenge' };
+    it('should register a new passkey with the server options', async () => {
+      const publicKey = {
+        challenge: 'test-challenge',
+        rp: { name: 'ECレコメンド', id: 'localhost' },
+        user: { id: 'c2VydmVyLXVzZXItaGFuZGxl', name: 'user@example.com', displayName: 'user@example.com' },
+        pubKeyCredParams: [{ alg: -7, type: 'public-key' }],
+      };
       const mockCredential = {
         id: 'credential-id',
         response: {
@@ -47,36 +55,51 @@ describe('usePasskey', () => {
         },
       };
 
-      vi.mocked(apiClient.post).mockResolvedValueOnce(mockChallenge);
-      vi.mocked(apiClient.post).mockResolvedValueOnce({ success: true });
+      localStorage.setItem('accessToken', 'access-token');
+      vi.mocked(authApiClient.passkeyRegisterBegin).mockResolvedValueOnce({
+        challenge: 'test-challenge',
+        options: { publicKey },
+      } as never);
+      vi.mocked(authApiClient.passkeyRegisterComplete).mockResolvedValueOnce({ success: true });
 
       mockPasskeyService.createPasskey.mockResolvedValueOnce(mockCredential);
 
       const { result } = renderHook(() => usePasskey());
 
       await act(async () => {
-        await result.current.registerPasskey('user-id', 'user@example.com');
+        await result.current.registerPasskey('user-id');
       });
 
       expect(result.current.isLoading).toBe(false);
       expect(result.current.error).toBeNull();
-      expect(apiClient.post).toHaveBeenCalledWith('/auth/passkey/register/begin', {
-        userId: 'user-id',
-        userName: 'user@example.com',
-      });
-      expect(apiClient.post).toHaveBeenCalledWith('/auth/passkey/register/complete', {
-        userId: 'user-id',
-        credential: mockCredential,
+      expect(authApiClient.passkeyRegisterBegin).toHaveBeenCalledWith('access-token');
+      expect(mockPasskeyService.createPasskey).toHaveBeenCalledWith(publicKey);
+      expect(authApiClient.passkeyRegisterComplete).toHaveBeenCalledWith(
+        'access-token',
+        'user-id',
+        mockCredential
+      );
+    });
+
+    it('should fail without an access token', async () => {
+      const { result } = renderHook(() => usePasskey());
+
+      await act(async () => {
+        await result.current.registerPasskey('user-id');
       });
+
+      expect(authApiClient.passkeyRegisterBegin).not.toHaveBeenCalled();
+      expect(result.current.error).toBe('パスキーの登録に失敗しました');
     });
 
     it('should handle registration error', async () => {
-      vi.mocked(apiClient.post).mockRejectedValueOnce(new Error('Registration failed'));
+      localStorage.setItem('accessToken', 'access-token');
+      vi.mocked(authApiClient.passkeyRegisterBegin).mockRejectedValueOnce(new Error('Registration failed'));
 
       const { result } = renderHook(() => usePasskey());
 
       await act(async () => {
-        await result.current.registerPasskey('user-id', 'user@example.com');
+        await result.current.registerPasskey('user-id');
       });
 
       expect(result.current.isLoading).toBe(false);
@@ -86,7 +109,11 @@ describe('usePasskey', () => {
 
   describe('authenticateWithPasskey', () => {
     it('should authenticate with passkey successfully', async () => {
-      const mockChallenge = { challenge: 'test-challenge' };
+      const publicKey = {
+        challenge: 'test-challenge',
+        rpId: 'localhost',
+        userVerification: 'preferred',
+      };
       const mockCredential = {
         id: 'credential-id',
         response: {
@@ -96,12 +123,22 @@ describe('usePasskey', () => {
         },
       };
       const mockAuthResponse = {
-        user: { id: 'user-id', email: 'user@example.com' },
-        tokens: { idToken: 'id-token', accessToken: 'access-token' },
+        accessToken: 'access-token',
+        idToken: 'id-token',
+        refreshToken: 'refresh-token',
+        user: {
+          id: 'user-id',
+          email: 'user@example.com',
+          emailVerified: true,
+          attributes: {},
+        },
       };
 
-      vi.mocked(apiClient.post).mockResolvedValueOnce(mockChallenge);
-      vi.mocked(apiClient.post).mockResolvedValueOnce(mockAuthResponse);
+      vi.mocked(authApiClient.passkeyAuthenticateBegin).mockResolvedValueOnce({
+        challenge: 'test-challenge',
+        options: { publicKey },
+      } as never);
+      vi.mocked(authApiClient.passkeyAuthenticateComplete).mockResolvedValueOnce(mockAuthResponse);
 
       mockPasskeyService.authenticateWithPasskey.mockResolvedValueOnce(mockCredential);
 
@@ -115,14 +152,14 @@ describe('usePasskey', () => {
       expect(result.current.isLoading).toBe(false);
       expect(result.current.error).toBeNull();
       expect(authResult).toEqual(mockAuthResponse);
-      expect(apiClient.post).toHaveBeenCalledWith('/auth/passkey/authenticate/begin', {});
-      expect(apiClient.post).toHaveBeenCalledWith('/auth/passkey/authenticate/complete', {
-        credential: mockCredential,
-      });
+      expect(mockPasskeyService.authenticateWithPasskey).toHaveBeenCalledWith(publicKey);
+      expect(authApiClient.passkeyAuthenticateComplete).toHaveBeenCalledWith(mockCredential);
+      expect(localStorage.getItem('accessToken')).toBe('access-token');
+      expect(localStorage.getItem('idToken')).toBe('id-token');
     });
 
     it('should handle authentication error', async () => {
-      vi.mocked(apiClient.post).mockRejectedValueOnce(new Error('Authentication failed'));
+      vi.mocked(authApiClient.passkeyAuthenticateBegin).mockRejectedValueOnce(new Error('Authentication failed'));
 
       const { result } = renderHook(() => usePasskey());
 
@@ -134,4 +171,4 @@ describe('usePasskey', () => {
       expect(result.current.error).toBe('パスキーでの認証に失敗しました');
     });
   });
-});
\ No newline at end of file
+});
@@ -10,23 +10,26 @@ export function usePasskey() {
 
   const isSupported = passkeyService.checkSupport();
 
-  const registerPasskey = async (userId: string, userName: string) => {
+  const registerPasskey = async (userId: string) => {
     setIsLoading(true);
     setError(null);
 
     try {
+      const token = localStorage.getItem('accessToken');
+      if (!token) {
+        throw new Error('Not signed in');
+      }
+
       // 1. サーバーから登録チャレンジを取得
-      const beginResponse = await authApiClient.passkeyRegisterBegin(userId, userName);
+      const beginResponse = await authApiClient.passkeyRegisterBegin(token);
 
-      // 2. WebAuthnでパスキーを作成
+      // 2. サーバーのオプションでパスキーを作成
       const credential = await passkeyService.createPasskey(
-        beginResponse.challenge,
-        userId,
-        userName
+        beginResponse.options.publicKey
       );
 
       // 3. サーバーに登録を完了
-      await authApiClient.passkeyRegisterComplete(userId, credential);
+      await authApiClient.passkeyRegisterComplete(token, userId, credential);
 
       return true;
     } catch (err) {
@@ -48,18 +51,18 @@ export function usePasskey() {
 
       // 2. WebAuthnで認証
       const credential = await passkeyService.authenticateWithPasskey(
-        beginResponse.challenge
+        beginResponse.options.publicKey
       );
 
       // 3. サーバーで認証を完了
       const authResponse = await authApiClient.passkeyAuthenticateComplete(credential);
 
       // トークンをローカルストレージとCookieに保存
-      localStorage.setItem('accessToken', authResponse.tokens.accessToken);
-      localStorage.setItem('idToken', authResponse.tokens.idToken);
+      localStorage.setItem('accessToken', authResponse.accessToken);
+      localStorage.setItem('idToken', authResponse.idToken);
       
       // Cookieにも保存（middlewareでアクセス可能）
-      document.cookie = `accessToken=${authResponse.tokens.accessToken}; path=/; max-age=${60 * 60 * 24 * 7}; secure; samesite=strict`;
+      document.cookie = `accessToken=${authResponse.accessToken}; path=/; max-age=${60 * 60 * 24 * 7}; secure; samesite=strict`;
 
       return authResponse;
     } catch (err) {
@@ -24,10 +24,30 @@ describe('PasskeyService', () => {
   });
 
   describe('createPasskey', () => {
-    it('should create a new passkey successfully', async () => {
-      const mockChallenge = 'test-challenge';
-      const mockUserId = 'test-user-id';
-      const mockUserName = 'test@example.com';
+    const options = {
+      challenge: 'test-challenge',
+      rp: {
+        name: 'ECレコメンド',
+        id: 'localhost',
+      },
+      user: {
+        // サーバーのユーザーハンドル (base64url)
+        id: 'c2VydmVyLXVzZXItaGFuZGxl',
+        name: 'test@example.com',
+        displayName: 'test@example.com',
+      },
+      pubKeyCredParams: [
+        { alg: -7, type: 'public-key' as const },
+        { alg: -257, type: 'public-key' as const },
+      ],
+      authenticatorSelection: {
+        residentKey: 'preferred' as const,
+        userVerification: 'preferred' as const,
+      },
+      timeout: 300000,
+    };
+
+    it('should create a new passkey from the server options', async () => {
       const mockResponse = {
         id: 'credential-id',
         rawId: 'credential-raw-id',
@@ -41,34 +61,10 @@ describe('PasskeyService', () => {
 
       vi.mocked(startRegistration).mockResolvedValue(mockResponse);
 
-      const result = await passkeyService.createPasskey(
-        mockChallenge,
-        mockUserId,
-        mockUserName
-      );
-
-      expect(startRegistration).toHaveBeenCalledWith({
-        challenge: mockChallenge,
-        rp: {
-          name: 'ECレコメンド',
-          id: 'localhost',
-        },
-        user: {
-          id: mockUserId,
-          name: mockUserName,
-          displayName: mockUserName,
-        },
-        pubKeyCredParams: [
-          { alg: -7, type: 'public-key' },
-          { alg: -257, type: 'public-key' },
-        ],
-        authenticatorSelection: {
-          residentKey: 'preferred',
-          userVerification: 'preferred',
-        },
-        timeout: 60000,
-        attestation: 'none',
-      });
+      const result = await passkeyService.createPasskey(options);
+
+      // オプションはそのまま渡し、user.id を組み立て直さない
+      expect(startRegistration).toHaveBeenCalledWith(options);
 
       expect(result).toEqual(mockResponse);
     });
@@ -77,14 +73,20 @@ describe('PasskeyService', () => {
       vi.mocked(startRegistration).mockRejectedValue(new Error('Registration failed'));
 
       await expect(
-        passkeyService.createPasskey('challenge', 'user-id', 'user@example.com')
+        passkeyService.createPasskey(options)
       ).rejects.toThrow('Registration failed');
     });
   });
 
   describe('authenticateWithPasskey', () => {
+    const options = {
+      challenge: 'test-challenge',
+      rpId: 'localhost',
+      userVerification: 'preferred' as const,
+      timeout: 300000,
+    };
+
     it('should authenticate with passkey successfully', async () => {
-      const mockChallenge = 'test-challenge';
       const mockResponse = {
         id: 'credential-id',
         rawId: 'credential-raw-id',
@@ -99,14 +101,9 @@ describe('PasskeyService', () => {
 
       vi.mocked(startAuthentication).mockResolvedValue(mockResponse);
 
-      const result = await passkeyService.authenticateWithPasskey(mockChallenge);
+      const result = await passkeyService.authenticateWithPasskey(options);
 
-      expect(startAuthentication).toHaveBeenCalledWith({
-        challenge: mockChallenge,
-        rpId: 'localhost',
-        userVerification: 'preferred',
-        timeout: 60000,
-      });
+      expect(startAuthentication).toHaveBeenCalledWith(options);
 
       expect(result).toEqual(mockResponse);
     });
@@ -115,7 +112,7 @@ describe('PasskeyService', () => {
       vi.mocked(startAuthentication).mockRejectedValue(new Error('Authentication failed'));
 
       await expect(
-        passkeyService.authenticateWithPasskey('challenge')
+        passkeyService.authenticateWithPasskey(options)
       ).rejects.toThrow('Authentication failed');
     });
   });
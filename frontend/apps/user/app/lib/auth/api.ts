@@ -1,3 +1,8 @@
+import type {
+  PublicKeyCredentialCreationOptionsJSON,
+  PublicKeyCredentialRequestOptionsJSON,
+} from '@simplewebauthn/types';
+
 // Auth API Client for Backend Authentication
 export const AUTH_API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
 
@@ -25,6 +30,22 @@ export interface AuthResponse {
   };
 }
 
+// Passkey ceremony options as returned by the server. publicKey is passed
+// to @simplewebauthn/browser unchanged.
+export interface PasskeyRegistrationOptions {
+  challenge: string;
+  options: {
+    publicKey: PublicKeyCredentialCreationOptionsJSON;
+  };
+}
+
+export interface PasskeyAuthenticationOptions {
+  challenge: string;
+  options: {
+    publicKey: PublicKeyCredentialRequestOptionsJSON;
+  };
+}
+
 export interface ApiError {
   error: string;
   message: string;
@@ -128,32 +149,39 @@ class AuthApiClient {
   }
 
   // Passkey API methods
-  async passkeyRegisterBegin(userId: string, userName: string): Promise<{ challenge: string }> {
-    return this.request<{ challenge: string }>('/auth/passkey/register/begin', {
+  async passkeyRegisterBegin(token: string): Promise<PasskeyRegistrationOptions> {
+    return this.request<PasskeyRegistrationOptions>('/auth/passkey/register/begin', {
       method: 'POST',
-      body: JSON.stringify({ userId, userName }),
+      headers: {
+        'Authorization': `Bearer ${token}`,
+      },
+      body: JSON.stringify({}),
     });
   }
 
-  async passkeyRegisterComplete(userId: string, credential: unknown): Promise<{ success: boolean }> {
+  async passkeyRegisterComplete(
+    token: string,
+    userId: string,
+    credential: unknown
+  ): Promise<{ success: boolean }> {
     return this.request<{ success: boolean }>('/auth/passkey/register/complete', {
       method: 'POST',
+      headers: {
+        'Authorization': `Bearer ${token}`,
+      },
       body: JSON.stringify({ userId, credential }),
     });
   }
 
-  async passkeyAuthenticateBegin(): Promise<{ challenge: string }> {
-    return this.request<{ challenge: string }>('/auth/passkey/authenticate/begin', {
+  async passkeyAuthenticateBegin(): Promise<PasskeyAuthenticationOptions> {
+    return this.request<PasskeyAuthenticationOptions>('/auth/passkey/authenticate/begin', {
       method: 'POST',
       body: JSON.stringify({}),
     });
   }
 
-  async passkeyAuthenticateComplete(credential: unknown): Promise<{
-    user: { id: string; email: string };
-    tokens: { idToken: string; accessToken: string };
-  }> {
-    return this.request('/auth/passkey/authenticate/complete', {
+  async passkeyAuthenticateComplete(credential: unknown): Promise<AuthResponse> {
+    return this.request<AuthResponse>('/auth/passkey/authenticate/complete', {
       method: 'POST',
       body: JSON.stringify({ credential }),
     });
@@ -11,15 +11,6 @@ import type {
 } from '@simplewebauthn/types';
 
 export class PasskeyService {
-  private rpId: string;
-  private rpName: string;
-
-  constructor() {
-    // 開発環境と本番環境で適切なRPIDを設定
-    this.rpId = process.env.NEXT_PUBLIC_PASSKEY_RP_ID || 'localhost';
-    this.rpName = 'ECレコメンド';
-  }
-
   /**
    * WebAuthnがサポートされているか確認
    */
@@ -32,39 +23,16 @@ export class PasskeyService {
 
   /**
    * 新しいパスキーを作成
+   *
+   * optionsはサーバーの register/begin が返したものをそのまま使う。
+   * user.id はサーバーがサインイン時に照合するユーザーハンドルなので、
+   * クライアント側で組み立ててはいけない。
    */
   async createPasskey(
-    challenge: string,
-    userId: string,
-    userName: string
+    options: PublicKeyCredentialCreationOptionsJSON
   ): Promise<RegistrationResponseJSON> {
-    const userIdBuffer = new TextEncoder().encode(userId);
-
-    const publicKeyCredentialCreationOptions: PublicKeyCredentialCreationOptionsJSON = {
-      challenge,
-      rp: {
-        name: this.rpName,
-        id: this.rpId,
-      },
-      user: {
-        id: Array.from(userIdBuffer).map(b => String.fromCharCode(b)).join(''),
-        name: userName,
-        displayName: userName,
-      },
-      pubKeyCredParams: [
-        { alg: -7, type: 'public-key' }, // ES256
-        { alg: -257, type: 'public-key' }, // RS256
-      ],
-      authenticatorSelection: {
-        residentKey: 'preferred',
-        userVerification: 'preferred',
-      },
-      timeout: 60000,
-      attestation: 'none',
-    };
-
     try {
-      const response = await startRegistration(publicKeyCredentialCreationOptions);
+      const response = await startRegistration(options);
       return response;
     } catch (error) {
       console.error('Passkey registration failed:', error);
@@ -74,24 +42,14 @@ export class PasskeyService {
 
   /**
    * パスキーで認証
+   *
+   * optionsはサーバーの authenticate/begin が返したものをそのまま使う。
    */
   async authenticateWithPasskey(
-    challenge: string,
-    allowCredentials?: Array<{ id: string; type: 'public-key' }>
+    options: PublicKeyCredentialRequestOptionsJSON
   ): Promise<AuthenticationResponseJSON> {
-    const publicKeyCredentialRequestOptions: PublicKeyCredentialRequestOptionsJSON = {
-      challenge,
-      rpId: this.rpId,
-      userVerification: 'preferred',
-      timeout: 60000,
-    };
-
-    if (allowCredentials && allowCredentials.length > 0) {
-      publicKeyCredentialRequestOptions.allowCredentials = allowCredentials;
-    }
-
     try {
-      const response = await startAuthentication(publicKeyCredentialRequestOptions);
+      const response = await startAuthentication(options);
       return response;
     } catch (error) {
       console.error('Passkey authentication failed:', error);
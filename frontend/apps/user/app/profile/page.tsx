@@ -17,7 +17,7 @@ export default function ProfilePage() {
   const handleRegisterPasskey = async () => {
     if (!user) return;
 
-    const success = await registerPasskey(user.id, user.email);
+    const success = await registerPasskey(user.id);
     if (success) {
       setShowPasskeyForm(false);
       setPasskeyName('');